@@ -0,0 +1,92 @@
+// Package bounds has the bounding volumes used to cull and collide objects
+package bounds
+
+import (
+	"math"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// AABB is an axis aligned box
+type AABB struct {
+	Min, Max mgl32.Vec3
+}
+
+// FromPoints returns the smallest box around points, the zero box if there are none
+func FromPoints(points []mgl32.Vec3) AABB {
+	if len(points) == 0 {
+		return AABB{}
+	}
+	b := AABB{points[0], points[0]}
+	for _, p := range points[1:] {
+		for i := 0; i < 3; i++ {
+			b.Min[i] = float32(math.Min(float64(b.Min[i]), float64(p[i])))
+			b.Max[i] = float32(math.Max(float64(b.Max[i]), float64(p[i])))
+		}
+	}
+	return b
+}
+
+// Center returns the middle of the box
+func (b AABB) Center() mgl32.Vec3 {
+	return b.Min.Add(b.Max).Mul(0.5)
+}
+
+// Extents returns half the size of the box
+func (b AABB) Extents() mgl32.Vec3 {
+	return b.Max.Sub(b.Min).Mul(0.5)
+}
+
+// Translate returns the box moved by d
+func (b AABB) Translate(d mgl32.Vec3) AABB {
+	return AABB{b.Min.Add(d), b.Max.Add(d)}
+}
+
+// Intersects reports whether the boxes overlap, touching faces don't count
+func (b AABB) Intersects(o AABB) bool {
+	for i := 0; i < 3; i++ {
+		if b.Max[i] <= o.Min[i] || b.Min[i] >= o.Max[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Transform returns the box around b after transforming it by m
+func (b AABB) Transform(m mgl32.Mat4) AABB {
+	// the extents along each axis are the sum of the absolute rotated extents
+	c, e := m.Mul4x1(b.Center().Vec4(1)).Vec3(), b.Extents()
+	var r mgl32.Vec3
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			r[i] += float32(math.Abs(float64(m.At(i, j)))) * e[j]
+		}
+	}
+	return AABB{c.Sub(r), c.Add(r)}
+}
+
+// Sphere is a bounding sphere
+type Sphere struct {
+	Center mgl32.Vec3
+	Radius float32
+}
+
+// SphereFromPoints returns a sphere around points centered on their box. It
+// isn't the smallest one, but close enough for culling.
+func SphereFromPoints(points []mgl32.Vec3) Sphere {
+	s := Sphere{Center: FromPoints(points).Center()}
+	for _, p := range points {
+		s.Radius = float32(math.Max(float64(s.Radius), float64(p.Sub(s.Center).Len())))
+	}
+	return s
+}
+
+// Transform returns the sphere around s after transforming it by m, growing it
+// by the largest scale of m
+func (s Sphere) Transform(m mgl32.Mat4) Sphere {
+	scale := float32(0)
+	for j := 0; j < 3; j++ {
+		scale = float32(math.Max(float64(scale), float64(m.Col(j).Vec3().Len())))
+	}
+	return Sphere{m.Mul4x1(s.Center.Vec4(1)).Vec3(), s.Radius * scale}
+}
@@ -0,0 +1,109 @@
+package bounds
+
+import "github.com/go-gl/mathgl/mgl32"
+
+// Frustum is the volume a camera sees, as six planes facing inwards. Each plane
+// is stored as (normal, distance), a point p is on its inner side if
+// normal·p + distance >= 0.
+type Frustum struct {
+	Planes [6]mgl32.Vec4 // left, right, bottom, top, near, far
+}
+
+// NewFrustum extracts the frustum from a projection*camera matrix
+func NewFrustum(viewProjection mgl32.Mat4) Frustum {
+	// a point is inside if -w <= x, y, z <= w in clip space, each of those six
+	// comparisons is a plane made from the rows of the matrix
+	m := viewProjection
+	r0, r1, r2, r3 := m.Row(0), m.Row(1), m.Row(2), m.Row(3)
+	f := Frustum{[6]mgl32.Vec4{
+		r3.Add(r0), r3.Sub(r0),
+		r3.Add(r1), r3.Sub(r1),
+		r3.Add(r2), r3.Sub(r2),
+	}}
+	for i, p := range f.Planes {
+		f.Planes[i] = p.Mul(1 / p.Vec3().Len())
+	}
+	return f
+}
+
+// ContainsPoint reports whether p is inside the frustum
+func (f Frustum) ContainsPoint(p mgl32.Vec3) bool {
+	for _, plane := range f.Planes {
+		if plane.Vec3().Dot(p)+plane[3] < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// IntersectsSphere reports whether any part of the sphere is inside the frustum
+func (f Frustum) IntersectsSphere(s Sphere) bool {
+	for _, plane := range f.Planes {
+		if plane.Vec3().Dot(s.Center)+plane[3] < -s.Radius {
+			return false
+		}
+	}
+	return true
+}
+
+// IntersectsAABB reports whether the box may be inside the frustum. Boxes near a
+// corner of the frustum can be reported as visible although they are outside,
+// which is fine for culling.
+func (f Frustum) IntersectsAABB(b AABB) bool {
+	for _, plane := range f.Planes {
+		// the corner furthest along the normal is the last one to leave the plane
+		var p mgl32.Vec3
+		for i := 0; i < 3; i++ {
+			if plane[i] >= 0 {
+				p[i] = b.Max[i]
+			} else {
+				p[i] = b.Min[i]
+			}
+		}
+		if plane.Vec3().Dot(p)+plane[3] < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Culler tests objects against a frustum and counts how many were drawn and
+// culled. Reset it with the matrices of the camera once per frame.
+type Culler struct {
+	Frustum Frustum
+	Drawn   int
+	Culled  int
+}
+
+// Reset sets the frustum to the one of viewProjection and the counters to 0
+func (c *Culler) Reset(viewProjection mgl32.Mat4) {
+	c.Frustum = NewFrustum(viewProjection)
+	c.Drawn, c.Culled = 0, 0
+}
+
+// VisibleAABB reports whether the box should be drawn and counts it. A nil Culler
+// draws everything.
+func (c *Culler) VisibleAABB(b AABB) bool {
+	if c == nil {
+		return true
+	}
+	return c.count(c.Frustum.IntersectsAABB(b))
+}
+
+// VisibleSphere reports whether the sphere should be drawn and counts it. A nil
+// Culler draws everything.
+func (c *Culler) VisibleSphere(s Sphere) bool {
+	if c == nil {
+		return true
+	}
+	return c.count(c.Frustum.IntersectsSphere(s))
+}
+
+func (c *Culler) count(visible bool) bool {
+	if visible {
+		c.Drawn++
+	} else {
+		c.Culled++
+	}
+	return visible
+}
@@ -0,0 +1,127 @@
+package bounds
+
+import (
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// testCamera is a 90° square view from 10,5,0 along +x, seeing 1 to 100 blocks
+// ahead. At a depth d it spans from -d to d sideways and up.
+func testCamera() mgl32.Mat4 {
+	projection := mgl32.Perspective(mgl32.DegToRad(90), 1, 1, 100)
+	view := mgl32.LookAtV(mgl32.Vec3{10, 5, 0}, mgl32.Vec3{11, 5, 0}, mgl32.Vec3{0, 1, 0})
+	return projection.Mul4(view)
+}
+
+func testFrustum() Frustum {
+	return NewFrustum(testCamera())
+}
+
+// cam returns the world position of a point at depth d in front of the test
+// camera, u to the right and v up
+func cam(d, u, v float32) mgl32.Vec3 {
+	return mgl32.Vec3{10 + d, 5 + v, u}
+}
+
+func TestFrustumPoints(t *testing.T) {
+	f := testFrustum()
+	tests := []struct {
+		d, u, v float32
+		inside  bool
+	}{
+		{10, 0, 0, true},
+		{10, 9.9, -9.9, true},
+		{10, 10.1, 0, false},
+		{10, 0, -10.1, false},
+		{0.9, 0, 0, false},
+		{-10, 0, 0, false},
+		{99.9, 0, 0, true},
+		{100.1, 0, 0, false},
+	}
+	for _, test := range tests {
+		if got := f.ContainsPoint(cam(test.d, test.u, test.v)); got != test.inside {
+			t.Errorf("point at depth %v, %v right and %v up: got inside %v", test.d, test.u, test.v, got)
+		}
+	}
+}
+
+func TestFrustumSpheres(t *testing.T) {
+	f := testFrustum()
+	tests := []struct {
+		name    string
+		d, u, v float32
+		radius  float32
+		visible bool
+	}{
+		{"inside", 10, 0, 0, 1, true},
+		{"around the camera", 0, 0, 0, 2, true},
+		{"behind", -5, 0, 0, 1, false},
+		{"before near", 0, 0, 0, 0.5, false},
+		{"straddling near", 0.5, 0, 0, 1, true},
+		{"beyond far", 105, 0, 0, 2, false},
+		{"straddling far", 101, 0, 0, 2, true},
+		// a sphere 15 blocks aside at depth 10 is 5/√2 = 3.54 from the side plane
+		{"outside left", 10, -15, 0, 3, false},
+		{"straddling left", 10, -15, 0, 4, true},
+		{"outside right", 10, 15, 0, 3, false},
+		{"straddling right", 10, 15, 0, 4, true},
+		{"outside bottom", 10, 0, -15, 3, false},
+		{"straddling bottom", 10, 0, -15, 4, true},
+		{"outside top", 10, 0, 15, 3, false},
+		{"straddling top", 10, 0, 15, 4, true},
+	}
+	for _, test := range tests {
+		s := Sphere{cam(test.d, test.u, test.v), test.radius}
+		if got := f.IntersectsSphere(s); got != test.visible {
+			t.Errorf("%v: got visible %v, want %v", test.name, got, test.visible)
+		}
+	}
+}
+
+func TestFrustumAABBs(t *testing.T) {
+	f := testFrustum()
+	tests := []struct {
+		name     string
+		min, max [3]float32 // depth, right, up
+		visible  bool
+	}{
+		{"inside", [3]float32{9, -1, -1}, [3]float32{11, 1, 1}, true},
+		{"containing the frustum", [3]float32{-200, -200, -200}, [3]float32{200, 200, 200}, true},
+		{"behind", [3]float32{-5, -1, -1}, [3]float32{-2, 1, 1}, false},
+		{"before near", [3]float32{0.2, -0.1, -0.1}, [3]float32{0.5, 0.1, 0.1}, false},
+		{"straddling near", [3]float32{0.5, -0.1, -0.1}, [3]float32{2, 0.1, 0.1}, true},
+		{"beyond far", [3]float32{101, -1, -1}, [3]float32{110, 1, 1}, false},
+		{"straddling far", [3]float32{99, -1, -1}, [3]float32{101, 1, 1}, true},
+		{"outside left", [3]float32{9, -20, -1}, [3]float32{11, -16, 1}, false},
+		{"straddling left", [3]float32{9, -12, -1}, [3]float32{11, -8, 1}, true},
+		{"outside right", [3]float32{9, 16, -1}, [3]float32{11, 20, 1}, false},
+		{"straddling right", [3]float32{9, 8, -1}, [3]float32{11, 12, 1}, true},
+		{"outside bottom", [3]float32{9, -1, -20}, [3]float32{11, 1, -16}, false},
+		{"straddling bottom", [3]float32{9, -1, -12}, [3]float32{11, 1, -8}, true},
+		{"outside top", [3]float32{9, -1, 16}, [3]float32{11, 1, 20}, false},
+		{"straddling top", [3]float32{9, -1, 8}, [3]float32{11, 1, 12}, true},
+	}
+	for _, test := range tests {
+		b := AABB{cam(test.min[0], test.min[1], test.min[2]), cam(test.max[0], test.max[1], test.max[2])}
+		if got := f.IntersectsAABB(b); got != test.visible {
+			t.Errorf("%v: got visible %v, want %v", test.name, got, test.visible)
+		}
+	}
+}
+
+func TestCuller(t *testing.T) {
+	var nilCuller *Culler
+	if !nilCuller.VisibleAABB(AABB{}) || !nilCuller.VisibleSphere(Sphere{}) {
+		t.Error("a nil Culler culled something")
+	}
+
+	c := new(Culler)
+	c.Reset(testCamera())
+	c.VisibleSphere(Sphere{cam(10, 0, 0), 1})
+	c.VisibleSphere(Sphere{cam(-10, 0, 0), 1})
+	c.VisibleAABB(AABB{cam(-5, -1, -1), cam(-2, 1, 1)})
+	if c.Drawn != 1 || c.Culled != 2 {
+		t.Errorf("drew %v and culled %v, want 1 and 2", c.Drawn, c.Culled)
+	}
+}
@@ -0,0 +1,136 @@
+// Package capture reads rendered frames back without stalling the GPU and
+// saves them as screenshots or recordings
+package capture
+
+import (
+	"image"
+	"time"
+	"unsafe"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/tehcyx/goengine/render"
+)
+
+// Reader reads frames back through a ring of pixel buffers. Reading into a
+// buffer returns at once, the pixels are copied out frames later once the GPU
+// got to them, so the frame doesn't wait for everything drawn before it.
+type Reader struct {
+	Width, Height int32
+
+	slots []slot
+	next  int // slot the next Read goes to
+}
+
+type slot struct {
+	buffer uint32
+	fence  uintptr // 0 while the slot is free
+	done   func(img *image.RGBA)
+}
+
+// NewReader creates a reader of width by height frames with a ring of depth
+// buffers, the number of reads that can wait at once. Must be called on the GL thread.
+func NewReader(width, height int32, depth int) *Reader {
+	r := new(Reader)
+	r.Width, r.Height = width, height
+	r.slots = make([]slot, depth)
+	for i := range r.slots {
+		gl.GenBuffers(1, &r.slots[i].buffer)
+		gl.BindBuffer(gl.PIXEL_PACK_BUFFER, r.slots[i].buffer)
+		gl.BufferData(gl.PIXEL_PACK_BUFFER, int(width*height*4), nil, gl.STREAM_READ)
+	}
+	gl.BindBuffer(gl.PIXEL_PACK_BUFFER, 0)
+	return r
+}
+
+// Read starts reading the framebuffer fbo, 0 is the window. done is called
+// with the pixels, top row first, from Poll or Flush once they arrived. If all
+// buffers are waiting, Read waits for the oldest one first.
+func (r *Reader) Read(fbo uint32, done func(img *image.RGBA)) {
+	s := &r.slots[r.next]
+	if s.fence != 0 {
+		r.complete(s, true)
+	}
+	r.next = (r.next + 1) % len(r.slots)
+
+	var previous int32
+	gl.GetIntegerv(gl.READ_FRAMEBUFFER_BINDING, &previous)
+	gl.BindFramebuffer(gl.READ_FRAMEBUFFER, fbo)
+	gl.BindBuffer(gl.PIXEL_PACK_BUFFER, s.buffer)
+	gl.PixelStorei(gl.PACK_ALIGNMENT, 1)
+	// with a pixel pack buffer bound the pointer is an offset into it
+	gl.ReadPixels(0, 0, r.Width, r.Height, gl.RGBA, gl.UNSIGNED_BYTE, gl.PtrOffset(0))
+	gl.BindBuffer(gl.PIXEL_PACK_BUFFER, 0)
+	gl.BindFramebuffer(gl.READ_FRAMEBUFFER, uint32(previous))
+	s.fence = gl.FenceSync(gl.SYNC_GPU_COMMANDS_COMPLETE, 0)
+	s.done = done
+}
+
+// Poll hands the reads the GPU finished to their done functions, oldest first
+func (r *Reader) Poll() {
+	for i := range r.slots {
+		s := &r.slots[(r.next+i)%len(r.slots)]
+		if s.fence != 0 && !r.complete(s, false) {
+			// later reads can't be done before this one
+			return
+		}
+	}
+}
+
+// Flush waits for all reads and hands them to their done functions
+func (r *Reader) Flush() {
+	for i := range r.slots {
+		if s := &r.slots[(r.next+i)%len(r.slots)]; s.fence != 0 {
+			r.complete(s, true)
+		}
+	}
+}
+
+// Pending returns the number of reads still waiting
+func (r *Reader) Pending() int {
+	n := 0
+	for _, s := range r.slots {
+		if s.fence != 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// complete copies the pixels of a slot out and calls its done function,
+// reports whether the read was finished. With wait it waits for the GPU.
+func (r *Reader) complete(s *slot, wait bool) bool {
+	status := gl.ClientWaitSync(s.fence, gl.SYNC_FLUSH_COMMANDS_BIT, 0)
+	for wait && status == gl.TIMEOUT_EXPIRED {
+		status = gl.ClientWaitSync(s.fence, gl.SYNC_FLUSH_COMMANDS_BIT, uint64(time.Second))
+	}
+	if status == gl.TIMEOUT_EXPIRED {
+		return false
+	}
+	gl.DeleteSync(s.fence)
+	s.fence = 0
+
+	img := image.NewRGBA(image.Rect(0, 0, int(r.Width), int(r.Height)))
+	gl.BindBuffer(gl.PIXEL_PACK_BUFFER, s.buffer)
+	if p := gl.MapBufferRange(gl.PIXEL_PACK_BUFFER, 0, len(img.Pix), gl.MAP_READ_BIT); p != nil {
+		copy(img.Pix, unsafe.Slice((*uint8)(p), len(img.Pix)))
+		gl.UnmapBuffer(gl.PIXEL_PACK_BUFFER)
+	}
+	gl.BindBuffer(gl.PIXEL_PACK_BUFFER, 0)
+	render.FlipRows(img)
+	done := s.done
+	s.done = nil
+	done(img)
+	return true
+}
+
+// Delete frees the buffers without waiting for the reads, Flush first to keep
+// them. Must be called on the GL thread.
+func (r *Reader) Delete() {
+	for i := range r.slots {
+		if r.slots[i].fence != 0 {
+			gl.DeleteSync(r.slots[i].fence)
+		}
+		gl.DeleteBuffers(1, &r.slots[i].buffer)
+	}
+	r.slots = nil
+}
@@ -0,0 +1,159 @@
+package capture
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// SavePNG writes an image to a PNG file
+func SavePNG(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to encode %v: %v", path, err)
+	}
+	return f.Close()
+}
+
+// ScreenshotPath returns a file name in dir for a screenshot taken at t
+func ScreenshotPath(dir string, t time.Time) string {
+	return filepath.Join(dir, t.Format("screenshot-2006-01-02-15-04-05.000.png"))
+}
+
+// Screenshot reads the framebuffer fbo back through r and writes it to a PNG
+// file at path. The file is encoded in the background, errors are logged and
+// saved, if not nil, is called from the encoding goroutine once it's written.
+func Screenshot(r *Reader, fbo uint32, path string, saved func()) {
+	r.Read(fbo, func(img *image.RGBA) {
+		go func() {
+			if err := SavePNG(path, img); err != nil {
+				log.Printf("screenshot failed: %v", err)
+				return
+			}
+			if saved != nil {
+				saved()
+			}
+		}()
+	})
+}
+
+// Recorder writes every frame it's given in the background, as numbered PNG
+// files or as raw RGBA into an encoder process
+type Recorder struct {
+	frames chan *image.RGBA
+	wg     sync.WaitGroup
+	count  int
+	err    error // the first error writing, read after the writer stopped
+
+	write func(n int, img *image.RGBA) error
+	close func() error
+}
+
+// queue is the number of frames waiting to be written before Add blocks
+const queue = 8
+
+func newRecorder(write func(n int, img *image.RGBA) error, close func() error) *Recorder {
+	r := new(Recorder)
+	r.frames = make(chan *image.RGBA, queue)
+	r.write = write
+	r.close = close
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		n := 0
+		for img := range r.frames {
+			if r.err != nil {
+				continue
+			}
+			if err := r.write(n, img); err != nil {
+				r.err = err
+				log.Printf("recording failed: %v", err)
+			}
+			n++
+		}
+	}()
+	return r
+}
+
+// RecordFrames creates a recorder writing frame-000000.png, frame-000001.png
+// and so on into dir, creating dir if needed
+func RecordFrames(dir string) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return newRecorder(func(n int, img *image.RGBA) error {
+		return SavePNG(filepath.Join(dir, fmt.Sprintf("frame-%06d.png", n)), img)
+	}, nil), nil
+}
+
+// RecordTo creates a recorder writing the frames as raw RGBA, top row first,
+// into the standard input of cmd, which it starts
+func RecordTo(cmd *exec.Cmd) (*Recorder, error) {
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %v: %v", cmd.Path, err)
+	}
+	return newRecorder(func(n int, img *image.RGBA) error {
+		return writeRows(stdin, img)
+	}, func() error {
+		stdin.Close()
+		return cmd.Wait()
+	}), nil
+}
+
+// FFmpeg returns the ffmpeg command encoding raw frames of width by height at
+// fps frames per second into the video file path, for RecordTo
+func FFmpeg(path string, width, height, fps int) *exec.Cmd {
+	return exec.Command("ffmpeg", "-y", "-loglevel", "error",
+		"-f", "rawvideo", "-pixel_format", "rgba", "-video_size", fmt.Sprintf("%vx%v", width, height),
+		"-framerate", fmt.Sprint(fps), "-i", "-",
+		"-pix_fmt", "yuv420p", path)
+}
+
+func writeRows(w io.Writer, img *image.RGBA) error {
+	row := img.Rect.Dx() * 4
+	for y := 0; y < img.Rect.Dy(); y++ {
+		if _, err := w.Write(img.Pix[y*img.Stride : y*img.Stride+row]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Add queues a frame, it blocks while the queue is full so no frame is lost
+func (r *Recorder) Add(img *image.RGBA) {
+	r.count++
+	r.frames <- img
+}
+
+// Frames returns the number of frames added so far
+func (r *Recorder) Frames() int {
+	return r.count
+}
+
+// Close writes the queued frames and waits for the encoder to finish, it
+// returns the first error writing a frame or closing the encoder
+func (r *Recorder) Close() error {
+	close(r.frames)
+	r.wg.Wait()
+	if r.close != nil {
+		if err := r.close(); err != nil && r.err == nil {
+			r.err = err
+		}
+	}
+	return r.err
+}
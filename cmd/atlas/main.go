@@ -0,0 +1,72 @@
+// Command atlas packs a directory of block textures into an atlas, or into the
+// layers of an array texture, and writes it as a PNG next to a JSON lookup of
+// where every texture ended up. Array layers are stacked top to bottom, see
+// texture.SplitLayers.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+
+	"github.com/tehcyx/goengine/texture"
+)
+
+var inFlag = flag.String("in", "res/textures/blocks", "directory of PNG and JPEG files to pack")
+var outFlag = flag.String("out", "blocks", "output path without extension, .png and .json are added")
+var paddingFlag = flag.Int("padding", 8, "pixels of edge bleeding around every atlas tile")
+var arrayFlag = flag.Bool("array", false, "pack an array texture instead of an atlas")
+
+func main() {
+	flag.Parse()
+	if err := run(); err != nil {
+		fmt.Fprintf(os.Stderr, "atlas: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	images, err := texture.LoadDir(*inFlag)
+	if err != nil {
+		return err
+	}
+
+	var img *image.NRGBA
+	var lookup *texture.Lookup
+	if *arrayFlag {
+		var layers []*image.NRGBA
+		layers, lookup, err = texture.PackArray(images)
+		if err != nil {
+			return err
+		}
+		img = texture.JoinLayers(layers)
+	} else {
+		img, lookup, err = texture.PackAtlas(images, *paddingFlag)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := writeFile(*outFlag+".png", func(f *os.File) error { return png.Encode(f, img) }); err != nil {
+		return err
+	}
+	if err := writeFile(*outFlag+".json", func(f *os.File) error { return lookup.Write(f) }); err != nil {
+		return err
+	}
+	fmt.Printf("packed %v textures into %v.png, %vx%v with %v layers\n", len(lookup.Tiles), *outFlag, img.Rect.Dx(), img.Rect.Dy(), lookup.Layers)
+	return nil
+}
+
+func writeFile(path string, write func(f *os.File) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %v: %v", path, err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,383 @@
+// Command golden renders scenes headless and compares them to the golden
+// images in res/golden, so rendering changes show up in make test. Run it with
+// -update to accept the current rendering as the new golden images. Where no
+// headless context can be created it skips the comparison without failing.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/tehcyx/goengine/bounds"
+	"github.com/tehcyx/goengine/golden"
+	"github.com/tehcyx/goengine/headless"
+	"github.com/tehcyx/goengine/light"
+	"github.com/tehcyx/goengine/material"
+	"github.com/tehcyx/goengine/mesh"
+	"github.com/tehcyx/goengine/obj"
+	"github.com/tehcyx/goengine/render"
+	"github.com/tehcyx/goengine/shader"
+	"github.com/tehcyx/goengine/shadow"
+	"github.com/tehcyx/goengine/sky"
+	"github.com/tehcyx/goengine/texture"
+	"github.com/tehcyx/goengine/world"
+)
+
+var (
+	dirFlag       = flag.String("dir", "res/golden", "directory of the golden images")
+	outFlag       = flag.String("out", "bin/golden", "directory the renderings and diffs of failing scenes are written to")
+	updateFlag    = flag.Bool("update", false, "write the renderings as the new golden images")
+	runFlag       = flag.String("run", "", "comma separated scenes to render, all if empty")
+	framesFlag    = flag.Int("frames", 30, "frames to render before comparing the last one")
+	widthFlag     = flag.Int("width", 320, "width of the renderings")
+	heightFlag    = flag.Int("height", 240, "height of the renderings")
+	thresholdFlag = flag.Int("threshold", int(golden.DefaultTolerance.Threshold), "channel differences up to it are ignored")
+	fractionFlag  = flag.Float64("fraction", golden.DefaultTolerance.Fraction, "share of the pixels that may differ")
+)
+
+// step is the simulated time between two frames
+const step = time.Second / 60
+
+// scene renders frames into the target of the context
+type scene struct {
+	name   string
+	render func(c *headless.Context, frames int) error
+}
+
+var scenes = []scene{
+	{"objects", renderObjects},
+	{"world", func(c *headless.Context, frames int) error {
+		return renderWorld(c, frames, 9, mgl32.Vec3{0, 90, 0}, mgl32.Vec3{48, 50, 48})
+	}},
+	{"sunset", func(c *headless.Context, frames int) error {
+		return renderWorld(c, frames, 18, mgl32.Vec3{0, 75, 0}, mgl32.Vec3{48, 85, 48})
+	}},
+}
+
+func main() {
+	flag.Parse()
+	runtime.LockOSThread()
+
+	c, err := headless.New(int32(*widthFlag), int32(*heightFlag))
+	if errors.Is(err, headless.ErrUnavailable) && !*updateFlag {
+		// nothing to compare, which isn't a rendering change
+		fmt.Println("SKIP golden images:", err)
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer c.Delete()
+	fmt.Println("Rendering with", c.Renderer())
+
+	tolerance := golden.Tolerance{Threshold: uint8(*thresholdFlag), Fraction: *fractionFlag}
+	failed := false
+	for _, s := range scenes {
+		if *runFlag != "" && !contains(strings.Split(*runFlag, ","), s.name) {
+			continue
+		}
+		if err := check(c, s, tolerance); err != nil {
+			fmt.Printf("FAIL %v: %v\n", s.name, err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// check renders a scene and compares it to its golden image, writing the
+// rendering and the difference to the output directory if they don't match
+func check(c *headless.Context, s scene, tolerance golden.Tolerance) error {
+	if err := s.render(c, *framesFlag); err != nil {
+		return err
+	}
+	img := c.Target.ReadPixels()
+	path := filepath.Join(*dirFlag, s.name+".png")
+	result, err := golden.Check(path, img, tolerance, *updateFlag)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("no golden image %v, run with -update to create it", path)
+		}
+		return err
+	}
+	if *updateFlag {
+		fmt.Println("updated", path)
+		return nil
+	}
+	if result.Match(tolerance) {
+		fmt.Printf("ok   %v: %v\n", s.name, result)
+		return nil
+	}
+	if err := os.MkdirAll(*outFlag, 0755); err != nil {
+		return err
+	}
+	got, diff := filepath.Join(*outFlag, s.name+".png"), filepath.Join(*outFlag, s.name+".diff.png")
+	if err := golden.Save(got, img); err != nil {
+		return err
+	}
+	if err := golden.Save(diff, result.Diff); err != nil {
+		return err
+	}
+	return fmt.Errorf("%v, see %v and %v", result, got, diff)
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// frame is what every scene renders into before post processing, like the demo does
+type frame struct {
+	target *render.Target
+	post   *render.Chain
+	sky    *sky.Sky
+	lights *light.Buffer
+}
+
+func newFrame(c *headless.Context, hour float32) (*frame, error) {
+	f := new(frame)
+	var err error
+	if f.target, err = render.NewTarget(c.Target.Width, c.Target.Height, 4, gl.RGBA16F, true); err != nil {
+		return nil, err
+	}
+	if f.post, err = render.NewDefaultChain(c.Target.Width, c.Target.Height, "res/shaders/post"); err != nil {
+		f.target.Delete()
+		return nil, err
+	}
+	cycle := sky.NewCycle(hour)
+	cycle.Paused = true
+	if f.sky, err = sky.New("res/shaders", cycle); err != nil {
+		f.post.Delete()
+		f.target.Delete()
+		return nil, err
+	}
+	f.lights = light.NewBuffer()
+	return f, nil
+}
+
+// begin clears the frame and draws the sky
+func (f *frame) begin(camera, projection mgl32.Mat4) {
+	f.target.Bind()
+	gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
+	f.sky.Draw(camera, projection)
+	gl.Enable(gl.DEPTH_TEST)
+	gl.DepthFunc(gl.LESS)
+}
+
+// end post processes the frame into the target of the context
+func (f *frame) end(c *headless.Context) {
+	f.post.Run(f.target, c.Target.Framebuffer(), c.Target.Width, c.Target.Height)
+	gl.Finish()
+}
+
+func (f *frame) delete() {
+	f.lights.Delete()
+	f.sky.Delete()
+	f.post.Delete()
+	f.target.Delete()
+}
+
+// renderObjects renders the spinning monkey and checker cube of the demo
+func renderObjects(c *headless.Context, frames int) error {
+	f, err := newFrame(c, 9)
+	if err != nil {
+		return err
+	}
+	defer f.delete()
+	textures := texture.NewCache()
+	defer textures.Delete()
+	materials := material.NewLibrary(textures, texture.DefaultOptions())
+	defer materials.Delete()
+
+	program, err := materials.Program("res/shaders/object.vert", "res/shaders/object.frag")
+	if err != nil {
+		return err
+	}
+	mtls, err := obj.LoadMaterials("res/models/monkey.obj")
+	if err != nil {
+		return err
+	}
+	if len(mtls) == 0 {
+		return fmt.Errorf("res/models/monkey.obj uses no material")
+	}
+	monkeyMaterial, err := materials.FromMtl(mtls[0], program)
+	if err != nil {
+		return err
+	}
+	checker, err := materials.Load("res/materials/checker.json")
+	if err != nil {
+		return err
+	}
+	monkey := mesh.NewMeshFromFile("res/models/monkey.obj")
+	defer monkey.Delete()
+	cube := mesh.NewMeshFromFile("res/models/cube.obj")
+	defer cube.Delete()
+
+	aspect := float32(c.Target.Width) / float32(c.Target.Height)
+	projection := mgl32.Perspective(mgl32.DegToRad(45), aspect, 0.1, 10)
+	camera := mgl32.LookAtV(mgl32.Vec3{3, 3, 3}, mgl32.Vec3{}, mgl32.Vec3{0, 1, 0})
+	program.Use()
+	light.Use(program)
+	gl.UniformMatrix4fv(program.Uniform("projection"), 1, false, &projection[0])
+	gl.UniformMatrix4fv(program.Uniform("camera"), 1, false, &camera[0])
+	gl.Uniform3f(program.Uniform("eye"), 3, 3, 3)
+
+	var lights light.Set
+	f.sky.Cycle.Apply(&lights)
+	lamp := light.NewPoint(mgl32.Vec3{1.5, 1, 1.5}, 4)
+	lamp.Color, lamp.Intensity = mgl32.Vec3{1, 0.6, 0.3}, 4
+	spot := light.NewSpot(mgl32.Vec3{0, 3, 0}, mgl32.Vec3{0, -1, 0}, 6)
+	spot.Intensity = 6
+	lights.Points = append(lights.Points, lamp)
+	lights.Spots = append(lights.Spots, spot)
+	f.lights.Update(&lights)
+
+	for i := 1; i <= frames; i++ {
+		t := float32((step * time.Duration(i)).Seconds())
+		f.begin(camera, projection)
+		var binder material.Binder
+		binder.Apply(monkeyMaterial)
+		model := mgl32.HomogRotate3DY(mgl32.DegToRad(45) * t)
+		gl.UniformMatrix4fv(program.Uniform("model"), 1, false, &model[0])
+		monkey.Draw()
+		binder.Apply(checker)
+		model = mgl32.Translate3D(0, 1.5, 0).Mul4(mgl32.HomogRotate3DY(mgl32.DegToRad(-30) * t)).Mul4(mgl32.Scale3D(0.5, 0.5, 0.5))
+		gl.UniformMatrix4fv(program.Uniform("model"), 1, false, &model[0])
+		cube.Draw()
+		f.end(c)
+	}
+	return nil
+}
+
+// renderWorld renders the voxel world of the demo at an hour of the day, with
+// shadows, fog and the sky, once all chunks around the eye are meshed
+func renderWorld(c *headless.Context, frames int, hour float32, eye, target mgl32.Vec3) error {
+	f, err := newFrame(c, hour)
+	if err != nil {
+		return err
+	}
+	defer f.delete()
+
+	program, err := shader.Load("res/shaders/world.vert", "res/shaders/world.frag")
+	if err != nil {
+		return err
+	}
+	defer program.Delete()
+	depth, err := shader.Load("res/shaders/depth.vert", "res/shaders/depth.frag")
+	if err != nil {
+		return err
+	}
+	defer depth.Delete()
+	shadows, err := shadow.New(2048, 4)
+	if err != nil {
+		return err
+	}
+	defer shadows.Delete()
+	blocks, lookup, err := loadBlockTextures("res/textures/blocks")
+	if err != nil {
+		return err
+	}
+	defer blocks.Delete()
+
+	chunks := world.NewManager(world.NewWorld(1337), 8, runtime.NumCPU())
+	defer chunks.Close()
+	chunks.Textures = lookup
+	if err := settle(chunks, eye); err != nil {
+		return err
+	}
+	viewDistance := float32((chunks.ViewRadius - 1) * world.ChunkSize)
+	f.sky.FogStart = viewDistance * 2 / 3
+	f.sky.FogDensity = sky.FogDensity(f.sky.FogStart, viewDistance)
+
+	const fovy, near = 60.0, 0.1
+	aspect := float32(c.Target.Width) / float32(c.Target.Height)
+	projection := mgl32.Perspective(mgl32.DegToRad(fovy), aspect, near, 512)
+	camera := mgl32.LookAtV(eye, target, mgl32.Vec3{0, 1, 0})
+	var lights light.Set
+	f.sky.Cycle.Apply(&lights)
+	f.lights.Update(&lights)
+	program.Use()
+	light.Use(program)
+	gl.UniformMatrix4fv(program.Uniform("projection"), 1, false, &projection[0])
+	gl.UniformMatrix4fv(program.Uniform("camera"), 1, false, &camera[0])
+	gl.Uniform3fv(program.Uniform("eye"), 1, &eye[0])
+	gl.VertexAttrib1f(uint32(mesh.LAYER_VB), -1)
+
+	var culler bounds.Culler
+	for i := 0; i < frames; i++ {
+		f.begin(camera, projection)
+
+		shadows.Update(camera, mgl32.DegToRad(fovy), aspect, near, lights.Sun.Direction.Mul(-1))
+		depth.Use()
+		model := mgl32.Ident4()
+		gl.UniformMatrix4fv(depth.Uniform("model"), 1, false, &model[0])
+		shadows.Render(func(cascade int, viewProjection mgl32.Mat4) {
+			gl.UniformMatrix4fv(depth.Uniform("viewProjection"), 1, false, &viewProjection[0])
+			culler.Reset(viewProjection)
+			chunks.Draw(&culler)
+		})
+
+		program.Use()
+		f.sky.Fog(program)
+		shadows.Bind(program, 1)
+		blocks.Bind(0)
+		culler.Reset(projection.Mul4(camera))
+		chunks.Draw(&culler)
+		gl.Enable(gl.BLEND)
+		gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
+		gl.DepthMask(false)
+		chunks.DrawTranslucent(eye, &culler)
+		gl.DepthMask(true)
+		gl.Disable(gl.BLEND)
+		f.end(c)
+	}
+	return nil
+}
+
+// settle updates the chunks until every chunk in view is generated, meshed and uploaded
+func settle(chunks *world.Manager, eye mgl32.Vec3) error {
+	deadline := time.Now().Add(time.Minute)
+	for time.Now().Before(deadline) {
+		chunks.Update(eye)
+		m := chunks.Metrics()
+		if m.Loaded > 0 && m.QueueDepth == 0 && m.InFlight == 0 && m.ResultBacklog == 0 && m.Uploaded == 0 {
+			return nil
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return fmt.Errorf("chunks weren't meshed after a minute")
+}
+
+// loadBlockTextures packs the block textures into an atlas like the demo does
+func loadBlockTextures(dir string) (*texture.Texture, *texture.Lookup, error) {
+	images, err := texture.LoadDir(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	atlas, lookup, err := texture.PackAtlas(images, 8)
+	if err != nil {
+		return nil, nil, err
+	}
+	options := texture.DefaultOptions()
+	options.Sampler.MagFilter = gl.NEAREST
+	options.Sampler.WrapS, options.Sampler.WrapT = gl.CLAMP_TO_EDGE, gl.CLAMP_TO_EDGE
+	options.Sampler.Anisotropy = 1
+	options.Sampler.MaxLevel = int32(lookup.MaxLevel)
+	t, err := texture.NewArray([]*image.NRGBA{atlas}, options)
+	return t, lookup, err
+}
@@ -0,0 +1,57 @@
+package component
+
+import (
+	"sort"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/tehcyx/goengine/bounds"
+	"github.com/tehcyx/goengine/ecs"
+	"github.com/tehcyx/goengine/material"
+	"github.com/tehcyx/goengine/mesh"
+)
+
+// MeshRenderer draws a mesh with a material at the transform of its entity
+type MeshRenderer struct {
+	Mesh     *mesh.Mesh
+	Material *material.Material // the model matrix is set on the "model" uniform of its program
+}
+
+type draw struct {
+	mesh     *mesh.Mesh
+	material *material.Material
+	model    mgl32.Mat4
+}
+
+// NewRenderSystem creates a system drawing every entity with a Transform and a
+// MeshRenderer, interpolating the transform with the alpha of the frame. Meshes
+// outside the frustum of culler are skipped, a nil culler draws all of them.
+// Draws are sorted by material, so programs, textures and uniforms only change
+// between materials. It has to run on the GL thread.
+func NewRenderSystem(culler *bounds.Culler) ecs.System {
+	var draws []draw
+	return func(w *ecs.World, t ecs.Time) {
+		draws = draws[:0]
+		ecs.Each2(w, func(e ecs.Entity, tr *Transform, r *MeshRenderer) {
+			if r.Mesh == nil || r.Material == nil {
+				return
+			}
+			model := tr.Interpolated(t.Alpha)
+			if !culler.VisibleSphere(r.Mesh.BoundingSphere().Transform(model)) {
+				return
+			}
+			draws = append(draws, draw{r.Mesh, r.Material, model})
+		})
+		sort.SliceStable(draws, func(i, j int) bool {
+			return material.Less(draws[i].material, draws[j].material)
+		})
+
+		var binder material.Binder
+		for i := range draws {
+			d := &draws[i]
+			binder.Apply(d.material)
+			gl.UniformMatrix4fv(d.material.Program.Uniform("model"), 1, false, &d.model[0])
+			d.mesh.Draw()
+		}
+	}
+}
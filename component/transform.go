@@ -0,0 +1,58 @@
+package component
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/tehcyx/goengine/ecs"
+)
+
+// Transform places an entity in the world. It remembers its state before the
+// last update, so rendering can interpolate between fixed updates.
+type Transform struct {
+	Position mgl32.Vec3
+	Rotation mgl32.Quat
+	Scale    mgl32.Vec3
+
+	prevPosition mgl32.Vec3
+	prevRotation mgl32.Quat
+	prevScale    mgl32.Vec3
+}
+
+// NewTransform creates a transform at position without rotation and scaling
+func NewTransform(position mgl32.Vec3) Transform {
+	t := Transform{Position: position, Rotation: mgl32.QuatIdent(), Scale: mgl32.Vec3{1, 1, 1}}
+	t.Snapshot()
+	return t
+}
+
+// Matrix returns the model matrix, scaling first, then rotating and translating
+func (t *Transform) Matrix() mgl32.Mat4 {
+	return compose(t.Position, t.Rotation, t.Scale)
+}
+
+// Snapshot remembers the current state as the one before the next update
+func (t *Transform) Snapshot() {
+	t.prevPosition, t.prevRotation, t.prevScale = t.Position, t.Rotation, t.Scale
+}
+
+// Interpolated returns the model matrix between the state before the last update
+// and the current one, alpha 0 is the previous and 1 the current state
+func (t *Transform) Interpolated(alpha float32) mgl32.Mat4 {
+	position := t.prevPosition.Add(t.Position.Sub(t.prevPosition).Mul(alpha))
+	scale := t.prevScale.Add(t.Scale.Sub(t.prevScale).Mul(alpha))
+	rotation := mgl32.QuatSlerp(t.prevRotation, t.Rotation, alpha)
+	return compose(position, rotation, scale)
+}
+
+func compose(position mgl32.Vec3, rotation mgl32.Quat, scale mgl32.Vec3) mgl32.Mat4 {
+	return mgl32.Translate3D(position.X(), position.Y(), position.Z()).
+		Mul4(rotation.Mat4()).
+		Mul4(mgl32.Scale3D(scale.X(), scale.Y(), scale.Z()))
+}
+
+// SnapshotSystem remembers the state of every transform before the update runs,
+// add it as the first system of the input stage
+func SnapshotSystem(w *ecs.World, t ecs.Time) {
+	ecs.Each(w, func(e ecs.Entity, tr *Transform) {
+		tr.Snapshot()
+	})
+}
@@ -0,0 +1,140 @@
+// Package debug draws lines and wire shapes to see bounds, rays, normals and
+// the like, for a single frame or for a while
+package debug
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/tehcyx/goengine/mesh"
+	"github.com/tehcyx/goengine/shader"
+)
+
+// Drawer collects the lines of the Draw calls and draws all of them with a
+// single buffer and draw call per frame. Call it on the GL thread only.
+type Drawer struct {
+	Normals   bool // whether the caller should pass its meshes to DrawNormals
+	Wireframe bool // draw polygons as outlines between BeginWireframe and EndWireframe
+	ChunkGrid bool // whether the caller should outline the chunks around the camera
+
+	lines    []line
+	vertices []vertex // reused every frame
+	program  *shader.Program
+	vao, vbo uint32
+	capacity int // vertices the buffer holds
+}
+
+type vertex struct {
+	position mgl32.Vec3
+	color    mgl32.Vec4
+}
+
+type line struct {
+	from, to vertex
+	expires  time.Time // zero to draw the line in the next frame only
+}
+
+const stride = 7 * 4 // bytes of a vertex
+
+// New creates a drawer, loading debug.vert and debug.frag from dir. Must be
+// called on the GL thread.
+func New(dir string) (*Drawer, error) {
+	d := new(Drawer)
+	var err error
+	d.program, err = shader.Load(filepath.Join(dir, "debug.vert"), filepath.Join(dir, "debug.frag"))
+	if err != nil {
+		return nil, err
+	}
+	gl.GenVertexArrays(1, &d.vao)
+	gl.GenBuffers(1, &d.vbo)
+	gl.BindVertexArray(d.vao)
+	gl.BindBuffer(gl.ARRAY_BUFFER, d.vbo)
+	gl.EnableVertexAttribArray(uint32(mesh.POSITION_VB))
+	gl.VertexAttribPointer(uint32(mesh.POSITION_VB), 3, gl.FLOAT, false, stride, gl.PtrOffset(0))
+	gl.EnableVertexAttribArray(uint32(mesh.COLOR_VB))
+	gl.VertexAttribPointer(uint32(mesh.COLOR_VB), 4, gl.FLOAT, false, stride, gl.PtrOffset(3*4))
+	gl.BindVertexArray(0)
+	return d, nil
+}
+
+// DrawLine draws a line from one point to another for duration, a duration of
+// 0 draws it in the next frame only. Colors are sRGB like vertex colors.
+func (d *Drawer) DrawLine(from, to mgl32.Vec3, color mgl32.Vec4, duration time.Duration) {
+	l := line{from: vertex{from, color}, to: vertex{to, color}}
+	if duration > 0 {
+		l.expires = time.Now().Add(duration)
+	}
+	d.lines = append(d.lines, l)
+}
+
+// Lines returns the number of lines waiting to be drawn
+func (d *Drawer) Lines() int {
+	return len(d.lines)
+}
+
+// Clear drops all lines, also those with a duration left
+func (d *Drawer) Clear() {
+	d.lines = d.lines[:0]
+}
+
+// Draw draws the lines as seen through viewProjection into the bound
+// framebuffer, depth tested against what is drawn already. Lines of a single
+// frame and expired ones are dropped afterwards.
+func (d *Drawer) Draw(viewProjection mgl32.Mat4) {
+	if len(d.lines) == 0 {
+		return
+	}
+	d.vertices = d.vertices[:0]
+	for _, l := range d.lines {
+		d.vertices = append(d.vertices, l.from, l.to)
+	}
+
+	gl.BindVertexArray(d.vao)
+	gl.BindBuffer(gl.ARRAY_BUFFER, d.vbo)
+	if len(d.vertices) > d.capacity {
+		// leave room so a few more lines don't need a bigger buffer again
+		d.capacity = len(d.vertices) * 3 / 2
+	}
+	// orphan the buffer so the driver doesn't wait for the last frame to finish with it
+	gl.BufferData(gl.ARRAY_BUFFER, d.capacity*stride, nil, gl.STREAM_DRAW)
+	gl.BufferSubData(gl.ARRAY_BUFFER, 0, len(d.vertices)*stride, gl.Ptr(d.vertices))
+
+	d.program.Use()
+	gl.UniformMatrix4fv(d.program.Uniform("viewProjection"), 1, false, &viewProjection[0])
+	gl.Enable(gl.BLEND)
+	gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
+	gl.DrawArrays(gl.LINES, 0, int32(len(d.vertices)))
+	gl.Disable(gl.BLEND)
+	gl.BindVertexArray(0)
+
+	now := time.Now()
+	kept := d.lines[:0]
+	for _, l := range d.lines {
+		if l.expires.After(now) {
+			kept = append(kept, l)
+		}
+	}
+	d.lines = kept
+}
+
+// BeginWireframe draws polygons as their outlines until EndWireframe, if
+// Wireframe is on
+func (d *Drawer) BeginWireframe() {
+	if d.Wireframe {
+		gl.PolygonMode(gl.FRONT_AND_BACK, gl.LINE)
+	}
+}
+
+// EndWireframe fills polygons again
+func (d *Drawer) EndWireframe() {
+	gl.PolygonMode(gl.FRONT_AND_BACK, gl.FILL)
+}
+
+// Delete frees the buffer and the program, must be called on the GL thread
+func (d *Drawer) Delete() {
+	gl.DeleteBuffers(1, &d.vbo)
+	gl.DeleteVertexArrays(1, &d.vao)
+	d.program.Delete()
+}
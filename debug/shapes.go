@@ -0,0 +1,94 @@
+package debug
+
+import (
+	"math"
+	"time"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/tehcyx/goengine/bounds"
+	"github.com/tehcyx/goengine/mesh"
+)
+
+// sphereSegments is the number of lines making up each circle of a sphere
+const sphereSegments = 24
+
+// DrawAABB draws the edges of a box
+func (d *Drawer) DrawAABB(box bounds.AABB, color mgl32.Vec4, duration time.Duration) {
+	var corners [8]mgl32.Vec3
+	for i := range corners {
+		corners[i] = box.Min
+		for axis := 0; axis < 3; axis++ {
+			if i>>uint(axis)&1 != 0 {
+				corners[i][axis] = box.Max[axis]
+			}
+		}
+	}
+	d.drawBox(corners, color, duration)
+}
+
+// drawBox draws the edges between 8 corners numbered like the bits of x, y and z
+func (d *Drawer) drawBox(corners [8]mgl32.Vec3, color mgl32.Vec4, duration time.Duration) {
+	// corners that differ in exactly one bit share an edge
+	for i := 0; i < 8; i++ {
+		for _, bit := range []int{1, 2, 4} {
+			if i&bit == 0 {
+				d.DrawLine(corners[i], corners[i|bit], color, duration)
+			}
+		}
+	}
+}
+
+// DrawSphere draws a sphere as three circles around its axes
+func (d *Drawer) DrawSphere(center mgl32.Vec3, radius float32, color mgl32.Vec4, duration time.Duration) {
+	axes := [3]mgl32.Vec3{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}
+	for i := range axes {
+		u, v := axes[(i+1)%3].Mul(radius), axes[(i+2)%3].Mul(radius)
+		previous := center.Add(u)
+		for s := 1; s <= sphereSegments; s++ {
+			angle := 2 * math.Pi * float64(s) / sphereSegments
+			p := center.Add(u.Mul(float32(math.Cos(angle)))).Add(v.Mul(float32(math.Sin(angle))))
+			d.DrawLine(previous, p, color, duration)
+			previous = p
+		}
+	}
+}
+
+// DrawAxes draws the x, y and z axes of a transform in red, green and blue,
+// size long before the transform scales them
+func (d *Drawer) DrawAxes(transform mgl32.Mat4, size float32, duration time.Duration) {
+	origin := transform.Col(3).Vec3()
+	colors := [3]mgl32.Vec4{{1, 0, 0, 1}, {0, 1, 0, 1}, {0, 0, 1, 1}}
+	for i, color := range colors {
+		d.DrawLine(origin, origin.Add(transform.Col(i).Vec3().Mul(size)), color, duration)
+	}
+}
+
+// DrawFrustum draws the volume seen through a projection*camera matrix, e.g.
+// of another camera or a shadow cascade
+func (d *Drawer) DrawFrustum(viewProjection mgl32.Mat4, color mgl32.Vec4, duration time.Duration) {
+	inverse := viewProjection.Inv()
+	var corners [8]mgl32.Vec3
+	for i := range corners {
+		ndc := mgl32.Vec4{-1, -1, -1, 1}
+		for axis := 0; axis < 3; axis++ {
+			if i>>uint(axis)&1 != 0 {
+				ndc[axis] = 1
+			}
+		}
+		p := inverse.Mul4x1(ndc)
+		corners[i] = p.Vec3().Mul(1 / p.W())
+	}
+	d.drawBox(corners, color, duration)
+}
+
+// DrawNormals draws the normals of the vertices of a mesh placed with model as
+// lines length long. Meshes without normals draw nothing.
+func (d *Drawer) DrawNormals(m *mesh.Mesh, model mgl32.Mat4, length float32, color mgl32.Vec4, duration time.Duration) {
+	positions, normals := m.Normals()
+	// normals turn with the inverse transpose, so scaling doesn't bend them
+	normalMatrix := model.Mat3().Inv().Transpose()
+	for i, n := range normals {
+		p := model.Mul4x1(positions[i].Vec4(1)).Vec3()
+		d.DrawLine(p, p.Add(normalMatrix.Mul3x1(n).Normalize().Mul(length)), color, duration)
+	}
+}
@@ -0,0 +1,52 @@
+package ecs
+
+// Commands queues changes to a world that can't be made while iterating it, like
+// spawning, despawning and adding or removing components. They are applied in
+// the order they were queued.
+type Commands struct {
+	queue []func(w *World)
+}
+
+// Spawn queues spawning an entity, init is called with it once it exists
+func (c *Commands) Spawn(init func(w *World, e Entity)) {
+	c.queue = append(c.queue, func(w *World) {
+		e := w.Spawn()
+		if init != nil {
+			init(w, e)
+		}
+	})
+}
+
+// Despawn queues despawning the entity
+func (c *Commands) Despawn(e Entity) {
+	c.queue = append(c.queue, func(w *World) { w.Despawn(e) })
+}
+
+// Run queues an arbitrary change
+func (c *Commands) Run(fn func(w *World)) {
+	c.queue = append(c.queue, fn)
+}
+
+// Len returns the number of queued commands
+func (c *Commands) Len() int {
+	return len(c.queue)
+}
+
+// Apply runs the queued commands on the world and clears the buffer. Commands
+// queued while applying run in the same call.
+func (c *Commands) Apply(w *World) {
+	for i := 0; i < len(c.queue); i++ {
+		c.queue[i](w)
+	}
+	c.queue = c.queue[:0]
+}
+
+// AddLater queues adding a component to the entity
+func AddLater[T any](c *Commands, e Entity, comp T) {
+	c.queue = append(c.queue, func(w *World) { Add(w, e, comp) })
+}
+
+// RemoveLater queues removing the component of type T from the entity
+func RemoveLater[T any](c *Commands, e Entity) {
+	c.queue = append(c.queue, func(w *World) { Remove[T](w, e) })
+}
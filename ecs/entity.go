@@ -0,0 +1,23 @@
+package ecs
+
+// Entity identifies an entity of a World. The low 32 bits are an index that is
+// reused after the entity is despawned, the high 32 bits the generation of the
+// index, so stale handles to a despawned entity are detected.
+type Entity uint64
+
+// Nil is never a live entity
+const Nil Entity = 0
+
+func newEntity(index, generation uint32) Entity {
+	return Entity(generation)<<32 | Entity(index)
+}
+
+// Index returns the slot of the entity, shared with earlier despawned entities
+func (e Entity) Index() uint32 {
+	return uint32(e)
+}
+
+// Generation returns how often the index of the entity was reused
+func (e Entity) Generation() uint32 {
+	return uint32(e >> 32)
+}
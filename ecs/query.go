@@ -0,0 +1,44 @@
+package ecs
+
+// Queries call fn for every entity with all requested components. Adding or
+// removing components of the queried types from fn isn't allowed, queue such
+// changes in the command buffer of the world instead.
+
+// Each calls fn for every entity with a component of type A
+func Each[A any](w *World, fn func(e Entity, a *A)) {
+	sa := storeOf[A](w)
+	for i := 0; i < len(sa.data); i++ {
+		fn(sa.entities[i], &sa.data[i])
+	}
+}
+
+// Each2 calls fn for every entity with components of type A and B
+func Each2[A, B any](w *World, fn func(e Entity, a *A, b *B)) {
+	sa, sb := storeOf[A](w), storeOf[B](w)
+	// iterate the smaller store and look up the other
+	if sb.len() < sa.len() {
+		for i := 0; i < len(sb.data); i++ {
+			e := sb.entities[i]
+			if a := sa.get(e); a != nil {
+				fn(e, a, &sb.data[i])
+			}
+		}
+		return
+	}
+	for i := 0; i < len(sa.data); i++ {
+		e := sa.entities[i]
+		if b := sb.get(e); b != nil {
+			fn(e, &sa.data[i], b)
+		}
+	}
+}
+
+// Each3 calls fn for every entity with components of type A, B and C
+func Each3[A, B, C any](w *World, fn func(e Entity, a *A, b *B, c *C)) {
+	sc := storeOf[C](w)
+	Each2(w, func(e Entity, a *A, b *B) {
+		if c := sc.get(e); c != nil {
+			fn(e, a, b, c)
+		}
+	})
+}
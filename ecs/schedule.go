@@ -0,0 +1,72 @@
+package ecs
+
+import "time"
+
+// Stage groups systems that run at the same point of a frame
+type Stage int
+
+const (
+	StageInput   Stage = iota // react to input gathered since the last update
+	StageUpdate               // game logic
+	StagePhysics              // movement and collisions
+	StageRender               // drawing, runs once per frame instead of per update
+	NumStages
+)
+
+// Time is passed to every system
+type Time struct {
+	Delta time.Duration // fixed update step, 0 in the render stage
+	Alpha float32       // how far the game is into the next update, only set in the render stage
+}
+
+// System is a function run by a Schedule
+type System func(w *World, t Time)
+
+type namedSystem struct {
+	name string
+	run  System
+}
+
+// Schedule runs systems stage by stage, in the order they were added
+type Schedule struct {
+	stages [NumStages][]namedSystem
+}
+
+// NewSchedule creates a new empty schedule
+func NewSchedule() *Schedule {
+	return new(Schedule)
+}
+
+// Add adds a system to the end of a stage
+func (s *Schedule) Add(stage Stage, name string, system System) {
+	s.stages[stage] = append(s.stages[stage], namedSystem{name, system})
+}
+
+// Systems returns the names of the systems of a stage in the order they run
+func (s *Schedule) Systems(stage Stage) []string {
+	names := make([]string, len(s.stages[stage]))
+	for i, sys := range s.stages[stage] {
+		names[i] = sys.name
+	}
+	return names
+}
+
+// Run runs the systems of a stage, applying the command buffer of the world after each one
+func (s *Schedule) Run(w *World, stage Stage, t Time) {
+	for _, sys := range s.stages[stage] {
+		sys.run(w, t)
+		w.commands.Apply(w)
+	}
+}
+
+// Update runs the input, update and physics stages for a fixed step
+func (s *Schedule) Update(w *World, dt time.Duration) {
+	for stage := StageInput; stage < StageRender; stage++ {
+		s.Run(w, stage, Time{Delta: dt})
+	}
+}
+
+// Render runs the render stage
+func (s *Schedule) Render(w *World, alpha float32) {
+	s.Run(w, StageRender, Time{Alpha: alpha})
+}
@@ -0,0 +1,70 @@
+package ecs
+
+// storage is the type independent part of a component store
+type storage interface {
+	remove(e Entity)
+	has(e Entity) bool
+	len() int
+}
+
+// store keeps the components of one type in a sparse set. Components are packed
+// densely for iteration, the sparse array maps entity indices into it.
+type store[T any] struct {
+	sparse   []int32 // dense position + 1 by entity index, 0 if the entity has no component
+	entities []Entity
+	data     []T
+}
+
+func (s *store[T]) get(e Entity) *T {
+	i := e.Index()
+	if int(i) >= len(s.sparse) || s.sparse[i] == 0 {
+		return nil
+	}
+	d := s.sparse[i] - 1
+	if s.entities[d] != e {
+		return nil
+	}
+	return &s.data[d]
+}
+
+func (s *store[T]) set(e Entity, c T) {
+	if p := s.get(e); p != nil {
+		*p = c
+		return
+	}
+	i := int(e.Index())
+	if i >= len(s.sparse) {
+		s.sparse = append(s.sparse, make([]int32, i+1-len(s.sparse))...)
+	}
+	s.entities = append(s.entities, e)
+	s.data = append(s.data, c)
+	s.sparse[i] = int32(len(s.data))
+}
+
+// remove swaps the last component into the gap, so removing during iteration
+// must go through a command buffer
+func (s *store[T]) remove(e Entity) {
+	if s.get(e) == nil {
+		return
+	}
+	d := s.sparse[e.Index()] - 1
+	last := int32(len(s.data) - 1)
+	if d != last {
+		s.entities[d] = s.entities[last]
+		s.data[d] = s.data[last]
+		s.sparse[s.entities[d].Index()] = d + 1
+	}
+	var zero T
+	s.data[last] = zero
+	s.entities = s.entities[:last]
+	s.data = s.data[:last]
+	s.sparse[e.Index()] = 0
+}
+
+func (s *store[T]) has(e Entity) bool {
+	return s.get(e) != nil
+}
+
+func (s *store[T]) len() int {
+	return len(s.data)
+}
@@ -0,0 +1,103 @@
+package ecs
+
+import "reflect"
+
+// World holds entities and their components. It isn't safe for concurrent use,
+// systems run one after another, see Schedule.
+type World struct {
+	generations []uint32 // current generation by entity index
+	free        []uint32 // indices of despawned entities, reused first
+	alive       int
+	stores      map[reflect.Type]storage
+	commands    *Commands
+}
+
+// NewWorld creates a new empty world
+func NewWorld() *World {
+	w := new(World)
+	w.stores = make(map[reflect.Type]storage)
+	w.commands = new(Commands)
+	// index 0 is reserved so the zero Entity is never alive
+	w.generations = []uint32{0}
+	return w
+}
+
+// Spawn creates a new entity without components
+func (w *World) Spawn() Entity {
+	w.alive++
+	if n := len(w.free); n > 0 {
+		i := w.free[n-1]
+		w.free = w.free[:n-1]
+		return newEntity(i, w.generations[i])
+	}
+	w.generations = append(w.generations, 1)
+	return newEntity(uint32(len(w.generations)-1), 1)
+}
+
+// Despawn removes the entity and all its components, despawning an entity that
+// isn't alive does nothing
+func (w *World) Despawn(e Entity) {
+	if !w.Alive(e) {
+		return
+	}
+	for _, s := range w.stores {
+		s.remove(e)
+	}
+	w.generations[e.Index()]++
+	w.free = append(w.free, e.Index())
+	w.alive--
+}
+
+// Alive reports whether the entity was spawned and not despawned since
+func (w *World) Alive(e Entity) bool {
+	i := e.Index()
+	return i > 0 && int(i) < len(w.generations) && w.generations[i] == e.Generation()
+}
+
+// Len returns the number of live entities
+func (w *World) Len() int {
+	return w.alive
+}
+
+// Commands returns the command buffer of the world, applied after every stage of a Schedule
+func (w *World) Commands() *Commands {
+	return w.commands
+}
+
+func storeOf[T any](w *World) *store[T] {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	s, ok := w.stores[t]
+	if !ok {
+		s = new(store[T])
+		w.stores[t] = s
+	}
+	return s.(*store[T])
+}
+
+// Add sets the component of type T of a live entity, replacing an existing one
+func Add[T any](w *World, e Entity, c T) {
+	if w.Alive(e) {
+		storeOf[T](w).set(e, c)
+	}
+}
+
+// Get returns the component of type T of the entity, nil if it has none. The
+// pointer is only valid until components of type T are added or removed.
+func Get[T any](w *World, e Entity) *T {
+	return storeOf[T](w).get(e)
+}
+
+// Has reports whether the entity has a component of type T
+func Has[T any](w *World, e Entity) bool {
+	return storeOf[T](w).has(e)
+}
+
+// Remove removes the component of type T from the entity
+func Remove[T any](w *World, e Entity) {
+	storeOf[T](w).remove(e)
+}
+
+// Count returns the number of entities with a component of type T
+func Count[T any](w *World) int {
+	return storeOf[T](w).len()
+}
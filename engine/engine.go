@@ -0,0 +1,139 @@
+package engine
+
+import (
+	"fmt"
+	"runtime"
+	"time"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"gopkg.in/veandco/go-sdl2.v0/sdl"
+)
+
+// Game is implemented by games run by the engine. All methods are called on the
+// thread owning the GL context.
+type Game interface {
+	// Init is called once the window and GL context exist
+	Init(e *Engine) error
+	// Update advances the game by a fixed step
+	Update(dt time.Duration)
+	// Render draws a frame, alpha is how far the game is into the next update
+	// from 0 to 1, for interpolating between the last two states
+	Render(alpha float32)
+	// Shutdown is called after the loop stopped, before the window is closed
+	Shutdown()
+}
+
+// EventHandler can be implemented by a Game to receive the SDL events of every frame
+type EventHandler interface {
+	HandleEvent(event sdl.Event)
+}
+
+// Config describes the window and loop of the engine
+type Config struct {
+	Title         string
+	Width, Height int32
+	Step          time.Duration // fixed update step, defaults to 60 updates per second
+	MaxSteps      int           // updates per frame before slowing down, defaults to 5
+}
+
+// Engine opens a window and runs a Game in a fixed update, variable render loop
+type Engine struct {
+	Config
+	Window *sdl.Window
+	Loop   *Loop
+
+	context sdl.GLContext
+	running bool
+	frames  uint64
+}
+
+// New creates a new engine, the window is opened by Run
+func New(config Config) *Engine {
+	e := new(Engine)
+	e.Config = config
+	if e.Step <= 0 {
+		e.Step = time.Second / 60
+	}
+	if e.MaxSteps <= 0 {
+		e.MaxSteps = 5
+	}
+	e.Loop = NewLoop(e.Step, e.MaxSteps)
+	return e
+}
+
+// Run opens the window and runs the game until Quit is called or the window is closed
+func (e *Engine) Run(g Game) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	if err := sdl.Init(sdl.INIT_EVERYTHING); err != nil {
+		return fmt.Errorf("failed to init sdl: %v", err)
+	}
+	defer sdl.Quit()
+
+	sdl.GLSetAttribute(sdl.GL_CONTEXT_PROFILE_MASK, sdl.GL_CONTEXT_PROFILE_CORE)
+	sdl.GLSetAttribute(sdl.GL_CONTEXT_MAJOR_VERSION, 3)
+	sdl.GLSetAttribute(sdl.GL_CONTEXT_MINOR_VERSION, 3)
+
+	sdl.GLSetAttribute(sdl.GL_RED_SIZE, 8)
+	sdl.GLSetAttribute(sdl.GL_GREEN_SIZE, 8)
+	sdl.GLSetAttribute(sdl.GL_BLUE_SIZE, 8)
+	sdl.GLSetAttribute(sdl.GL_ALPHA_SIZE, 8)
+	sdl.GLSetAttribute(sdl.GL_BUFFER_SIZE, 32)
+	sdl.GLSetAttribute(sdl.GL_DOUBLEBUFFER, 1)
+
+	var err error
+	e.Window, err = sdl.CreateWindow(e.Title, sdl.WINDOWPOS_UNDEFINED, sdl.WINDOWPOS_UNDEFINED, e.Width, e.Height, sdl.WINDOW_OPENGL)
+	if err != nil {
+		return fmt.Errorf("failed to create window: %v", err)
+	}
+	defer e.Window.Destroy()
+	e.context, err = e.Window.GLCreateContext()
+	if err != nil {
+		return fmt.Errorf("failed to create context: %v", err)
+	}
+	defer sdl.GLDeleteContext(e.context)
+
+	if err := gl.Init(); err != nil {
+		return fmt.Errorf("failed to init gl: %v", err)
+	}
+	fmt.Println("OpenGL version", gl.GoStr(gl.GetString(gl.VERSION)))
+
+	if err := g.Init(e); err != nil {
+		return err
+	}
+	defer g.Shutdown()
+
+	handler, _ := g.(EventHandler)
+	last := time.Now()
+	e.running = true
+	for e.running {
+		for event := sdl.PollEvent(); event != nil; event = sdl.PollEvent() {
+			if _, ok := event.(*sdl.QuitEvent); ok {
+				e.running = false
+			}
+			if handler != nil {
+				handler.HandleEvent(event)
+			}
+		}
+
+		now := time.Now()
+		alpha := e.Loop.Advance(now.Sub(last), g.Update)
+		last = now
+
+		g.Render(alpha)
+		e.Window.GLSwap()
+		e.frames++
+	}
+	return nil
+}
+
+// Quit stops the loop after the current frame
+func (e *Engine) Quit() {
+	e.running = false
+}
+
+// Frames returns the number of frames rendered so far
+func (e *Engine) Frames() uint64 {
+	return e.frames
+}
@@ -0,0 +1,44 @@
+package engine
+
+import "time"
+
+// Loop turns variable frame times into fixed simulation steps. Time that isn't
+// enough for a whole step carries over to the next frame, the fraction of a step
+// left over is the interpolation alpha for rendering.
+type Loop struct {
+	Step     time.Duration // simulated time per update
+	MaxSteps int           // updates per frame before the loop gives up catching up
+
+	accumulator time.Duration
+	ticks       uint64
+}
+
+// NewLoop creates a new fixed timestep loop
+func NewLoop(step time.Duration, maxSteps int) *Loop {
+	l := new(Loop)
+	l.Step = step
+	l.MaxSteps = maxSteps
+	return l
+}
+
+// Advance adds the time the last frame took and calls update once for every step
+// that is due. Frames longer than MaxSteps steps are clamped, so a hitch slows the
+// simulation down instead of stalling the following frames. It returns how far
+// the simulation is into the next step, from 0 to 1.
+func (l *Loop) Advance(frame time.Duration, update func(dt time.Duration)) float32 {
+	if max := l.Step * time.Duration(l.MaxSteps); frame > max {
+		frame = max
+	}
+	l.accumulator += frame
+	for l.accumulator >= l.Step {
+		update(l.Step)
+		l.accumulator -= l.Step
+		l.ticks++
+	}
+	return float32(l.accumulator) / float32(l.Step)
+}
+
+// Ticks returns the number of updates run so far
+func (l *Loop) Ticks() uint64 {
+	return l.ticks
+}
@@ -1,5 +1,7 @@
 module github.com/tehcyx/goengine
 
+go 1.18
+
 require (
 	github.com/andrebq/assimp v0.0.0-20121130215243-a775ba6866d8
 	github.com/go-gl/gl v0.0.0-20181026044259-55b76b7df9d2
@@ -0,0 +1,125 @@
+// Package golden compares rendered frames to known good images, tolerating the
+// small differences between GL implementations
+package golden
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+)
+
+// Tolerance tells how different two images may be and still match
+type Tolerance struct {
+	Threshold uint8   // channel differences up to it don't count
+	Fraction  float64 // share of the pixels that may differ by more than Threshold
+}
+
+// DefaultTolerance ignores rounding and dithering and lets a few edge pixels differ
+var DefaultTolerance = Tolerance{Threshold: 8, Fraction: 0.005}
+
+// Result is the outcome of a comparison
+type Result struct {
+	Differing int         // pixels differing by more than the threshold
+	Total     int         // pixels compared
+	MaxDelta  uint8       // biggest channel difference
+	Diff      *image.RGBA // the differing pixels in red over a faded copy of the expected image
+}
+
+// Fraction returns the share of the pixels that differ
+func (r Result) Fraction() float64 {
+	if r.Total == 0 {
+		return 0
+	}
+	return float64(r.Differing) / float64(r.Total)
+}
+
+// Match reports whether the images match within the tolerance
+func (r Result) Match(t Tolerance) bool {
+	return r.Fraction() <= t.Fraction
+}
+
+func (r Result) String() string {
+	return fmt.Sprintf("%v of %v pixels (%.3f%%) differ, by up to %v", r.Differing, r.Total, r.Fraction()*100, r.MaxDelta)
+}
+
+// Compare compares got to want pixel by pixel, both have to be the same size
+func Compare(got, want image.Image, threshold uint8) (Result, error) {
+	gb, wb := got.Bounds(), want.Bounds()
+	if gb.Size() != wb.Size() {
+		return Result{}, fmt.Errorf("failed to compare images: got %vx%v, want %vx%v", gb.Dx(), gb.Dy(), wb.Dx(), wb.Dy())
+	}
+	r := Result{Total: gb.Dx() * gb.Dy(), Diff: image.NewRGBA(image.Rect(0, 0, gb.Dx(), gb.Dy()))}
+	for y := 0; y < gb.Dy(); y++ {
+		for x := 0; x < gb.Dx(); x++ {
+			g := color.RGBAModel.Convert(got.At(gb.Min.X+x, gb.Min.Y+y)).(color.RGBA)
+			w := color.RGBAModel.Convert(want.At(wb.Min.X+x, wb.Min.Y+y)).(color.RGBA)
+			delta := maxDelta(g, w)
+			if delta > r.MaxDelta {
+				r.MaxDelta = delta
+			}
+			if delta > threshold {
+				r.Differing++
+				r.Diff.SetRGBA(x, y, color.RGBA{255, 0, 0, 255})
+			} else {
+				r.Diff.SetRGBA(x, y, color.RGBA{w.R / 4, w.G / 4, w.B / 4, 255})
+			}
+		}
+	}
+	return r, nil
+}
+
+func maxDelta(a, b color.RGBA) uint8 {
+	var max uint8
+	for _, d := range [4][2]uint8{{a.R, b.R}, {a.G, b.G}, {a.B, b.B}, {a.A, b.A}} {
+		delta := d[0] - d[1]
+		if d[1] > d[0] {
+			delta = d[1] - d[0]
+		}
+		if delta > max {
+			max = delta
+		}
+	}
+	return max
+}
+
+// Check compares img to the golden PNG at path. With update img becomes the
+// golden image instead.
+func Check(path string, img image.Image, t Tolerance, update bool) (Result, error) {
+	if update {
+		return Result{}, Save(path, img)
+	}
+	want, err := Load(path)
+	if err != nil {
+		return Result{}, err
+	}
+	return Compare(img, want, t.Threshold)
+}
+
+// Load reads a PNG image
+func Load(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %v: %v", path, err)
+	}
+	return img, nil
+}
+
+// Save writes an image as PNG
+func Save(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to encode %v: %v", path, err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,80 @@
+package golden
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// gray returns a w by h image of gray pixels, the first n of them brightened by delta
+func gray(w, h, n int, delta uint8) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i := 0; i < w*h; i++ {
+		v := uint8(100)
+		if i < n {
+			v += delta
+		}
+		img.SetRGBA(i%w, i/w, color.RGBA{v, v, v, 255})
+	}
+	return img
+}
+
+func TestCompareSizeMismatch(t *testing.T) {
+	if _, err := Compare(gray(10, 10, 0, 0), gray(10, 9, 0, 0), 8); err == nil {
+		t.Error("comparing images of different sizes didn't fail")
+	}
+	// only the size counts, not where the bounds start
+	offset := gray(10, 10, 0, 0).SubImage(image.Rect(5, 5, 10, 10))
+	if r, err := Compare(offset, gray(5, 5, 0, 0), 8); err != nil || r.Differing != 0 {
+		t.Errorf("comparing offset bounds: %v, %v", r, err)
+	}
+}
+
+func TestCompareThreshold(t *testing.T) {
+	want := gray(10, 10, 0, 0)
+	tests := []struct {
+		delta     uint8
+		differing int
+	}{
+		{0, 0},
+		{8, 0},
+		{9, 3},
+	}
+	for _, test := range tests {
+		r, err := Compare(gray(10, 10, 3, test.delta), want, 8)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Differing != test.differing || r.Total != 100 || r.MaxDelta != test.delta {
+			t.Errorf("off by %v: got %v, want %v differing pixels", test.delta, r, test.differing)
+		}
+		if got := r.Diff.RGBAAt(0, 0); (got == color.RGBA{255, 0, 0, 255}) != (test.differing > 0) {
+			t.Errorf("off by %v: the diff image marks the first pixel %v", test.delta, got)
+		}
+	}
+}
+
+func TestMatchFraction(t *testing.T) {
+	// 5 of 1000 pixels differ, exactly the default fraction
+	want := gray(100, 10, 0, 0)
+	tests := []struct {
+		n     int
+		match bool
+	}{
+		{0, true},
+		{5, true},
+		{6, false},
+	}
+	for _, test := range tests {
+		r, err := Compare(gray(100, 10, test.n, 50), want, DefaultTolerance.Threshold)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := r.Match(DefaultTolerance); got != test.match {
+			t.Errorf("%v of %v pixels differing: got match %v, want %v", test.n, r.Total, got, test.match)
+		}
+	}
+	if !(Result{}).Match(Tolerance{}) {
+		t.Error("an empty comparison doesn't match")
+	}
+}
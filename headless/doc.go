@@ -0,0 +1,10 @@
+// Package headless creates OpenGL contexts without a window, rendering into an
+// offscreen target. With Mesa's llvmpipe no GPU or display is needed, so the
+// engine can render on servers and in CI.
+package headless
+
+import "errors"
+
+// ErrUnavailable is returned by New when the system can't create a headless
+// context, because it has no EGL or no driver for a GL 4.1 core context
+var ErrUnavailable = errors.New("headless contexts are unavailable")
@@ -0,0 +1,197 @@
+//go:build linux
+
+package headless
+
+/*
+#cgo LDFLAGS: -ldl
+#include <dlfcn.h>
+#include <stdint.h>
+#include <stddef.h>
+
+// libEGL is loaded at runtime, so neither its headers nor the library are
+// needed to build, only to render. The types and constants are the ones of
+// EGL 1.5.
+typedef void *EGLDisplay;
+typedef void *EGLContext;
+typedef void *EGLConfig;
+typedef void *EGLSurface;
+typedef int32_t EGLint;
+typedef unsigned int EGLBoolean;
+typedef unsigned int EGLenum;
+
+#define EGL_NONE 0x3038
+#define EGL_SURFACE_TYPE 0x3033
+#define EGL_PBUFFER_BIT 0x0001
+#define EGL_RENDERABLE_TYPE 0x3040
+#define EGL_OPENGL_BIT 0x0008
+#define EGL_OPENGL_API 0x30A2
+#define EGL_CONTEXT_MAJOR_VERSION 0x3098
+#define EGL_CONTEXT_MINOR_VERSION 0x30FB
+#define EGL_CONTEXT_OPENGL_PROFILE_MASK 0x30FD
+#define EGL_CONTEXT_OPENGL_CORE_PROFILE_BIT 0x0001
+#define EGL_PLATFORM_SURFACELESS_MESA 0x31DD
+
+static void *(*eglGetProcAddress)(const char *);
+static EGLDisplay (*eglGetDisplay)(void *);
+static EGLBoolean (*eglInitialize)(EGLDisplay, EGLint *, EGLint *);
+static EGLBoolean (*eglTerminate)(EGLDisplay);
+static EGLBoolean (*eglChooseConfig)(EGLDisplay, const EGLint *, EGLConfig *, EGLint, EGLint *);
+static EGLBoolean (*eglBindAPI)(EGLenum);
+static EGLContext (*eglCreateContext)(EGLDisplay, EGLConfig, EGLContext, const EGLint *);
+static EGLBoolean (*eglDestroyContext)(EGLDisplay, EGLContext);
+static EGLBoolean (*eglMakeCurrent)(EGLDisplay, EGLSurface, EGLSurface, EGLContext);
+static EGLint (*eglGetError)(void);
+
+// load opens libEGL and looks up the functions used here, it returns 0 if
+// there is no libEGL
+static int load() {
+	if (eglGetError != NULL) {
+		return 1;
+	}
+	void *lib = dlopen("libEGL.so.1", RTLD_NOW | RTLD_GLOBAL);
+	if (lib == NULL) {
+		lib = dlopen("libEGL.so", RTLD_NOW | RTLD_GLOBAL);
+	}
+	if (lib == NULL) {
+		return 0;
+	}
+	if ((eglGetProcAddress = dlsym(lib, "eglGetProcAddress")) == NULL ||
+		(eglGetDisplay = dlsym(lib, "eglGetDisplay")) == NULL ||
+		(eglInitialize = dlsym(lib, "eglInitialize")) == NULL ||
+		(eglTerminate = dlsym(lib, "eglTerminate")) == NULL ||
+		(eglChooseConfig = dlsym(lib, "eglChooseConfig")) == NULL ||
+		(eglBindAPI = dlsym(lib, "eglBindAPI")) == NULL ||
+		(eglCreateContext = dlsym(lib, "eglCreateContext")) == NULL ||
+		(eglDestroyContext = dlsym(lib, "eglDestroyContext")) == NULL ||
+		(eglMakeCurrent = dlsym(lib, "eglMakeCurrent")) == NULL ||
+		(eglGetError = dlsym(lib, "eglGetError")) == NULL) {
+		eglGetError = NULL;
+		dlclose(lib);
+		return 0;
+	}
+	return 1;
+}
+
+// display returns a display that needs no window system, Mesa's surfaceless
+// platform if there is one, the default display otherwise
+static EGLDisplay display() {
+	EGLDisplay (*getPlatformDisplay)(EGLenum, void *, const EGLint *) = eglGetProcAddress("eglGetPlatformDisplayEXT");
+	if (getPlatformDisplay != NULL) {
+		EGLDisplay d = getPlatformDisplay(EGL_PLATFORM_SURFACELESS_MESA, NULL, NULL);
+		if (d != NULL && eglInitialize(d, NULL, NULL)) {
+			return d;
+		}
+	}
+	EGLDisplay d = eglGetDisplay(NULL);
+	if (d != NULL && eglInitialize(d, NULL, NULL)) {
+		return d;
+	}
+	return NULL;
+}
+
+// context creates a GL 4.1 core context and makes it current without a surface
+static EGLContext context(EGLDisplay d) {
+	EGLint configAttributes[] = {EGL_SURFACE_TYPE, EGL_PBUFFER_BIT, EGL_RENDERABLE_TYPE, EGL_OPENGL_BIT, EGL_NONE};
+	EGLConfig config;
+	EGLint count;
+	if (!eglChooseConfig(d, configAttributes, &config, 1, &count) || count == 0) {
+		return NULL;
+	}
+	if (!eglBindAPI(EGL_OPENGL_API)) {
+		return NULL;
+	}
+	EGLint contextAttributes[] = {
+		EGL_CONTEXT_MAJOR_VERSION, 4,
+		EGL_CONTEXT_MINOR_VERSION, 1,
+		EGL_CONTEXT_OPENGL_PROFILE_MASK, EGL_CONTEXT_OPENGL_CORE_PROFILE_BIT,
+		EGL_NONE,
+	};
+	EGLContext c = eglCreateContext(d, config, NULL, contextAttributes);
+	if (c == NULL) {
+		return NULL;
+	}
+	if (!eglMakeCurrent(d, NULL, NULL, c)) {
+		eglDestroyContext(d, c);
+		return NULL;
+	}
+	return c;
+}
+
+static EGLint lastError() {
+	return eglGetError();
+}
+
+static void terminate(EGLDisplay d) {
+	eglTerminate(d);
+}
+
+static void destroy(EGLDisplay d, EGLContext c) {
+	eglMakeCurrent(d, NULL, NULL, NULL);
+	eglDestroyContext(d, c);
+	eglTerminate(d);
+}
+*/
+import "C"
+
+import (
+	"fmt"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/tehcyx/goengine/render"
+)
+
+// Context is a GL context current on the thread that created it, with a
+// target to render frames into
+type Context struct {
+	Target *render.Target // 8 bit RGBA with a depth buffer, bound after New
+
+	display C.EGLDisplay
+	context C.EGLContext
+}
+
+// New creates a context with a target of width by height pixels. GL calls only
+// work on the thread that created the context, lock it first with
+// runtime.LockOSThread.
+func New(width, height int32) (*Context, error) {
+	if C.load() == 0 {
+		return nil, fmt.Errorf("failed to create headless context: %w: libEGL not found", ErrUnavailable)
+	}
+	c := new(Context)
+	c.display = C.display()
+	if c.display == 0 {
+		return nil, fmt.Errorf("failed to create headless context: %w: no EGL display, error 0x%x", ErrUnavailable, C.lastError())
+	}
+	c.context = C.context(c.display)
+	if c.context == nil {
+		err := C.lastError()
+		C.terminate(c.display)
+		return nil, fmt.Errorf("failed to create headless context: %w: no GL 4.1 core context, error 0x%x", ErrUnavailable, err)
+	}
+	if err := gl.Init(); err != nil {
+		c.destroy()
+		return nil, fmt.Errorf("failed to init gl: %v", err)
+	}
+	var err error
+	c.Target, err = render.NewTarget(width, height, 0, gl.RGBA8, true)
+	if err != nil {
+		c.destroy()
+		return nil, err
+	}
+	c.Target.Bind()
+	return c, nil
+}
+
+// Renderer returns the name of the GL implementation, e.g. llvmpipe
+func (c *Context) Renderer() string {
+	return gl.GoStr(gl.GetString(gl.RENDERER))
+}
+
+// Delete frees the target and destroys the context
+func (c *Context) Delete() {
+	c.Target.Delete()
+	c.destroy()
+}
+
+func (c *Context) destroy() {
+	C.destroy(c.display, c.context)
+}
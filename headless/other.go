@@ -0,0 +1,28 @@
+//go:build !linux
+
+package headless
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/tehcyx/goengine/render"
+)
+
+// Context is a GL context with a target to render frames into, only available on Linux
+type Context struct {
+	Target *render.Target
+}
+
+// New fails, headless contexts need EGL
+func New(width, height int32) (*Context, error) {
+	return nil, fmt.Errorf("failed to create headless context: %w: not supported on %v", ErrUnavailable, runtime.GOOS)
+}
+
+// Renderer returns the name of the GL implementation
+func (c *Context) Renderer() string {
+	return ""
+}
+
+// Delete frees the target and destroys the context
+func (c *Context) Delete() {}
@@ -0,0 +1,46 @@
+package light
+
+import (
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/tehcyx/goengine/shader"
+)
+
+// Binding is the uniform buffer binding point of the Lights block
+const Binding = 0
+
+// Buffer is the uniform buffer the lights are uploaded to
+type Buffer struct {
+	id   uint32
+	data []float32
+}
+
+// NewBuffer creates a new light buffer and binds it to Binding, must be called on the GL thread
+func NewBuffer() *Buffer {
+	b := new(Buffer)
+	b.data = make([]float32, bufferSize)
+	gl.GenBuffers(1, &b.id)
+	gl.BindBuffer(gl.UNIFORM_BUFFER, b.id)
+	gl.BufferData(gl.UNIFORM_BUFFER, len(b.data)*4, nil, gl.DYNAMIC_DRAW)
+	gl.BindBuffer(gl.UNIFORM_BUFFER, 0)
+	gl.BindBufferBase(gl.UNIFORM_BUFFER, Binding, b.id)
+	return b
+}
+
+// Update uploads the lights, must be called on the GL thread
+func (b *Buffer) Update(s *Set) {
+	s.pack(b.data)
+	gl.BindBuffer(gl.UNIFORM_BUFFER, b.id)
+	gl.BufferSubData(gl.UNIFORM_BUFFER, 0, len(b.data)*4, gl.Ptr(b.data))
+	gl.BindBuffer(gl.UNIFORM_BUFFER, 0)
+}
+
+// Use connects the Lights block of a program to the buffer, reports whether
+// the program has the block
+func Use(p *shader.Program) bool {
+	return p.BindBlock("Lights", Binding)
+}
+
+// Delete frees the buffer, must be called on the GL thread
+func (b *Buffer) Delete() {
+	gl.DeleteBuffers(1, &b.id)
+}
@@ -0,0 +1,126 @@
+// Package light describes the lights of a scene and uploads them to a uniform
+// buffer shared by all shaders including res/shaders/lighting.glsl
+package light
+
+import (
+	"math"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// Limits of the uniform buffer, lights past them are ignored
+const (
+	MaxPoints = 16
+	MaxSpots  = 8
+)
+
+// Directional is a light infinitely far away, like the sun
+type Directional struct {
+	Direction mgl32.Vec3 // the light travels along it
+	Color     mgl32.Vec3
+	Intensity float32
+}
+
+// Point is a light shining in every direction from a position
+type Point struct {
+	Position  mgl32.Vec3
+	Color     mgl32.Vec3
+	Intensity float32
+	Range     float32 // the light fades out completely at this distance
+}
+
+// Spot is a light shining in a cone, like a flashlight
+type Spot struct {
+	Position   mgl32.Vec3
+	Direction  mgl32.Vec3 // the axis of the cone
+	Color      mgl32.Vec3
+	Intensity  float32
+	Range      float32
+	InnerAngle float32 // radians from the axis where the light starts to fade
+	OuterAngle float32 // radians from the axis where it is gone
+}
+
+// Set is all lights of a scene
+type Set struct {
+	Ambient mgl32.Vec3 // lights every surface evenly
+	Sun     Directional
+	Points  []Point
+	Spots   []Spot
+}
+
+// NewPoint creates a white point light
+func NewPoint(position mgl32.Vec3, rng float32) Point {
+	return Point{Position: position, Color: mgl32.Vec3{1, 1, 1}, Intensity: 1, Range: rng}
+}
+
+// NewSpot creates a white spot light with a cone of 30 degrees that fades over its outer 5
+func NewSpot(position, direction mgl32.Vec3, rng float32) Spot {
+	return Spot{
+		Position:   position,
+		Direction:  direction,
+		Color:      mgl32.Vec3{1, 1, 1},
+		Intensity:  1,
+		Range:      rng,
+		InnerAngle: mgl32.DegToRad(10),
+		OuterAngle: mgl32.DegToRad(15),
+	}
+}
+
+// std140 layout of the Lights block in lighting.glsl, in floats
+const (
+	headerSize = 16 // ambient, sun direction, sun color, counts
+	pointSize  = 8  // position and range, color
+	spotSize   = 12 // position and range, direction and cos outer angle, color and cos inner angle
+	bufferSize = headerSize + MaxPoints*pointSize + MaxSpots*spotSize
+)
+
+// pack writes the lights in the layout of the Lights block
+func (s *Set) pack(data []float32) {
+	for i := range data {
+		data[i] = 0
+	}
+	copy(data[0:], s.Ambient[:])
+	if s.Sun.Direction.Len() > 0 {
+		toSun := s.Sun.Direction.Normalize().Mul(-1)
+		copy(data[4:], toSun[:])
+	}
+	sun := s.Sun.Color.Mul(s.Sun.Intensity)
+	copy(data[8:], sun[:])
+
+	points, spots := len(s.Points), len(s.Spots)
+	if points > MaxPoints {
+		points = MaxPoints
+	}
+	if spots > MaxSpots {
+		spots = MaxSpots
+	}
+	// the counts are ints, GLSL reads the bits as they are
+	data[12] = math.Float32frombits(uint32(points))
+	data[13] = math.Float32frombits(uint32(spots))
+
+	o := headerSize
+	for _, p := range s.Points[:points] {
+		color := p.Color.Mul(p.Intensity)
+		put(data[o:], p.Position, p.Range)
+		put(data[o+4:], color, 0)
+		o += pointSize
+	}
+	o = headerSize + MaxPoints*pointSize
+	for _, sp := range s.Spots[:spots] {
+		dir := sp.Direction
+		if dir.Len() > 0 {
+			dir = dir.Normalize()
+		}
+		color := sp.Color.Mul(sp.Intensity)
+		put(data[o:], sp.Position, sp.Range)
+		put(data[o+4:], dir, float32(math.Cos(float64(sp.OuterAngle))))
+		put(data[o+8:], color, float32(math.Cos(float64(sp.InnerAngle))))
+		o += spotSize
+	}
+}
+
+// put writes a vec4 made of v and w
+func put(data []float32, v mgl32.Vec3, w float32) {
+	copy(data, v[:])
+	data[3] = w
+}
@@ -1,8 +1,12 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"image"
+	"math"
 	"os"
+	"path/filepath"
 	"runtime"
 	"strings"
 	"time"
@@ -10,208 +14,870 @@ import (
 	"github.com/andrebq/assimp/conv"
 	"github.com/go-gl/gl/v4.1-core/gl"
 	"github.com/go-gl/mathgl/mgl32"
+	"github.com/tehcyx/goengine/bounds"
+	"github.com/tehcyx/goengine/capture"
+	"github.com/tehcyx/goengine/component"
+	"github.com/tehcyx/goengine/debug"
+	"github.com/tehcyx/goengine/ecs"
+	"github.com/tehcyx/goengine/engine"
+	"github.com/tehcyx/goengine/light"
+	"github.com/tehcyx/goengine/material"
 	"github.com/tehcyx/goengine/mesh"
-	"github.com/tehcyx/goengine/util"
+	"github.com/tehcyx/goengine/obj"
+	"github.com/tehcyx/goengine/physics"
+	"github.com/tehcyx/goengine/render"
+	"github.com/tehcyx/goengine/scene"
+	"github.com/tehcyx/goengine/shader"
+	"github.com/tehcyx/goengine/shadow"
+	"github.com/tehcyx/goengine/sky"
+	"github.com/tehcyx/goengine/texture"
+	"github.com/tehcyx/goengine/world"
 	"gopkg.in/veandco/go-sdl2.v0/sdl"
 )
 
-func newProgram(vertexShaderSource, fragmentShaderSource string) (uint32, error) {
-	defer util.TimeTrack(time.Now(), "newProgram")
-	vertexShader, err := compileShader(vertexShaderSource, gl.VERTEX_SHADER)
+var uniRoll float32
+var uniYaw float32
+var uniPitch float32
+var uniscale float32 = 0.3
+var yrot float32 = 20.0
+var zrot float32
+var xrot float32
+
+var worldFlag = flag.Bool("world", false, "stream a generated voxel world around the camera")
+var worldDirFlag = flag.String("worlddir", "", "directory the voxel world is loaded from and autosaved to")
+var editFlag = flag.Bool("edit", false, "break blocks with the left and place them with the right mouse button, pick stone, water, lava or torch with 1-4, undo with ctrl+z and redo with ctrl+y")
+var blockArrayFlag = flag.Bool("blockarray", false, "put the block textures into an array texture instead of an atlas")
+var skyboxFlag = flag.String("skybox", "", "directory with the cube map faces px, nx, py, ny, pz and nz to draw as the sky")
+var hourFlag = flag.Float64("hour", 9, "time of day to start at, from 0 to 24")
+var screenshotsFlag = flag.String("screenshots", "screenshots", "directory F12 saves screenshots to")
+var recordFlag = flag.String("record", "recording", "directory F10 records numbered frames to, or a video file like recording.mp4 to encode them into with ffmpeg")
+var walkFlag = flag.Bool("walk", false, "walk through the voxel world with wasd and the mouse, jump with space, toggle flying with f and noclip with n, throw props with g")
+
+var mouseX, mouseY int32
+
+func main() {
+	flag.Parse()
+
+	// printBanner()
+
+	e := engine.New(engine.Config{Title: winTitle, Width: winWidth, Height: winHeight})
+	if err := e.Run(new(demo)); err != nil {
+		panic(err)
+	}
+}
+
+// demo spins the monkey model with a smaller one orbiting it and, with -world,
+// streams a voxel world around the camera
+type demo struct {
+	engine   *engine.Engine
+	entities *ecs.World
+	schedule *ecs.Schedule
+	scene    *scene.Node
+	orbit    *scene.Node
+	sky      *sky.Sky
+
+	textures       *texture.Cache
+	materials      *material.Library
+	lights         light.Set
+	lightBuffer    *light.Buffer
+	flashlight     bool
+	camera         mgl32.Mat4
+	projection     mgl32.Mat4
+	viewProjection mgl32.Mat4
+	culler         bounds.Culler // for the entities
+	chunkCuller    bounds.Culler
+	lastReport     time.Time
+	frame          *render.Target // the scene is rendered into it in HDR before post processing
+	post           *render.Chain
+	debug          *debug.Drawer
+	reader         *capture.Reader
+	screenshot     bool        // take one at the end of the frame
+	screenshots    chan string // the paths of the screenshots written in the background
+	recorder       *capture.Recorder
+	saved          string // the last screenshot or recording, shown in the title for a while
+	savedUntil     time.Time
+
+	voxelWorld      *world.World
+	chunks          *world.Manager
+	history         *world.History
+	fluids          *world.FluidSim
+	placeBlock      world.Block
+	worldProgram    *shader.Program
+	blockTextures   *texture.Texture
+	shadows         *shadow.Map
+	depthProgram    *shader.Program
+	shadowCuller    bounds.Culler
+	showCascades    bool
+	worldProjection mgl32.Mat4
+	worldCamera     mgl32.Mat4
+	cameraPos       mgl32.Vec3
+	selection       world.Hit
+	hasSelection    bool
+	outline         *mesh.Mesh
+	stopAutosave    func()
+
+	player        *physics.Character
+	playerGrid    physics.Grid
+	playerPrev    mgl32.Vec3 // position before the last update, to interpolate the camera
+	playerSpawned bool
+	yaw, pitch    float32
+	props         *physics.Space
+	stopWaking    func()
+}
+
+func (d *demo) Init(e *engine.Engine) error {
+	d.engine = e
+	srcFilepath := "res/models/monkey.obj"
+
+	d.textures = texture.NewCache()
+	d.materials = material.NewLibrary(d.textures, texture.DefaultOptions())
+
+	var err error
+	d.frame, err = render.NewTarget(winWidth, winHeight, 4, gl.RGBA16F, true)
+	if err != nil {
+		return err
+	}
+	d.post, err = render.NewDefaultChain(winWidth, winHeight, "res/shaders/post")
 	if err != nil {
-		return 0, err
+		return err
+	}
+	d.reader = capture.NewReader(winWidth, winHeight, 3)
+	d.screenshots = make(chan string, 8)
+	d.debug, err = debug.New("res/shaders")
+	if err != nil {
+		return err
 	}
 
-	fragmentShader, err := compileShader(fragmentShaderSource, gl.FRAGMENT_SHADER)
+	// Configure the vertex and fragment shaders
+	program, err := d.materials.Program("res/shaders/object.vert", "res/shaders/object.frag")
 	if err != nil {
-		return 0, err
+		return err
 	}
 
-	program := gl.CreateProgram()
+	program.Use()
+
+	d.projection = mgl32.Perspective(mgl32.DegToRad(45.0), float32(winWidth)/winHeight, 0.1, 10.0)
+	gl.UniformMatrix4fv(program.Uniform("projection"), 1, false, &d.projection[0])
+
+	d.camera = mgl32.LookAtV(mgl32.Vec3{3, 3, 3}, mgl32.Vec3{0, 0, 0}, mgl32.Vec3{0, 1, 0})
+	gl.UniformMatrix4fv(program.Uniform("camera"), 1, false, &d.camera[0])
+	gl.Uniform3f(program.Uniform("eye"), 3, 3, 3)
+	d.viewProjection = d.projection.Mul4(d.camera)
+
+	// the time of day sets the sun and the ambient light, a warm lamp and a spot light from above add highlights
+	d.sky, err = sky.New("res/shaders", sky.NewCycle(float32(*hourFlag)))
+	if err != nil {
+		return err
+	}
+	if *skyboxFlag != "" {
+		if d.sky.Cubemap, err = texture.LoadCubemap(*skyboxFlag, texture.DefaultOptions()); err != nil {
+			return err
+		}
+	}
+	d.lightBuffer = light.NewBuffer()
+	light.Use(program)
+	lamp := light.NewPoint(mgl32.Vec3{1.5, 1, 1.5}, 4)
+	lamp.Color, lamp.Intensity = mgl32.Vec3{1, 0.6, 0.3}, 4
+	spot := light.NewSpot(mgl32.Vec3{0, 3, 0}, mgl32.Vec3{0, -1, 0}, 6)
+	spot.Intensity = 6
+	d.lights.Points = append(d.lights.Points, lamp)
+	d.lights.Spots = append(d.lights.Spots, spot)
+
+	monkeyModel := mesh.NewMeshFromFile(srcFilepath)
+	// monkeyModel := mesh.NewMesh("res/models/monkey.obj")
+	cubeModel := mesh.NewMeshFromFile("res/models/cube.obj")
 
-	gl.AttachShader(program, vertexShader)
-	gl.AttachShader(program, fragmentShader)
-	gl.LinkProgram(program)
+	// the monkey has no texture coordinates, its MTL file paints it in a single color
+	mtls, err := obj.LoadMaterials(srcFilepath)
+	if err != nil {
+		return err
+	}
+	if len(mtls) == 0 {
+		return fmt.Errorf("failed to load %v: it uses no material", srcFilepath)
+	}
+	monkeyMaterial, err := d.materials.FromMtl(mtls[0], program)
+	if err != nil {
+		return err
+	}
+	checker, err := d.materials.Load("res/materials/checker.json")
+	if err != nil {
+		return err
+	}
 
-	var status int32
-	gl.GetProgramiv(program, gl.LINK_STATUS, &status)
-	if status == gl.FALSE {
-		var logLength int32
-		gl.GetProgramiv(program, gl.INFO_LOG_LENGTH, &logLength)
+	d.entities = ecs.NewWorld()
+	d.schedule = ecs.NewSchedule()
+	d.schedule.Add(ecs.StageInput, "snapshot", component.SnapshotSystem)
+	d.schedule.Add(ecs.StageUpdate, "spin", spinSystem)
+	d.schedule.Add(ecs.StageRender, "render", component.NewRenderSystem(&d.culler))
+
+	monkey := d.entities.Spawn()
+	ecs.Add(d.entities, monkey, component.NewTransform(mgl32.Vec3{}))
+	ecs.Add(d.entities, monkey, component.MeshRenderer{Mesh: monkeyModel, Material: monkeyMaterial})
+	ecs.Add(d.entities, monkey, spinner{Speed: mgl32.DegToRad(45)})
+
+	cube := d.entities.Spawn()
+	cubeTransform := component.NewTransform(mgl32.Vec3{0, 1.5, 0})
+	cubeTransform.Scale = mgl32.Vec3{0.5, 0.5, 0.5}
+	ecs.Add(d.entities, cube, cubeTransform)
+	ecs.Add(d.entities, cube, component.MeshRenderer{Mesh: cubeModel, Material: checker})
+	ecs.Add(d.entities, cube, spinner{Speed: mgl32.DegToRad(-30)})
+
+	// the moon hangs off a pivot, turning the pivot moves it in a circle
+	d.scene = scene.NewNode("root")
+	d.orbit = scene.NewNode("orbit")
+	moon := scene.NewNode("moon")
+	moon.SetPosition(mgl32.Vec3{1.5, 0.5, 0})
+	moon.SetScale(mgl32.Vec3{0.3, 0.3, 0.3})
+	moon.Drawable = monkeyModel
+	d.scene.AddChild(d.orbit)
+	d.orbit.AddChild(moon)
+	d.schedule.Add(ecs.StageRender, "scene", func(w *ecs.World, t ecs.Time) {
+		monkeyMaterial.Apply()
+		d.scene.Draw(func(n *scene.Node, world mgl32.Mat4) {
+			gl.UniformMatrix4fv(program.Uniform("model"), 1, false, &world[0])
+			n.Drawable.Draw()
+		})
+	})
 
-		log := strings.Repeat("\x00", int(logLength+1))
-		gl.GetProgramInfoLog(program, logLength, nil, gl.Str(log))
+	scene, err := conv.LoadAsset(srcFilepath)
+	if err != nil {
+		return err
+	}
+	scene.Mesh[0].Id()
 
-		return 0, fmt.Errorf("failed to link program: %v", log)
+	d.placeBlock = world.Stone
+	d.cameraPos = mgl32.Vec3{3, 3, 3}
+	if *worldFlag {
+		if err := d.initWorld(); err != nil {
+			return err
+		}
 	}
 
-	gl.DeleteShader(vertexShader)
-	gl.DeleteShader(fragmentShader)
+	// Configure global settings
+	// gl.Enable(gl.DEPTH_TEST)
+	// gl.DepthFunc(gl.LESS)
+
+	// gl.Enable(gl.CULL_FACE)
 
-	return program, nil
+	return nil
 }
 
-func compileShader(source string, shaderType uint32) (uint32, error) {
-	defer util.TimeTrack(time.Now(), "compileShader")
-	shader := gl.CreateShader(shaderType)
+func (d *demo) initWorld() error {
+	var err error
+	d.worldProgram, err = shader.Load("res/shaders/world.vert", "res/shaders/world.frag")
+	if err != nil {
+		return err
+	}
+	d.worldProgram.Use()
+	light.Use(d.worldProgram)
+
+	d.cameraPos = mgl32.Vec3{0, 90, 0}
+	d.worldProjection = mgl32.Perspective(mgl32.DegToRad(worldFov), float32(winWidth)/winHeight, worldNear, 512.0)
+	gl.UniformMatrix4fv(d.worldProgram.Uniform("projection"), 1, false, &d.worldProjection[0])
+	d.worldCamera = mgl32.LookAtV(d.cameraPos, mgl32.Vec3{48, 50, 48}, mgl32.Vec3{0, 1, 0})
+	gl.UniformMatrix4fv(d.worldProgram.Uniform("camera"), 1, false, &d.worldCamera[0])
+
+	d.voxelWorld = world.NewWorld(1337)
+	if *worldDirFlag != "" {
+		if loaded, err := world.Load(*worldDirFlag); err == nil {
+			d.voxelWorld = loaded
+		} else if !os.IsNotExist(err) {
+			return err
+		} else if err := d.voxelWorld.Save(*worldDirFlag); err != nil {
+			// saved right away, edits to chunks unloaded before the first autosave are kept
+			return err
+		}
+		d.stopAutosave = d.voxelWorld.StartAutosave(*worldDirFlag, 30*time.Second)
+	}
+
+	d.chunks = world.NewManager(d.voxelWorld, 8, runtime.NumCPU())
+	// the fog hides the chunks appearing at the edge of the view
+	viewDistance := float32((d.chunks.ViewRadius - 1) * world.ChunkSize)
+	d.sky.FogStart = viewDistance * 2 / 3
+	d.sky.FogDensity = sky.FogDensity(d.sky.FogStart, viewDistance)
+	d.blockTextures, d.chunks.Textures, err = loadBlockTextures("res/textures/blocks", *blockArrayFlag)
+	if err != nil {
+		return err
+	}
+	// meshes without layers, like the outlines, read this instead and stay untextured
+	gl.VertexAttrib1f(uint32(mesh.LAYER_VB), -1)
+	d.history = world.NewHistory(d.voxelWorld, 100)
+	d.fluids = world.NewFluidSim(d.voxelWorld)
+
+	if *walkFlag {
+		d.player = physics.NewCharacter(mgl32.Vec3{0.5, 90, 0.5})
+		d.playerGrid = physics.WorldGrid(d.voxelWorld)
+		d.playerPrev = d.player.Position
+		sdl.SetRelativeMouseMode(true)
+
+		// props resting on changed blocks have to fall again
+		d.props = physics.NewSpace(d.playerGrid)
+		d.stopWaking = d.voxelWorld.Subscribe(func(change world.BlockChange) {
+			p := mgl32.Vec3{float32(change.Pos[0]), float32(change.Pos[1]), float32(change.Pos[2])}
+			d.props.WakeInside(bounds.AABB{Min: p.Sub(mgl32.Vec3{1, 1, 1}), Max: p.Add(mgl32.Vec3{2, 2, 2})})
+		})
+	}
 
-	csources, free := gl.Strs(source)
-	gl.ShaderSource(shader, 1, csources, nil)
-	free()
-	gl.CompileShader(shader)
+	d.depthProgram, err = shader.Load("res/shaders/depth.vert", "res/shaders/depth.frag")
+	if err != nil {
+		return err
+	}
+	d.shadows, err = shadow.New(2048, 4)
+	if err != nil {
+		return err
+	}
 
-	var status int32
-	gl.GetShaderiv(shader, gl.COMPILE_STATUS, &status)
-	if status == gl.FALSE {
-		var logLength int32
-		gl.GetShaderiv(shader, gl.INFO_LOG_LENGTH, &logLength)
+	gl.Enable(gl.DEPTH_TEST)
+	gl.DepthFunc(gl.LESS)
+	return nil
+}
 
-		log := strings.Repeat("\x00", int(logLength+1))
-		gl.GetShaderInfoLog(shader, logLength, nil, gl.Str(log))
+func (d *demo) HandleEvent(event sdl.Event) {
+	switch t := event.(type) {
+	case *sdl.KeyboardEvent:
+		if t.Keysym.Sym == sdl.K_ESCAPE {
+			d.engine.Quit()
+		}
+		if t.Type == sdl.KEYDOWN && d.player != nil {
+			switch t.Keysym.Sym {
+			case sdl.K_f:
+				d.player.Mode = toggleMode(d.player.Mode, physics.Flying)
+			case sdl.K_n:
+				d.player.Mode = toggleMode(d.player.Mode, physics.Noclip)
+			case sdl.K_g:
+				d.throwProp()
+			}
+		}
+		if t.Type == sdl.KEYDOWN && t.Keysym.Sym == sdl.K_l {
+			d.flashlight = !d.flashlight
+		}
+		if t.Type == sdl.KEYDOWN && t.Keysym.Sym == sdl.K_c {
+			d.showCascades = !d.showCascades
+		}
+		if t.Type == sdl.KEYDOWN && t.Keysym.Sym == sdl.K_p {
+			d.sky.Cycle.Paused = !d.sky.Cycle.Paused
+		}
+		if t.Type == sdl.KEYDOWN && t.Keysym.Sym == sdl.K_o {
+			d.sky.Cycle.Skip(1)
+		}
+		if t.Type == sdl.KEYDOWN && t.Keysym.Sym == sdl.K_F12 {
+			d.screenshot = true
+		}
+		if t.Type == sdl.KEYDOWN && t.Keysym.Sym == sdl.K_F10 {
+			d.toggleRecording()
+		}
+		if t.Type == sdl.KEYDOWN {
+			// the overlays that are on are listed in the title, refresh it right away
+			switch t.Keysym.Sym {
+			case sdl.K_F6:
+				d.debug.Normals = !d.debug.Normals
+				d.lastReport = time.Time{}
+			case sdl.K_F7:
+				d.debug.Wireframe = !d.debug.Wireframe
+				d.lastReport = time.Time{}
+			case sdl.K_F8:
+				d.debug.ChunkGrid = !d.debug.ChunkGrid
+				d.lastReport = time.Time{}
+			}
+		}
+		if name, ok := postKeys[t.Keysym.Sym]; ok && t.Type == sdl.KEYDOWN {
+			d.post.Toggle(name)
+			d.lastReport = time.Time{} // show it in the title right away
+		}
+		if t.Type == sdl.KEYDOWN && d.history != nil && *editFlag && t.Keysym.Mod&sdl.KMOD_CTRL != 0 {
+			if t.Keysym.Sym == sdl.K_z {
+				d.history.Undo()
+			} else if t.Keysym.Sym == sdl.K_y {
+				d.history.Redo()
+			}
+		}
+		if t.Type == sdl.KEYDOWN && *editFlag {
+			switch t.Keysym.Sym {
+			case sdl.K_1:
+				d.placeBlock = world.Stone
+			case sdl.K_2:
+				d.placeBlock = world.Water
+			case sdl.K_3:
+				d.placeBlock = world.Lava
+			case sdl.K_4:
+				d.placeBlock = world.Torch
+			}
+		}
+	case *sdl.MouseButtonEvent:
+		if t.Type == sdl.MOUSEBUTTONUP && d.chunks != nil && *editFlag {
+			if !d.hasSelection {
+				break
+			}
+			p, n := d.selection.Pos, d.selection.Normal
+			if t.Button == sdl.BUTTON_LEFT {
+				d.history.SetBlock(p[0], p[1], p[2], world.Air)
+			} else if t.Button == sdl.BUTTON_RIGHT && n != [3]int{} {
+				d.history.SetBlock(p[0]+n[0], p[1]+n[1], p[2]+n[2], d.placeBlock)
+			}
+		}
+	case *sdl.MouseMotionEvent:
+		mouseX, mouseY = t.X, t.Y
+		if d.player != nil {
+			d.yaw += float32(t.XRel) * mouseSensitivity
+			d.pitch = mgl32.Clamp(d.pitch-float32(t.YRel)*mouseSensitivity, -1.5, 1.5)
+		}
 
-		return 0, fmt.Errorf("failed to compile %v: %v", source, log)
+		xrot = float32(t.Y) / 2
+		yrot = float32(t.X) / 2
+		//fmt.Printf("[%dms]MouseMotion \tid:%d \tx:%d \ty:%d \txrel:%d \tyrel:%d\n", t.Timestamp, t.Which, t.X, t.Y, t.XRel, t.YRel)
 	}
+}
 
-	return shader, nil
+func (d *demo) Update(dt time.Duration) {
+	d.schedule.Update(d.entities, dt)
+	d.orbit.Rotate(mgl32.QuatRotate(-float32(dt.Seconds())*mgl32.DegToRad(90), mgl32.Vec3{0, 1, 0}))
+	d.sky.Cycle.Advance(dt)
+	if d.fluids != nil {
+		d.fluids.Advance(dt)
+	}
+	if d.player != nil {
+		d.updatePlayer(dt)
+		d.props.Step(dt)
+	}
 }
 
-var uniRoll float32
-var uniYaw float32
-var uniPitch float32
-var uniscale float32 = 0.3
-var yrot float32 = 20.0
-var zrot float32
-var xrot float32
+const mouseSensitivity = 0.003 // radians per pixel
+
+// updatePlayer moves the player by the keys held down
+func (d *demo) updatePlayer(dt time.Duration) {
+	d.playerPrev = d.player.Position
+	// wait for the ground to be generated before falling through it
+	x, z := int(math.Floor(float64(d.player.Position.X()))), int(math.Floor(float64(d.player.Position.Z())))
+	pos := world.ChunkPosAt(x, z)
+	chunk := d.voxelWorld.Chunk(pos)
+	if chunk == nil {
+		return
+	}
+	if !d.playerSpawned {
+		// start standing on top of the terrain
+		originX, originZ := pos.Origin()
+		d.player.Position[1] = float32(chunk.Height(x-originX, z-originZ) + 1)
+		d.playerPrev = d.player.Position
+		d.playerSpawned = true
+	}
+	keys := sdl.GetKeyboardState()
+	in := physics.Input{Yaw: d.yaw}
+	in.Forward = float32(keys[sdl.SCANCODE_W]) - float32(keys[sdl.SCANCODE_S])
+	in.Right = float32(keys[sdl.SCANCODE_D]) - float32(keys[sdl.SCANCODE_A])
+	in.Jump = keys[sdl.SCANCODE_SPACE] != 0
+	in.Descend = keys[sdl.SCANCODE_LSHIFT] != 0
+	d.player.Update(d.playerGrid, in, dt)
+}
 
-func main() {
+// propShapes are thrown one after the other
+var propShapes = []physics.Shape{
+	physics.Box(mgl32.Vec3{0.25, 0.25, 0.25}),
+	physics.Sphere(0.3),
+	physics.Capsule(0.2, 0.8),
+}
 
-	// printBanner()
+// throwProp throws a prop where the player looks
+func (d *demo) throwProp() {
+	look := lookDirection(d.yaw, d.pitch)
+	shape := propShapes[len(d.props.Bodies())%len(propShapes)]
+	prop := physics.NewBody(shape, d.player.Eye().Add(look), 1)
+	prop.Velocity = d.player.Velocity.Add(look.Mul(10))
+	d.props.Add(prop)
+}
 
-	srcFilepath := "res/models/monkey.obj"
+// lookDirection returns the direction the camera looks in
+func lookDirection(yaw, pitch float32) mgl32.Vec3 {
+	sinYaw, cosYaw := math.Sincos(float64(yaw))
+	sinPitch, cosPitch := math.Sincos(float64(pitch))
+	return mgl32.Vec3{float32(sinYaw * cosPitch), float32(sinPitch), float32(-cosYaw * cosPitch)}
+}
 
-	var window *sdl.Window
-	var context sdl.GLContext
-	var event sdl.Event
-	var running bool
-	var err error
-	runtime.LockOSThread()
-	if err = sdl.Init(sdl.INIT_EVERYTHING); err != nil {
-		panic(err)
+// toggleMode switches between mode and walking
+func toggleMode(current, mode physics.MoveMode) physics.MoveMode {
+	if current == mode {
+		return physics.Walking
 	}
-	defer sdl.Quit()
+	return mode
+}
 
-	sdl.GLSetAttribute(sdl.GL_CONTEXT_PROFILE_MASK, sdl.GL_CONTEXT_PROFILE_CORE)
-	sdl.GLSetAttribute(sdl.GL_CONTEXT_MAJOR_VERSION, 3)
-	sdl.GLSetAttribute(sdl.GL_CONTEXT_MINOR_VERSION, 3)
+// postKeys toggle the post processing passes
+var postKeys = map[sdl.Keycode]string{
+	sdl.K_F1: "bloom",
+	sdl.K_F2: "tonemap",
+	sdl.K_F3: "gamma",
+	sdl.K_F4: "fxaa",
+	sdl.K_F5: "vignette",
+}
 
-	sdl.GLSetAttribute(sdl.GL_RED_SIZE, 8)
-	sdl.GLSetAttribute(sdl.GL_GREEN_SIZE, 8)
-	sdl.GLSetAttribute(sdl.GL_BLUE_SIZE, 8)
-	sdl.GLSetAttribute(sdl.GL_ALPHA_SIZE, 8)
-	sdl.GLSetAttribute(sdl.GL_BUFFER_SIZE, 32)
-	sdl.GLSetAttribute(sdl.GL_DOUBLEBUFFER, 1)
+func (d *demo) Render(alpha float32) {
+	d.frame.Bind()
+	gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
+	if d.chunks != nil {
+		d.updateWorldCamera(alpha)
+		d.sky.Draw(d.worldCamera, d.worldProjection)
+	} else {
+		d.sky.Draw(d.camera, d.projection)
+	}
 
-	window, err = sdl.CreateWindow(winTitle, sdl.WINDOWPOS_UNDEFINED,
-		sdl.WINDOWPOS_UNDEFINED,
-		winWidth, winHeight, sdl.WINDOW_OPENGL)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to create window: %s\n", err)
-		panic(err)
+	// once per frame, the objects, the world and the shadows all use these
+	d.updateLights()
+	d.culler.Reset(d.viewProjection)
+	d.debug.BeginWireframe()
+	d.schedule.Render(d.entities, alpha)
+	d.debug.EndWireframe()
+
+	if d.chunks != nil {
+		d.renderWorld()
 	}
-	defer window.Destroy()
-	context, err = window.GLCreateContext()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to create context: %s\n", err)
-		panic(err)
+	d.drawDebug(alpha)
+	d.post.Run(d.frame, 0, winWidth, winHeight)
+	if d.screenshot {
+		path := capture.ScreenshotPath(*screenshotsFlag, time.Now())
+		capture.Screenshot(d.reader, 0, path, func() { d.screenshots <- path })
+		d.screenshot = false
 	}
-	defer sdl.GLDeleteContext(context)
-
-	// Initialize gl
-	if err := gl.Init(); err != nil {
-		panic(err)
+	if d.recorder != nil {
+		d.reader.Read(0, d.recorder.Add)
+	}
+	d.reader.Poll()
+	select {
+	case path := <-d.screenshots:
+		d.showSaved(path)
+	default:
 	}
 
-	version := gl.GoStr(gl.GetString(gl.VERSION))
-	fmt.Println("OpenGL version", version)
+	if time.Since(d.lastReport) >= time.Second {
+		d.lastReport = time.Now()
+		title := fmt.Sprintf("%s - %d/%d objects drawn", winTitle, d.culler.Drawn, d.culler.Drawn+d.culler.Culled)
+		if d.chunks != nil {
+			title += fmt.Sprintf(", %d/%d chunk meshes drawn", d.chunkCuller.Drawn, d.chunkCuller.Drawn+d.chunkCuller.Culled)
+		}
+		var off []string
+		for _, p := range d.post.Passes {
+			if !p.Enabled {
+				off = append(off, p.Name)
+			}
+		}
+		if len(off) > 0 {
+			title += " - " + strings.Join(off, ", ") + " off"
+		}
+		var on []string
+		for _, overlay := range []struct {
+			name string
+			on   bool
+		}{{"normals", d.debug.Normals}, {"wireframe", d.debug.Wireframe}, {"chunk grid", d.debug.ChunkGrid}} {
+			if overlay.on {
+				on = append(on, overlay.name)
+			}
+		}
+		if len(on) > 0 {
+			title += " - " + strings.Join(on, ", ") + " on"
+		}
+		if d.recorder != nil {
+			title += fmt.Sprintf(" - recording %d frames", d.recorder.Frames())
+		} else if time.Now().Before(d.savedUntil) {
+			title += " - saved " + d.saved
+		}
+		d.engine.Window.SetTitle(title)
+	}
+}
 
-	// Configure the vertex and fragment shaders
-	program, err := newProgram(vertexShader, fragmentShader)
+// toggleRecording starts recording every frame, or stops and finishes the recording
+func (d *demo) toggleRecording() {
+	if d.recorder != nil {
+		d.reader.Flush()
+		frames := d.recorder.Frames()
+		if err := d.recorder.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to record: %s\n", err)
+		} else {
+			d.showSaved(fmt.Sprintf("%s, %d frames", *recordFlag, frames))
+		}
+		d.recorder = nil
+		return
+	}
+	var err error
+	if filepath.Ext(*recordFlag) != "" {
+		// frames come as fast as they are rendered, the video plays them at 60 per second
+		d.recorder, err = capture.RecordTo(capture.FFmpeg(*recordFlag, winWidth, winHeight, 60))
+	} else {
+		d.recorder, err = capture.RecordFrames(*recordFlag)
+	}
 	if err != nil {
-		panic(err)
+		fmt.Fprintf(os.Stderr, "Failed to record: %s\n", err)
+		return
 	}
+	d.lastReport = time.Time{} // show it in the title right away
+}
 
-	gl.UseProgram(program)
+// showSaved shows what was written in the title for a few seconds
+func (d *demo) showSaved(what string) {
+	d.saved = what
+	d.savedUntil = time.Now().Add(5 * time.Second)
+	d.lastReport = time.Time{}
+}
 
-	projection := mgl32.Perspective(mgl32.DegToRad(45.0), float32(winWidth)/winHeight, 0.1, 10.0)
-	projectionUniform := gl.GetUniformLocation(program, gl.Str("projection\x00"))
-	gl.UniformMatrix4fv(projectionUniform, 1, false, &projection[0])
+// spinner turns an entity around the y axis
+type spinner struct {
+	Speed float32 // radians per second
+}
 
-	camera := mgl32.LookAtV(mgl32.Vec3{3, 3, 3}, mgl32.Vec3{0, 0, 0}, mgl32.Vec3{0, 1, 0})
-	cameraUniform := gl.GetUniformLocation(program, gl.Str("camera\x00"))
-	gl.UniformMatrix4fv(cameraUniform, 1, false, &camera[0])
+func spinSystem(w *ecs.World, t ecs.Time) {
+	ecs.Each2(w, func(e ecs.Entity, tr *component.Transform, s *spinner) {
+		turn := mgl32.QuatRotate(s.Speed*float32(t.Delta.Seconds()), mgl32.Vec3{0, 1, 0})
+		tr.Rotation = turn.Mul(tr.Rotation).Normalize()
+	})
+}
 
-	model := mgl32.Ident4()
-	modelUniform := gl.GetUniformLocation(program, gl.Str("model\x00"))
-	gl.UniformMatrix4fv(modelUniform, 1, false, &model[0])
+// loadBlockTextures packs the block textures in dir into an atlas, or into an
+// array texture. Both are uploaded as array textures, an atlas has one layer,
+// so the world shader handles either.
+func loadBlockTextures(dir string, array bool) (*texture.Texture, *texture.Lookup, error) {
+	images, err := texture.LoadDir(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, name := range world.TextureNames() {
+		if _, ok := images[name]; !ok {
+			return nil, nil, fmt.Errorf("failed to load block textures: %v has no %v", dir, name)
+		}
+	}
 
-	textureUniform := gl.GetUniformLocation(program, gl.Str("tex\x00"))
-	gl.Uniform1i(textureUniform, 0)
+	options := texture.DefaultOptions()
+	options.Sampler.MagFilter = gl.NEAREST // keep the pixels crisp up close
+	if array {
+		layers, lookup, err := texture.PackArray(images)
+		if err != nil {
+			return nil, nil, err
+		}
+		t, err := texture.NewArray(layers, options)
+		return t, lookup, err
+	}
 
-	gl.BindFragDataLocation(program, 0, gl.Str("outputColor\x00"))
+	atlas, lookup, err := texture.PackAtlas(images, 8)
+	if err != nil {
+		return nil, nil, err
+	}
+	// repeating or anisotropic filtering would reach past the padding
+	options.Sampler.WrapS, options.Sampler.WrapT = gl.CLAMP_TO_EDGE, gl.CLAMP_TO_EDGE
+	options.Sampler.Anisotropy = 1
+	options.Sampler.MaxLevel = int32(lookup.MaxLevel)
+	if lookup.MaxLevel == 0 {
+		options.Mipmaps = false
+		options.Sampler.MinFilter = gl.LINEAR
+	}
+	t, err := texture.NewArray([]*image.NRGBA{atlas}, options)
+	return t, lookup, err
+}
 
-	monkeyModel := mesh.NewMeshFromFile(srcFilepath)
-	// monkeyModel := mesh.NewMesh("res/models/monkey.obj")
+// updateLights uploads the lights of the time of day, with a flashlight at the
+// camera of the world if it's on
+func (d *demo) updateLights() {
+	d.sky.Cycle.Apply(&d.lights)
+	set := d.lights
+	if d.flashlight && d.chunks != nil {
+		forward := mgl32.Vec3{-d.worldCamera[2], -d.worldCamera[6], -d.worldCamera[10]}
+		flashlight := light.NewSpot(d.cameraPos, forward, 40)
+		flashlight.Color, flashlight.Intensity = mgl32.Vec3{1, 0.95, 0.8}, 50
+		flashlight.InnerAngle, flashlight.OuterAngle = mgl32.DegToRad(15), mgl32.DegToRad(25)
+		set.Spots = append(append([]light.Spot(nil), set.Spots...), flashlight)
+	}
+	d.lightBuffer.Update(&set)
+}
 
-	scene, err := conv.LoadAsset(srcFilepath)
-	if err != nil {
-		panic(err)
+// renderShadows renders the depth of the chunks as seen from the sun into the shadow map
+func (d *demo) renderShadows() {
+	toSun := d.lights.Sun.Direction.Mul(-1)
+	d.shadows.Update(d.worldCamera, mgl32.DegToRad(worldFov), float32(winWidth)/winHeight, worldNear, toSun)
+	d.depthProgram.Use()
+	model := mgl32.Ident4()
+	gl.UniformMatrix4fv(d.depthProgram.Uniform("model"), 1, false, &model[0])
+	d.shadows.Render(func(cascade int, viewProjection mgl32.Mat4) {
+		gl.UniformMatrix4fv(d.depthProgram.Uniform("viewProjection"), 1, false, &viewProjection[0])
+		d.shadowCuller.Reset(viewProjection)
+		d.chunks.Draw(&d.shadowCuller)
+	})
+}
+
+// updateWorldCamera looks through the eyes of the player if there is one
+func (d *demo) updateWorldCamera(alpha float32) {
+	if d.player == nil {
+		return
 	}
-	scene.Mesh[0].Id()
+	eye := d.playerPrev.Add(d.player.Position.Sub(d.playerPrev).Mul(alpha)).Add(mgl32.Vec3{0, d.player.EyeHeight, 0})
+	d.cameraPos = eye
+	d.worldCamera = mgl32.LookAtV(eye, eye.Add(lookDirection(d.yaw, d.pitch)), mgl32.Vec3{0, 1, 0})
+}
 
-	// Configure global settings
-	// gl.Enable(gl.DEPTH_TEST)
-	// gl.DepthFunc(gl.LESS)
+func (d *demo) renderWorld() {
+	d.worldProgram.Use()
+	pickX, pickY := float32(mouseX), float32(winHeight-mouseY)
+	if d.player != nil {
+		// pick what's in the middle of the screen
+		pickX, pickY = winWidth/2, winHeight/2
+	}
+	gl.UniformMatrix4fv(d.worldProgram.Uniform("camera"), 1, false, &d.worldCamera[0])
+	gl.Uniform3fv(d.worldProgram.Uniform("eye"), 1, &d.cameraPos[0])
+	d.sky.Fog(d.worldProgram)
+	d.chunks.Update(d.cameraPos)
+	d.renderShadows()
+	d.worldProgram.Use()
+	d.shadows.Bind(d.worldProgram, 1)
+	showCascades := int32(0)
+	if d.showCascades {
+		showCascades = 1
+	}
+	gl.Uniform1i(d.worldProgram.Uniform("showCascades"), showCascades)
+	d.blockTextures.Bind(0)
+	d.chunkCuller.Reset(d.worldProjection.Mul4(d.worldCamera))
+	d.debug.BeginWireframe()
+	d.chunks.Draw(&d.chunkCuller)
+	d.debug.EndWireframe()
+
+	// highlight the block under the mouse cursor
+	near, errNear := mgl32.UnProject(mgl32.Vec3{pickX, pickY, 0}, d.worldCamera, d.worldProjection, 0, 0, winWidth, winHeight)
+	far, errFar := mgl32.UnProject(mgl32.Vec3{pickX, pickY, 1}, d.worldCamera, d.worldProjection, 0, 0, winWidth, winHeight)
+	hit, ok := world.Hit{}, false
+	if errNear == nil && errFar == nil {
+		hit, ok = d.voxelWorld.Raycast(near, far.Sub(near), 256)
+	}
+	if ok != d.hasSelection || hit.Pos != d.selection.Pos {
+		if d.outline != nil {
+			d.outline.Delete()
+			d.outline = nil
+		}
+		if ok {
+			d.outline = mesh.NewMeshFromData(world.OutlineData(hit.Pos, mgl32.Vec4{0, 0, 0, 1}))
+		}
+	}
+	d.selection, d.hasSelection = hit, ok
+	if d.outline != nil {
+		d.outline.Draw()
+	}
+	if d.props != nil {
+		d.drawProps()
+	}
 
-	// gl.Enable(gl.CULL_FACE)
+	// water last, blended over everything behind it
+	gl.Enable(gl.BLEND)
+	gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
+	gl.DepthMask(false)
+	d.debug.BeginWireframe()
+	d.chunks.DrawTranslucent(d.cameraPos, &d.chunkCuller)
+	d.debug.EndWireframe()
+	gl.DepthMask(true)
+	gl.Disable(gl.BLEND)
+}
 
-	gl.ClearColor(0.0, 1.0, 0.8, 1.0)
-	gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
+// drawProps outlines the bounds of the props, white while they move and grey once they sleep
+func (d *demo) drawProps() {
+	for _, prop := range d.props.Bodies() {
+		color := mgl32.Vec4{1, 1, 1, 1}
+		if prop.Sleeping() {
+			color = mgl32.Vec4{0.5, 0.5, 0.5, 1}
+		}
+		d.debug.DrawAABB(prop.Bounds(), color, 0)
+	}
+}
 
-	running = true
-	for running {
-		for event = sdl.PollEvent(); event != nil; event = sdl.PollEvent() {
-			switch t := event.(type) {
-			case *sdl.QuitEvent:
-				running = false
-			case *sdl.KeyboardEvent:
-				if t.Keysym.Sym == sdl.K_ESCAPE {
-					running = false
+// drawDebug adds the debug overlays that are on and draws the debug lines of the frame
+func (d *demo) drawDebug(alpha float32) {
+	viewProjection := d.viewProjection
+	if d.chunks != nil {
+		viewProjection = d.worldProjection.Mul4(d.worldCamera)
+		if d.debug.ChunkGrid {
+			d.drawChunkGrid()
+		}
+		if d.debug.Normals {
+			// only around the camera, all chunks would be millions of lines
+			here := d.cameraChunk()
+			d.chunks.Meshes(func(pos world.ChunkPos, opaque, translucent *mesh.Mesh) {
+				if pos.X < here.X-1 || pos.X > here.X+1 || pos.Z < here.Z-1 || pos.Z > here.Z+1 {
+					return
 				}
-			case *sdl.MouseButtonEvent:
-				if t.Type == sdl.MOUSEBUTTONUP {
-					if t.Button == sdl.BUTTON_LEFT {
-						fmt.Printf("Left Mouse %d\n", 1)
-					} else if t.Button == sdl.BUTTON_RIGHT {
-						fmt.Printf("Right Mouse %d\n", 1)
+				for _, m := range []*mesh.Mesh{opaque, translucent} {
+					if m != nil {
+						d.debug.DrawNormals(m, mgl32.Ident4(), 0.25, mgl32.Vec4{1, 0, 1, 1}, 0)
 					}
 				}
-			case *sdl.MouseMotionEvent:
-
-				xrot = float32(t.Y) / 2
-				yrot = float32(t.X) / 2
-				//fmt.Printf("[%dms]MouseMotion \tid:%d \tx:%d \ty:%d \txrel:%d \tyrel:%d\n", t.Timestamp, t.Which, t.X, t.Y, t.XRel, t.YRel)
-			}
+			})
 		}
+	} else if d.debug.Normals {
+		ecs.Each2(d.entities, func(e ecs.Entity, tr *component.Transform, r *component.MeshRenderer) {
+			if r.Mesh != nil {
+				d.debug.DrawNormals(r.Mesh, tr.Interpolated(alpha), 0.1, mgl32.Vec4{1, 0, 1, 1}, 0)
+			}
+		})
+	}
+	d.debug.Draw(viewProjection)
+}
 
-		gl.ClearColor(0.0, 1.0, 0.8, 1.0)
-		gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
-
-		gl.UseProgram(program)
-		gl.UniformMatrix4fv(modelUniform, 1, false, &model[0])
+// cameraChunk returns the chunk the world camera is in
+func (d *demo) cameraChunk() world.ChunkPos {
+	return world.ChunkPosAt(int(math.Floor(float64(d.cameraPos.X()))), int(math.Floor(float64(d.cameraPos.Z()))))
+}
 
-		monkeyModel.Draw()
+// drawChunkGrid outlines the chunks around the camera, the one it's in in
+// yellow with a line around it every ChunkSize blocks up
+func (d *demo) drawChunkGrid() {
+	const radius = 2
+	here := d.cameraChunk()
+	column := func(pos world.ChunkPos) bounds.AABB {
+		x, z := pos.Origin()
+		return bounds.AABB{
+			Min: mgl32.Vec3{float32(x), 0, float32(z)},
+			Max: mgl32.Vec3{float32(x + world.ChunkSize), world.ChunkHeight, float32(z + world.ChunkSize)},
+		}
+	}
+	// the camera's chunk first, the edges it shares with the others are drawn only once
+	yellow := mgl32.Vec4{1, 0.9, 0.2, 1}
+	box := column(here)
+	for y := 0; y <= world.ChunkHeight; y += world.ChunkSize {
+		layer := box
+		layer.Min[1], layer.Max[1] = float32(y), float32(y)
+		d.debug.DrawAABB(layer, yellow, 0)
+	}
+	d.debug.DrawAABB(box, yellow, 0)
+	for x := here.X - radius; x <= here.X+radius; x++ {
+		for z := here.Z - radius; z <= here.Z+radius; z++ {
+			if pos := (world.ChunkPos{X: x, Z: z}); pos != here {
+				d.debug.DrawAABB(column(pos), mgl32.Vec4{0.2, 0.5, 1, 1}, 0)
+			}
+		}
+	}
+}
 
-		window.GLSwap()
+func (d *demo) Shutdown() {
+	if d.recorder != nil {
+		d.toggleRecording()
+	}
+	d.reader.Flush()
+	d.reader.Delete()
+	d.textures.Delete()
+	d.materials.Delete()
+	d.lightBuffer.Delete()
+	if d.sky.Cubemap != nil {
+		d.sky.Cubemap.Delete()
+	}
+	d.sky.Delete()
+	d.post.Delete()
+	d.frame.Delete()
+	d.debug.Delete()
+	if d.chunks == nil {
+		return
+	}
+	if d.stopWaking != nil {
+		d.stopWaking()
+	}
+	d.fluids.Close()
+	d.chunks.Close()
+	d.blockTextures.Delete()
+	d.shadows.Delete()
+	d.depthProgram.Delete()
+	if d.stopAutosave != nil {
+		d.stopAutosave()
+		if err := d.voxelWorld.Save(*worldDirFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to save world: %s\n", err)
+		}
 	}
 }
 
@@ -219,28 +885,10 @@ const (
 	winTitle  = "OpenGL Shader"
 	winWidth  = 800
 	winHeight = 600
-)
 
-var vertexShader = `
-#version 330
-uniform mat4 projection;
-uniform mat4 camera;
-uniform mat4 model;
-in vec3 vert;
-in vec2 vertTexCoord;
-void main() {
-    gl_Position = projection * camera * model * vec4(vert, 1);
-}
-` + "\x00"
-
-var fragmentShader = `
-#version 330
-uniform sampler2D tex;
-out vec4 outputColor;
-void main() {
-    outputColor = vec4(0.3, 0.5, 0.8, 1.0);
-}
-` + "\x00"
+	worldFov  = 60.0 // vertical field of view of the world camera in degrees
+	worldNear = 0.1
+)
 
 func printBanner() {
 	fmt.Println()
@@ -0,0 +1,198 @@
+package material
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/tehcyx/goengine/obj"
+	"github.com/tehcyx/goengine/shader"
+	"github.com/tehcyx/goengine/texture"
+)
+
+// file is the JSON form of a material. Paths are relative to the file.
+//
+//	{
+//		"name": "checker",
+//		"vertex": "../shaders/object.vert",
+//		"fragment": "../shaders/object.frag",
+//		"textures": {"diffuseMap": "../textures/checker.png"},
+//		"params": {"diffuse": [1, 1, 1, 1], "shininess": 16}
+//	}
+//
+// Numbers become float32 uniforms, arrays of 2, 3, 4 or 16 numbers vectors or a
+// matrix, booleans int32 uniforms of 0 or 1.
+type file struct {
+	Name     string                 `json:"name"`
+	Vertex   string                 `json:"vertex"`
+	Fragment string                 `json:"fragment"`
+	Textures map[string]string      `json:"textures"`
+	Params   map[string]interface{} `json:"params"`
+}
+
+type programKey struct {
+	vertex, fragment string
+}
+
+// Library loads materials and shares the programs and textures between them.
+// It must only be used from the GL thread.
+type Library struct {
+	TextureOptions texture.Options // used for every texture a material loads
+
+	textures  *texture.Cache
+	programs  map[programKey]*shader.Program
+	materials map[string]*Material
+	white     *texture.Texture
+}
+
+// NewLibrary creates a new library loading textures through the cache
+func NewLibrary(textures *texture.Cache, options texture.Options) *Library {
+	l := new(Library)
+	l.TextureOptions = options
+	l.textures = textures
+	l.programs = make(map[programKey]*shader.Program)
+	l.materials = make(map[string]*Material)
+	return l
+}
+
+// Program returns the program built from the shader files, building it on first use
+func (l *Library) Program(vertexPath, fragmentPath string) (*shader.Program, error) {
+	key := programKey{filepath.Clean(vertexPath), filepath.Clean(fragmentPath)}
+	if p, ok := l.programs[key]; ok {
+		return p, nil
+	}
+	p, err := shader.Load(key.vertex, key.fragment)
+	if err != nil {
+		return nil, err
+	}
+	l.programs[key] = p
+	return p, nil
+}
+
+// Load returns the material of a JSON file, loading it on first use
+func (l *Library) Load(path string) (*Material, error) {
+	path = filepath.Clean(path)
+	if m, ok := l.materials[path]; ok {
+		return m, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to load material %v: %v", path, err)
+	}
+	if f.Name == "" {
+		f.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+
+	dir := filepath.Dir(path)
+	program, err := l.Program(filepath.Join(dir, f.Vertex), filepath.Join(dir, f.Fragment))
+	if err != nil {
+		return nil, err
+	}
+	m := New(f.Name, program)
+	for sampler, texturePath := range f.Textures {
+		t, err := l.textures.Get(filepath.Join(dir, texturePath), l.TextureOptions)
+		if err != nil {
+			return nil, err
+		}
+		m.Textures[sampler] = t
+	}
+	for name, value := range f.Params {
+		v, err := paramValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load material %v: %v: %v", path, name, err)
+		}
+		m.Params[name] = v
+	}
+	l.materials[path] = m
+	return m, nil
+}
+
+// FromMtl creates a material drawing with program from an MTL material. The
+// diffuse color and opacity go to the vec4 "diffuse", Ka to the vec3 "ambient",
+// Ks to the vec3 "specular", Ns to the float "shininess" and map_Kd to the
+// sampler "diffuseMap", which gets a white texture if there is no map.
+func (l *Library) FromMtl(mtl *obj.Mtl, program *shader.Program) (*Material, error) {
+	m := New(mtl.Name, program)
+	m.Params["diffuse"] = mtl.Diffuse.Vec4(mtl.Opacity)
+	m.Params["ambient"] = mtl.Ambient
+	m.Params["specular"] = mtl.Specular
+	m.Params["shininess"] = mtl.Shininess
+	if mtl.DiffuseMap == "" {
+		m.Textures["diffuseMap"] = l.White()
+		return m, nil
+	}
+	t, err := l.textures.Get(mtl.DiffuseMap, l.TextureOptions)
+	if err != nil {
+		return nil, err
+	}
+	m.Textures["diffuseMap"] = t
+	return m, nil
+}
+
+// White returns a 1x1 white texture for samplers that have nothing to sample
+func (l *Library) White() *texture.Texture {
+	if l.white == nil {
+		pixel := image.NewNRGBA(image.Rect(0, 0, 1, 1))
+		pixel.Set(0, 0, color.White)
+		l.white = texture.New(pixel, l.TextureOptions)
+	}
+	return l.white
+}
+
+// Delete frees the programs and the white texture, the textures of the cache stay
+func (l *Library) Delete() {
+	for key, p := range l.programs {
+		p.Delete()
+		delete(l.programs, key)
+	}
+	if l.white != nil {
+		l.white.Delete()
+		l.white = nil
+	}
+	l.materials = make(map[string]*Material)
+}
+
+// paramValue converts a decoded JSON value to the type of its uniform
+func paramValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case float64:
+		return float32(v), nil
+	case bool:
+		if v {
+			return int32(1), nil
+		}
+		return int32(0), nil
+	case []interface{}:
+		floats := make([]float32, len(v))
+		for i, e := range v {
+			f, ok := e.(float64)
+			if !ok {
+				return nil, fmt.Errorf("%v isn't a number", e)
+			}
+			floats[i] = float32(f)
+		}
+		switch len(floats) {
+		case 2:
+			return mgl32.Vec2{floats[0], floats[1]}, nil
+		case 3:
+			return mgl32.Vec3{floats[0], floats[1], floats[2]}, nil
+		case 4:
+			return mgl32.Vec4{floats[0], floats[1], floats[2], floats[3]}, nil
+		case 16:
+			var m mgl32.Mat4
+			copy(m[:], floats)
+			return m, nil
+		}
+		return nil, fmt.Errorf("arrays need 2, 3, 4 or 16 numbers, not %v", len(floats))
+	}
+	return nil, fmt.Errorf("unsupported value %v", value)
+}
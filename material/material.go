@@ -0,0 +1,120 @@
+// Package material ties shader programs, textures and uniform values together
+package material
+
+import (
+	"fmt"
+	"sort"
+	"sync/atomic"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/tehcyx/goengine/shader"
+	"github.com/tehcyx/goengine/texture"
+)
+
+var nextID uint32
+
+// Material is how a mesh is drawn: the program, the textures bound to its
+// sampler uniforms and the values of its other uniforms
+type Material struct {
+	Name     string
+	Program  *shader.Program
+	Textures map[string]*texture.Texture // by sampler uniform, bound to units in the order of the names
+	Params   map[string]interface{}      // by uniform: float32, int32, mgl32.Vec2, mgl32.Vec3, mgl32.Vec4 or mgl32.Mat4
+
+	id uint32
+}
+
+// New creates a new material without textures and parameters
+func New(name string, program *shader.Program) *Material {
+	m := new(Material)
+	m.Name = name
+	m.Program = program
+	m.Textures = make(map[string]*texture.Texture)
+	m.Params = make(map[string]interface{})
+	m.id = atomic.AddUint32(&nextID, 1)
+	return m
+}
+
+// Less orders materials by program first, so drawing in that order switches
+// programs as rarely as possible
+func Less(a, b *Material) bool {
+	if a.Program.ID != b.Program.ID {
+		return a.Program.ID < b.Program.ID
+	}
+	return a.id < b.id
+}
+
+// Set sets the value of a uniform, see Params for the types it may have
+func (m *Material) Set(name string, value interface{}) error {
+	switch value.(type) {
+	case float32, int32, mgl32.Vec2, mgl32.Vec3, mgl32.Vec4, mgl32.Mat4:
+		m.Params[name] = value
+		return nil
+	}
+	return fmt.Errorf("failed to set %v of material %v: unsupported type %T", name, m.Name, value)
+}
+
+// Apply makes the program current, binds the textures and sets the uniforms,
+// must be called on the GL thread
+func (m *Material) Apply() {
+	m.Program.Use()
+	m.bind()
+}
+
+// bind binds the textures and sets the uniforms of a material whose program is
+// already in use
+func (m *Material) bind() {
+	samplers := make([]string, 0, len(m.Textures))
+	for name := range m.Textures {
+		samplers = append(samplers, name)
+	}
+	sort.Strings(samplers)
+	for unit, name := range samplers {
+		m.Textures[name].Bind(uint32(unit))
+		gl.Uniform1i(m.Program.Uniform(name), int32(unit))
+	}
+
+	for name, value := range m.Params {
+		location := m.Program.Uniform(name)
+		switch v := value.(type) {
+		case float32:
+			gl.Uniform1f(location, v)
+		case int32:
+			gl.Uniform1i(location, v)
+		case mgl32.Vec2:
+			gl.Uniform2fv(location, 1, &v[0])
+		case mgl32.Vec3:
+			gl.Uniform3fv(location, 1, &v[0])
+		case mgl32.Vec4:
+			gl.Uniform4fv(location, 1, &v[0])
+		case mgl32.Mat4:
+			gl.UniformMatrix4fv(location, 1, false, &v[0])
+		}
+	}
+}
+
+// Binder applies materials one after another, skipping the program switch when
+// consecutive materials share it and all work when they're the same
+type Binder struct {
+	current *Material
+	program uint32
+}
+
+// Apply applies m unless it was the last material applied
+func (b *Binder) Apply(m *Material) {
+	if m == b.current {
+		return
+	}
+	if b.current == nil || m.Program.ID != b.program {
+		m.Program.Use()
+		b.program = m.Program.ID
+	}
+	m.bind()
+	b.current = m
+}
+
+// Reset forgets the last material, call it when something else changed the GL state
+func (b *Binder) Reset() {
+	b.current = nil
+}
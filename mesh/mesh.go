@@ -5,6 +5,7 @@ import (
 
 	"github.com/go-gl/gl/v4.1-core/gl"
 	"github.com/go-gl/mathgl/mgl32"
+	"github.com/tehcyx/goengine/bounds"
 	"github.com/tehcyx/goengine/obj"
 	"github.com/tehcyx/goengine/util"
 )
@@ -14,7 +15,10 @@ const (
 	TEXCOORD_VB int = 1
 	NORMAL_VB   int = 2
 	INDEX_VB    int = 3
-	NUM_BUFFERS int = 4
+	COLOR_VB    int = 4
+	LIGHT_VB    int = 5
+	LAYER_VB    int = 6
+	NUM_BUFFERS int = 7
 )
 
 type Vertex struct {
@@ -23,11 +27,27 @@ type Vertex struct {
 	normal   mgl32.Vec3
 }
 
+// Data holds vertex data built on the CPU, e.g. by a mesher, ready to be uploaded.
+// Attributes other than Positions are optional.
+type Data struct {
+	Positions []mgl32.Vec3
+	TexCoords []mgl32.Vec2
+	Normals   []mgl32.Vec3
+	Colors    []mgl32.Vec4
+	Lights    []mgl32.Vec2 // sky and block light from 0 to 1, baked by voxel meshers
+	Layers    []float32    // array texture layer of every vertex
+	Indices   []uint32
+	Lines     bool // draw the indices as line segments instead of triangles
+}
+
 type Mesh struct {
 	vao        uint32              // vertex array object
 	vbo        [NUM_BUFFERS]uint32 // vertex buffer object
 	model      *obj.IndexedModel
 	quickmodel *obj.QuickObjModel
+	data       *Data
+	box        bounds.AABB   // around the vertices in model space
+	sphere     bounds.Sphere // around the vertices in model space
 }
 
 func NewMesh(path string) *Mesh {
@@ -47,6 +67,13 @@ func NewMeshFromFile(file string) *Mesh {
 	return m
 }
 
+// NewMeshFromData creates a new Mesh from vertex data, must be called on the GL thread
+func NewMeshFromData(data *Data) *Mesh {
+	m := new(Mesh)
+	m.dataInit(data)
+	return m
+}
+
 func (m *Mesh) create(vertices []Vertex, indices []int) {
 	defer util.TimeTrack(time.Now(), "Mesh create -> init")
 	model := new(obj.IndexedModel)
@@ -68,6 +95,7 @@ func (m *Mesh) create(vertices []Vertex, indices []int) {
 func (m *Mesh) init(model *obj.IndexedModel) {
 	defer util.TimeTrack(time.Now(), "Mesh init")
 	m.model = model
+	m.setBounds(model.Positions)
 
 	gl.GenVertexArrays(1, &m.vao)
 	gl.BindVertexArray(m.vao)
@@ -89,8 +117,13 @@ func (m *Mesh) init(model *obj.IndexedModel) {
 	gl.EnableVertexAttribArray(uint32(NORMAL_VB))
 	gl.VertexAttribPointer(uint32(NORMAL_VB), 3, gl.FLOAT, false, 0, gl.PtrOffset(0))
 
+	// GL wants 32 bit indices, the model has ints
+	indices := make([]uint32, len(m.model.Indices))
+	for i, index := range m.model.Indices {
+		indices[i] = uint32(index)
+	}
 	gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, m.vbo[INDEX_VB])
-	gl.BufferData(gl.ELEMENT_ARRAY_BUFFER, len(m.model.Indices)*3*4, gl.Ptr(m.model.Indices), gl.STATIC_DRAW)
+	gl.BufferData(gl.ELEMENT_ARRAY_BUFFER, len(indices)*4, gl.Ptr(indices), gl.STATIC_DRAW)
 
 	gl.BindVertexArray(0)
 }
@@ -98,6 +131,7 @@ func (m *Mesh) init(model *obj.IndexedModel) {
 func (m *Mesh) quickInit(model *obj.QuickObjModel) {
 	defer util.TimeTrack(time.Now(), "Mesh quickInit")
 	m.quickmodel = model
+	m.setBounds(model.Vertices)
 
 	gl.GenVertexArrays(1, &m.vao)
 	gl.BindVertexArray(m.vao)
@@ -126,8 +160,105 @@ func (m *Mesh) quickInit(model *obj.QuickObjModel) {
 	gl.BindVertexArray(0)
 }
 
+func (m *Mesh) dataInit(data *Data) {
+	m.data = data
+	m.setBounds(data.Positions)
+
+	gl.GenVertexArrays(1, &m.vao)
+	gl.BindVertexArray(m.vao)
+
+	gl.GenBuffers(int32(NUM_BUFFERS), &m.vbo[0])
+
+	gl.BindBuffer(gl.ARRAY_BUFFER, m.vbo[POSITION_VB])
+	gl.BufferData(gl.ARRAY_BUFFER, len(data.Positions)*3*4, gl.Ptr(data.Positions), gl.STATIC_DRAW)
+	gl.EnableVertexAttribArray(uint32(POSITION_VB))
+	gl.VertexAttribPointer(uint32(POSITION_VB), 3, gl.FLOAT, false, 0, gl.PtrOffset(0))
+
+	if len(data.TexCoords) > 0 {
+		gl.BindBuffer(gl.ARRAY_BUFFER, m.vbo[TEXCOORD_VB])
+		gl.BufferData(gl.ARRAY_BUFFER, len(data.TexCoords)*2*4, gl.Ptr(data.TexCoords), gl.STATIC_DRAW)
+		gl.EnableVertexAttribArray(uint32(TEXCOORD_VB))
+		gl.VertexAttribPointer(uint32(TEXCOORD_VB), 2, gl.FLOAT, false, 0, gl.PtrOffset(0))
+	}
+
+	if len(data.Normals) > 0 {
+		gl.BindBuffer(gl.ARRAY_BUFFER, m.vbo[NORMAL_VB])
+		gl.BufferData(gl.ARRAY_BUFFER, len(data.Normals)*3*4, gl.Ptr(data.Normals), gl.STATIC_DRAW)
+		gl.EnableVertexAttribArray(uint32(NORMAL_VB))
+		gl.VertexAttribPointer(uint32(NORMAL_VB), 3, gl.FLOAT, false, 0, gl.PtrOffset(0))
+	}
+
+	if len(data.Colors) > 0 {
+		gl.BindBuffer(gl.ARRAY_BUFFER, m.vbo[COLOR_VB])
+		gl.BufferData(gl.ARRAY_BUFFER, len(data.Colors)*4*4, gl.Ptr(data.Colors), gl.STATIC_DRAW)
+		gl.EnableVertexAttribArray(uint32(COLOR_VB))
+		gl.VertexAttribPointer(uint32(COLOR_VB), 4, gl.FLOAT, false, 0, gl.PtrOffset(0))
+	}
+
+	if len(data.Lights) > 0 {
+		gl.BindBuffer(gl.ARRAY_BUFFER, m.vbo[LIGHT_VB])
+		gl.BufferData(gl.ARRAY_BUFFER, len(data.Lights)*2*4, gl.Ptr(data.Lights), gl.STATIC_DRAW)
+		gl.EnableVertexAttribArray(uint32(LIGHT_VB))
+		gl.VertexAttribPointer(uint32(LIGHT_VB), 2, gl.FLOAT, false, 0, gl.PtrOffset(0))
+	}
+
+	if len(data.Layers) > 0 {
+		gl.BindBuffer(gl.ARRAY_BUFFER, m.vbo[LAYER_VB])
+		gl.BufferData(gl.ARRAY_BUFFER, len(data.Layers)*4, gl.Ptr(data.Layers), gl.STATIC_DRAW)
+		gl.EnableVertexAttribArray(uint32(LAYER_VB))
+		gl.VertexAttribPointer(uint32(LAYER_VB), 1, gl.FLOAT, false, 0, gl.PtrOffset(0))
+	}
+
+	if len(data.Indices) > 0 {
+		gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, m.vbo[INDEX_VB])
+		gl.BufferData(gl.ELEMENT_ARRAY_BUFFER, len(data.Indices)*4, gl.Ptr(data.Indices), gl.STATIC_DRAW)
+	}
+
+	gl.BindVertexArray(0)
+}
+
+func (m *Mesh) setBounds(positions []mgl32.Vec3) {
+	m.box = bounds.FromPoints(positions)
+	m.sphere = bounds.SphereFromPoints(positions)
+}
+
+// Bounds returns the box around the vertices of the mesh, before any model matrix is applied
+func (m *Mesh) Bounds() bounds.AABB {
+	return m.box
+}
+
+// BoundingSphere returns the sphere around the vertices of the mesh, before any model matrix is applied
+func (m *Mesh) BoundingSphere() bounds.Sphere {
+	return m.sphere
+}
+
+// Normals returns the positions and normals of the vertices in model space,
+// nil if the mesh has no normals
+func (m *Mesh) Normals() (positions, normals []mgl32.Vec3) {
+	switch {
+	case m.data != nil:
+		positions, normals = m.data.Positions, m.data.Normals
+	case m.model != nil:
+		positions, normals = m.model.Positions, m.model.Normals
+	case m.quickmodel != nil:
+		positions, normals = m.quickmodel.Vertices, m.quickmodel.Normals
+	}
+	if len(normals) != len(positions) {
+		return nil, nil
+	}
+	return positions, normals
+}
+
+// Delete frees the GL buffers of the mesh, must be called on the GL thread
+func (m *Mesh) Delete() {
+	gl.DeleteBuffers(int32(NUM_BUFFERS), &m.vbo[0])
+	gl.DeleteVertexArrays(1, &m.vao)
+}
+
 func (m *Mesh) Draw() {
-	if m.quickmodel != nil {
+	if m.data != nil {
+		m.DrawData()
+	} else if m.quickmodel != nil {
 		m.DrawNew()
 	} else {
 		m.DrawOld()
@@ -139,7 +270,7 @@ func (m *Mesh) DrawOld() {
 	gl.BindVertexArray(m.vao)
 
 	// gl.DrawElements(gl.TRIANGLES, int32(len(m.model.Indices)), gl.UNSIGNED_INT, gl.PtrOffset(0))
-	gl.DrawElements(gl.TRIANGLES, int32(len(m.model.Indices))*3, gl.UNSIGNED_INT, gl.PtrOffset(0))
+	gl.DrawElements(gl.TRIANGLES, int32(len(m.model.Indices)), gl.UNSIGNED_INT, gl.PtrOffset(0))
 	// gl.DrawElementsBaseVertex(gl.TRIANGLES, int32(len(m.model.Indices)), gl.UNSIGNED_INT, gl.PtrOffset(0), 0)
 
 	gl.BindVertexArray(0)
@@ -155,3 +286,30 @@ func (m *Mesh) DrawNew() {
 
 	gl.BindVertexArray(0)
 }
+
+// UpdateIndices uploads the indices of the Data the mesh was created from again,
+// after they were reordered in place, must be called on the GL thread
+func (m *Mesh) UpdateIndices() {
+	if m.data == nil || len(m.data.Indices) == 0 {
+		return
+	}
+	gl.BindVertexArray(m.vao)
+	gl.BufferSubData(gl.ELEMENT_ARRAY_BUFFER, 0, len(m.data.Indices)*4, gl.Ptr(m.data.Indices))
+	gl.BindVertexArray(0)
+}
+
+func (m *Mesh) DrawData() {
+	gl.BindVertexArray(m.vao)
+
+	var mode uint32 = gl.TRIANGLES
+	if m.data.Lines {
+		mode = gl.LINES
+	}
+	if len(m.data.Indices) > 0 {
+		gl.DrawElements(mode, int32(len(m.data.Indices)), gl.UNSIGNED_INT, gl.PtrOffset(0))
+	} else {
+		gl.DrawArrays(mode, 0, int32(len(m.data.Positions)))
+	}
+
+	gl.BindVertexArray(0)
+}
@@ -0,0 +1,99 @@
+package noise
+
+import (
+	"math"
+	"math/rand"
+)
+
+// Perlin is a seeded gradient noise source. The same seed always yields the same field.
+type Perlin struct {
+	perm [512]int
+}
+
+// NewPerlin creates a new Perlin noise source from the given seed
+func NewPerlin(seed int64) *Perlin {
+	p := new(Perlin)
+	r := rand.New(rand.NewSource(seed))
+	for i, v := range r.Perm(256) {
+		p.perm[i] = v
+		p.perm[i+256] = v
+	}
+	return p
+}
+
+// Noise2 returns 2D noise at x, y in the range [-1, 1]
+func (p *Perlin) Noise2(x, y float64) float64 {
+	return p.Noise3(x, y, 0)
+}
+
+// Noise3 returns 3D noise at x, y, z in the range [-1, 1]
+func (p *Perlin) Noise3(x, y, z float64) float64 {
+	fx, fy, fz := math.Floor(x), math.Floor(y), math.Floor(z)
+	xi, yi, zi := int(fx)&255, int(fy)&255, int(fz)&255
+	x, y, z = x-fx, y-fy, z-fz
+	u, v, w := fade(x), fade(y), fade(z)
+
+	a := p.perm[xi] + yi
+	aa := p.perm[a] + zi
+	ab := p.perm[a+1] + zi
+	b := p.perm[xi+1] + yi
+	ba := p.perm[b] + zi
+	bb := p.perm[b+1] + zi
+
+	return lerp(w,
+		lerp(v,
+			lerp(u, grad(p.perm[aa], x, y, z), grad(p.perm[ba], x-1, y, z)),
+			lerp(u, grad(p.perm[ab], x, y-1, z), grad(p.perm[bb], x-1, y-1, z))),
+		lerp(v,
+			lerp(u, grad(p.perm[aa+1], x, y, z-1), grad(p.perm[ba+1], x-1, y, z-1)),
+			lerp(u, grad(p.perm[ab+1], x, y-1, z-1), grad(p.perm[bb+1], x-1, y-1, z-1))))
+}
+
+// Octave2 sums octaves of 2D noise (fractal brownian motion), normalized to [-1, 1]
+func (p *Perlin) Octave2(x, y float64, octaves int, persistence float64) float64 {
+	return p.Octave3(x, y, 0, octaves, persistence)
+}
+
+// Octave3 sums octaves of 3D noise (fractal brownian motion), normalized to [-1, 1]
+func (p *Perlin) Octave3(x, y, z float64, octaves int, persistence float64) float64 {
+	var total, max float64
+	frequency, amplitude := 1.0, 1.0
+	for i := 0; i < octaves; i++ {
+		total += p.Noise3(x*frequency, y*frequency, z*frequency) * amplitude
+		max += amplitude
+		amplitude *= persistence
+		frequency *= 2
+	}
+	return total / max
+}
+
+func fade(t float64) float64 {
+	return t * t * t * (t*(t*6-15) + 10)
+}
+
+func lerp(t, a, b float64) float64 {
+	return a + t*(b-a)
+}
+
+func grad(hash int, x, y, z float64) float64 {
+	h := hash & 15
+	u := x
+	if h >= 8 {
+		u = y
+	}
+	var v float64
+	if h < 4 {
+		v = y
+	} else if h == 12 || h == 14 {
+		v = x
+	} else {
+		v = z
+	}
+	if h&1 != 0 {
+		u = -u
+	}
+	if h&2 != 0 {
+		v = -v
+	}
+	return u + v
+}
@@ -0,0 +1,123 @@
+package obj
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// Mtl is a material of an MTL file
+type Mtl struct {
+	Name       string
+	Ambient    mgl32.Vec3 // Ka
+	Diffuse    mgl32.Vec3 // Kd
+	Specular   mgl32.Vec3 // Ks
+	Shininess  float32    // Ns, the specular exponent
+	Opacity    float32    // d, or 1 - Tr
+	DiffuseMap string     // map_Kd, relative to the working directory
+}
+
+// LoadMtl reads the materials of an MTL file by name
+func LoadMtl(path string) (map[string]*Mtl, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	materials := make(map[string]*Mtl)
+	var m *Mtl
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if fields[0] == "newmtl" {
+			m = &Mtl{Name: fields[1], Diffuse: mgl32.Vec3{1, 1, 1}, Opacity: 1}
+			materials[m.Name] = m
+			continue
+		}
+		if m == nil {
+			return nil, fmt.Errorf("failed to read %v: %v before newmtl", path, fields[0])
+		}
+		switch fields[0] {
+		case "Ka":
+			m.Ambient = parseFields3(fields)
+		case "Kd":
+			m.Diffuse = parseFields3(fields)
+		case "Ks":
+			m.Specular = parseFields3(fields)
+		case "Ns":
+			m.Shininess = parseFloatValue(fields[1])
+		case "d":
+			m.Opacity = parseFloatValue(fields[1])
+		case "Tr":
+			m.Opacity = 1 - parseFloatValue(fields[1])
+		case "map_Kd":
+			// options like -s come before the file name, which may contain spaces
+			m.DiffuseMap = filepath.Join(filepath.Dir(path), fields[len(fields)-1])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %v: %v", path, err)
+	}
+	return materials, nil
+}
+
+// LoadMaterials reads the MTL files an OBJ file refers to with mtllib and
+// returns the materials it uses with usemtl, in order
+func LoadMaterials(objPath string) ([]*Mtl, error) {
+	f, err := os.Open(objPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	materials := make(map[string]*Mtl)
+	var used []*Mtl
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "mtllib":
+			lib, err := LoadMtl(filepath.Join(filepath.Dir(objPath), fields[1]))
+			if err != nil {
+				return nil, err
+			}
+			for name, m := range lib {
+				materials[name] = m
+			}
+		case "usemtl":
+			m, ok := materials[fields[1]]
+			if !ok {
+				return nil, fmt.Errorf("failed to read %v: material %v isn't in any mtllib", objPath, fields[1])
+			}
+			used = append(used, m)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %v: %v", objPath, err)
+	}
+	return used, nil
+}
+
+func parseFields3(fields []string) mgl32.Vec3 {
+	var v mgl32.Vec3
+	for i := range v {
+		// a single value is used for all three
+		j := i + 1
+		if j >= len(fields) {
+			j = 1
+		}
+		v[i] = parseFloatValue(fields[j])
+	}
+	return v
+}
@@ -0,0 +1,72 @@
+package physics
+
+import (
+	"time"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/tehcyx/goengine/bounds"
+)
+
+// Body is a rigid body moving in a Space. Bodies don't rotate, which is enough
+// for props and dropped items and keeps every shape axis aligned.
+type Body struct {
+	Shape       Shape
+	Position    mgl32.Vec3 // center of the shape
+	Velocity    mgl32.Vec3
+	Mass        float32 // 0 makes the body static, it never moves
+	Restitution float32 // bounciness from 0 to 1
+	Friction    float32
+	Data        interface{} // anything gameplay code wants to find the body by
+
+	sleeping  bool
+	stillTime time.Duration // how long the body has been slower than the sleep speed
+}
+
+// NewBody creates a new body, a mass of 0 creates a static one
+func NewBody(shape Shape, position mgl32.Vec3, mass float32) *Body {
+	b := new(Body)
+	b.Shape = shape
+	b.Position = position
+	b.Mass = mass
+	b.Restitution = 0.2
+	b.Friction = 0.5
+	return b
+}
+
+// Bounds returns the box around the body
+func (b *Body) Bounds() bounds.AABB {
+	return b.Shape.Bounds(b.Position)
+}
+
+// Static reports whether the body never moves
+func (b *Body) Static() bool {
+	return b.Mass <= 0
+}
+
+// Sleeping reports whether the body came to rest and isn't simulated until
+// something wakes it
+func (b *Body) Sleeping() bool {
+	return b.sleeping
+}
+
+// Wake makes a sleeping body simulated again
+func (b *Body) Wake() {
+	b.sleeping = false
+	b.stillTime = 0
+}
+
+// ApplyImpulse changes the velocity as if the impulse hit the body and wakes it
+func (b *Body) ApplyImpulse(impulse mgl32.Vec3) {
+	if b.Static() {
+		return
+	}
+	b.Velocity = b.Velocity.Add(impulse.Mul(1 / b.Mass))
+	b.Wake()
+}
+
+func (b *Body) inverseMass() float32 {
+	if b.Static() {
+		return 0
+	}
+	return 1 / b.Mass
+}
@@ -0,0 +1,151 @@
+package physics
+
+import (
+	"math"
+	"time"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/tehcyx/goengine/bounds"
+)
+
+// MoveMode selects how a character moves
+type MoveMode int
+
+const (
+	Walking MoveMode = iota // gravity, jumping and collisions
+	Flying                  // no gravity, moves up and down freely, still collides
+	Noclip                  // flying through blocks
+)
+
+// Input is what the player wants a character to do during one update
+type Input struct {
+	Forward float32 // -1 to 1, along the view direction
+	Right   float32 // -1 to 1, sideways
+	Yaw     float32 // view direction in radians around the y axis, 0 looks along -z
+	Jump    bool    // jump when walking, rise when flying
+	Descend bool    // sink when flying
+}
+
+// Character is a box shaped body moved by player input, like the player of a
+// first person game. Position is the center of its feet.
+type Character struct {
+	Position mgl32.Vec3
+	Velocity mgl32.Vec3
+	Mode     MoveMode
+	OnGround bool // standing on a solid block after the last update
+
+	Width      float32 // size along x and z
+	Height     float32
+	EyeHeight  float32 // height of the camera above the feet
+	WalkSpeed  float32 // blocks per second
+	FlySpeed   float32
+	JumpSpeed  float32 // upwards velocity when jumping
+	Gravity    float32 // acceleration, negative is down
+	MaxFall    float32 // terminal falling speed
+	AirControl float32 // how quickly the horizontal velocity follows input in the air, per second
+	StepHeight float32 // ledges up to this height are climbed without jumping
+}
+
+// NewCharacter creates a walking character the size of a Minecraft player
+func NewCharacter(position mgl32.Vec3) *Character {
+	c := new(Character)
+	c.Position = position
+	c.Width = 0.6
+	c.Height = 1.8
+	c.EyeHeight = 1.62
+	c.WalkSpeed = 4.3
+	c.FlySpeed = 10.8
+	c.JumpSpeed = 8.4
+	c.Gravity = -28
+	c.MaxFall = 60
+	c.AirControl = 6
+	c.StepHeight = 1
+	return c
+}
+
+// Box returns the collision box of the character
+func (c *Character) Box() bounds.AABB {
+	h := c.Width / 2
+	return bounds.AABB{
+		Min: mgl32.Vec3{c.Position.X() - h, c.Position.Y(), c.Position.Z() - h},
+		Max: mgl32.Vec3{c.Position.X() + h, c.Position.Y() + c.Height, c.Position.Z() + h},
+	}
+}
+
+// Eye returns the camera position of the character
+func (c *Character) Eye() mgl32.Vec3 {
+	return c.Position.Add(mgl32.Vec3{0, c.EyeHeight, 0})
+}
+
+// Update moves the character by one fixed step
+func (c *Character) Update(g Grid, in Input, dt time.Duration) {
+	t := float32(dt.Seconds())
+	sin, cos := float32(math.Sin(float64(in.Yaw))), float32(math.Cos(float64(in.Yaw)))
+	forward, right := mgl32.Vec3{sin, 0, -cos}, mgl32.Vec3{cos, 0, sin}
+	wish := forward.Mul(in.Forward).Add(right.Mul(in.Right))
+	if wish.Len() > 1 {
+		wish = wish.Normalize()
+	}
+
+	if c.Mode != Walking {
+		c.Velocity = wish.Mul(c.FlySpeed)
+		if in.Jump {
+			c.Velocity[1] += c.FlySpeed
+		}
+		if in.Descend {
+			c.Velocity[1] -= c.FlySpeed
+		}
+		if c.Mode == Noclip {
+			c.Position = c.Position.Add(c.Velocity.Mul(t))
+			c.OnGround = false
+			return
+		}
+		c.move(g, c.Velocity.Mul(t), false)
+		return
+	}
+
+	target := wish.Mul(c.WalkSpeed)
+	if c.OnGround {
+		c.Velocity[0], c.Velocity[2] = target[0], target[2]
+		if in.Jump {
+			c.Velocity[1] = c.JumpSpeed
+		}
+	} else {
+		// steer towards the input, but keep most of the momentum
+		k := min32(c.AirControl*t, 1)
+		c.Velocity[0] += (target[0] - c.Velocity[0]) * k
+		c.Velocity[2] += (target[2] - c.Velocity[2]) * k
+	}
+	c.Velocity[1] = max32(c.Velocity[1]+c.Gravity*t, -c.MaxFall)
+	c.move(g, c.Velocity.Mul(t), c.OnGround)
+}
+
+// move sweeps the character, trying to step up ledges it walks into if it stood on the ground
+func (c *Character) move(g Grid, delta mgl32.Vec3, canStep bool) {
+	box, moved, hit := Move(g, c.Box(), delta)
+	ground := hit[1] && delta[1] < 0
+
+	if canStep && c.StepHeight > 0 && (hit[0] || hit[2]) {
+		// go up, then along, then back down and keep that if it got further
+		up, _, _ := Move(g, c.Box(), mgl32.Vec3{0, c.StepHeight, 0})
+		across, acrossMoved, acrossHit := Move(g, up, mgl32.Vec3{delta[0], 0, delta[2]})
+		down, _, downHit := Move(g, across, mgl32.Vec3{0, -c.StepHeight, 0})
+		if downHit[1] && horizontal(acrossMoved) > horizontal(moved)+skin {
+			box = down
+			hit = [3]bool{acrossHit[0], true, acrossHit[2]}
+			ground = true
+		}
+	}
+
+	c.Position = mgl32.Vec3{(box.Min[0] + box.Max[0]) / 2, box.Min[1], (box.Min[2] + box.Max[2]) / 2}
+	c.OnGround = ground
+	for i := range hit {
+		if hit[i] {
+			c.Velocity[i] = 0
+		}
+	}
+}
+
+func horizontal(v mgl32.Vec3) float32 {
+	return v[0]*v[0] + v[2]*v[2]
+}
@@ -0,0 +1,154 @@
+package physics
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+const step = time.Second / 60
+
+// east looks along +x
+const east = math.Pi / 2
+
+// flat is solid below y 0
+func flat(x, y, z int) bool {
+	return y < 0
+}
+
+// run updates the character n times with the same input
+func run(c *Character, g Grid, in Input, n int) {
+	for i := 0; i < n; i++ {
+		c.Update(g, in, step)
+	}
+}
+
+func near32(a, b float32) bool {
+	return math.Abs(float64(a-b)) < 1e-3
+}
+
+func TestCharacterGround(t *testing.T) {
+	c := NewCharacter(mgl32.Vec3{0.5, 3, 0.5})
+	run(c, GridFunc(flat), Input{}, 60)
+	if !c.OnGround || !near32(c.Position.Y(), 0) || c.Velocity.Y() != 0 {
+		t.Fatalf("after falling: on ground %v at %v moving %v", c.OnGround, c.Position, c.Velocity)
+	}
+	run(c, GridFunc(flat), Input{}, 10)
+	if !c.OnGround || !near32(c.Position.Y(), 0) {
+		t.Errorf("standing still: on ground %v at %v", c.OnGround, c.Position)
+	}
+
+	// walking off the edge of the floor
+	ledge := GridFunc(func(x, y, z int) bool { return y < 0 && x < 2 })
+	run(c, ledge, Input{Forward: 1, Yaw: east}, 60)
+	if c.OnGround || c.Position.Y() >= 0 || c.Position.X() < 2 {
+		t.Errorf("past the ledge: on ground %v at %v", c.OnGround, c.Position)
+	}
+}
+
+func TestCharacterStepUp(t *testing.T) {
+	tests := []struct {
+		name   string
+		height int
+		climb  bool
+	}{
+		{"one block", 1, true},
+		{"two blocks", 2, false},
+	}
+	for _, test := range tests {
+		g := GridFunc(func(x, y, z int) bool { return y < 0 || x >= 2 && y < test.height })
+		c := NewCharacter(mgl32.Vec3{0.5, 0, 0.5})
+		run(c, g, Input{}, 1)
+		run(c, g, Input{Forward: 1, Yaw: east}, 60)
+		if test.climb {
+			if !c.OnGround || !near32(c.Position.Y(), 1) || c.Position.X() < 3 {
+				t.Errorf("%v: on ground %v at %v, want it on top of the step", test.name, c.OnGround, c.Position)
+			}
+		} else if !near32(c.Position.Y(), 0) || c.Position.X() > 2-c.Width/2+skin {
+			t.Errorf("%v: at %v, want it stopped by the wall", test.name, c.Position)
+		}
+	}
+}
+
+func TestCharacterJump(t *testing.T) {
+	c := NewCharacter(mgl32.Vec3{0.5, 0, 0.5})
+	run(c, GridFunc(flat), Input{}, 1)
+	run(c, GridFunc(flat), Input{Jump: true}, 1)
+	if c.OnGround {
+		t.Fatal("still on the ground after jumping")
+	}
+	var top float32
+	for i := 0; i < 120 && !c.OnGround; i++ {
+		c.Update(GridFunc(flat), Input{}, step)
+		top = max32(top, c.Position.Y())
+	}
+	// v²/2g is 1.26 blocks
+	if top < 1.1 || top > 1.3 {
+		t.Errorf("jumped %v blocks high", top)
+	}
+	if !c.OnGround || !near32(c.Position.Y(), 0) {
+		t.Errorf("after the jump: on ground %v at %v", c.OnGround, c.Position)
+	}
+
+	// no jumping in mid air
+	c.Position[1] = 5
+	run(c, GridFunc(flat), Input{}, 1)
+	run(c, GridFunc(flat), Input{Jump: true}, 1)
+	if c.Velocity.Y() > 0 {
+		t.Errorf("jumped in the air with velocity %v", c.Velocity)
+	}
+}
+
+func TestCharacterNoTunnelling(t *testing.T) {
+	// a platform one block thick, fallen onto at 50 blocks per step
+	platform := GridFunc(func(x, y, z int) bool { return y == 10 })
+	c := NewCharacter(mgl32.Vec3{0.5, 200, 0.5})
+	c.MaxFall = 3000
+	c.Velocity[1] = -3000
+	run(c, platform, Input{}, 10)
+	if !c.OnGround || !near32(c.Position.Y(), 11) {
+		t.Fatalf("fell through the platform: on ground %v at %v", c.OnGround, c.Position)
+	}
+
+	// a wall one block thick, flown into at 100 blocks per step
+	wall := GridFunc(func(x, y, z int) bool { return x == 5 })
+	c = NewCharacter(mgl32.Vec3{0.5, 0, 0.5})
+	c.Mode = Flying
+	c.FlySpeed = 6000
+	run(c, wall, Input{Forward: 1, Yaw: east}, 3)
+	if !near32(c.Position.X(), 5-c.Width/2) {
+		t.Errorf("flew through the wall to %v", c.Position)
+	}
+}
+
+func TestCharacterFly(t *testing.T) {
+	// a ceiling at y 5 and a wall at x 3
+	g := GridFunc(func(x, y, z int) bool { return y < 0 || y == 5 || x == 3 })
+	c := NewCharacter(mgl32.Vec3{0.5, 1, 0.5})
+	c.Mode = Flying
+	run(c, g, Input{}, 30)
+	if !near32(c.Position.Y(), 1) {
+		t.Errorf("flying character fell to %v", c.Position)
+	}
+	run(c, g, Input{Jump: true}, 60)
+	if !near32(c.Position.Y()+c.Height, 5) {
+		t.Errorf("flying up stopped at %v, want the head at the ceiling", c.Position)
+	}
+	run(c, g, Input{Descend: true}, 60)
+	if !near32(c.Position.Y(), 0) || !c.OnGround {
+		t.Errorf("flying down: on ground %v at %v", c.OnGround, c.Position)
+	}
+	run(c, g, Input{Forward: 1, Yaw: east}, 60)
+	if !near32(c.Position.X(), 3-c.Width/2) {
+		t.Errorf("flew through the wall to %v", c.Position)
+	}
+
+	c.Mode = Noclip
+	run(c, g, Input{Forward: 1, Yaw: east, Jump: true}, 60)
+	want := mgl32.Vec3{3 - c.Width/2 + c.FlySpeed, c.FlySpeed, 0.5}
+	if !near32(c.Position.X(), want.X()) || !near32(c.Position.Y(), want.Y()) || c.OnGround {
+		t.Errorf("noclip: on ground %v at %v, want %v", c.OnGround, c.Position, want)
+	}
+}
@@ -0,0 +1,159 @@
+package physics
+
+import (
+	"math"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/tehcyx/goengine/bounds"
+	"github.com/tehcyx/goengine/world"
+)
+
+// Grid tells which unit blocks boxes collide with
+type Grid interface {
+	Solid(x, y, z int) bool
+}
+
+// GridFunc adapts a function to the Grid interface
+type GridFunc func(x, y, z int) bool
+
+// Solid calls f
+func (f GridFunc) Solid(x, y, z int) bool {
+	return f(x, y, z)
+}
+
+// WorldGrid collides with the solid blocks of a voxel world. Blocks of chunks
+// that aren't loaded are air.
+func WorldGrid(w *world.World) Grid {
+	return GridFunc(func(x, y, z int) bool {
+		return w.Block(x, y, z).IsSolid()
+	})
+}
+
+// skin keeps rounding errors from letting a box that rests on a block count as inside it
+const skin = 1e-4
+
+// Move sweeps box by delta through the grid, one axis after the other starting
+// with y, and stops it at the first solid block on each axis, so it slides along
+// walls. It returns the moved box, the distance actually moved and the axes on
+// which it hit something. Blocks the box already overlaps are ignored, so a box
+// stuck inside blocks can still move out.
+func Move(g Grid, box bounds.AABB, delta mgl32.Vec3) (bounds.AABB, mgl32.Vec3, [3]bool) {
+	var moved mgl32.Vec3
+	var hit [3]bool
+	for _, axis := range [3]int{1, 0, 2} {
+		d := delta[axis]
+		if d == 0 {
+			continue
+		}
+		// the blocks the box passes on this axis, shrunk on the others so sliding
+		// along a wall doesn't catch on it
+		lo, hi := box.Min.Add(mgl32.Vec3{skin, skin, skin}), box.Max.Sub(mgl32.Vec3{skin, skin, skin})
+		if d > 0 {
+			lo[axis], hi[axis] = box.Max[axis]-skin, box.Max[axis]+d
+		} else {
+			lo[axis], hi[axis] = box.Min[axis]+d, box.Min[axis]+skin
+		}
+		x0, y0, z0 := floor(lo[0]), floor(lo[1]), floor(lo[2])
+		x1, y1, z1 := ceil(hi[0])-1, ceil(hi[1])-1, ceil(hi[2])-1
+		for x := x0; x <= x1; x++ {
+			for y := y0; y <= y1; y++ {
+				for z := z0; z <= z1; z++ {
+					if !g.Solid(x, y, z) {
+						continue
+					}
+					p := [3]int{x, y, z}
+					if d > 0 && float32(p[axis]) >= box.Max[axis]-skin {
+						d = min32(d, float32(p[axis])-box.Max[axis])
+					} else if d < 0 && float32(p[axis]+1) <= box.Min[axis]+skin {
+						d = max32(d, float32(p[axis]+1)-box.Min[axis])
+					}
+				}
+			}
+		}
+		if d != delta[axis] {
+			hit[axis] = true
+		}
+		box.Min[axis] += d
+		box.Max[axis] += d
+		moved[axis] = d
+	}
+	return box, moved, hit
+}
+
+// MoveShape sweeps a shape centered at position by delta through the grid like
+// Move, but collides with the shape itself instead of the box around it, so
+// spheres and capsules roll off edges. It slides along what it hits and returns
+// the distance actually moved and the normals of the blocks it hit, pointing away
+// from them. Blocks the shape already overlaps are ignored.
+func MoveShape(g Grid, s Shape, position, delta mgl32.Vec3) (mgl32.Vec3, []mgl32.Vec3) {
+	start := position
+	var normals []mgl32.Vec3
+	// a slide per axis, and one more for rounding
+	for i := 0; i < 4 && delta.Len() > 0; i++ {
+		from, to := s.Bounds(position), s.Bounds(position.Add(delta))
+		lo := mgl32.Vec3{min32(from.Min[0], to.Min[0]), min32(from.Min[1], to.Min[1]), min32(from.Min[2], to.Min[2])}
+		hi := mgl32.Vec3{max32(from.Max[0], to.Max[0]), max32(from.Max[1], to.Max[1]), max32(from.Max[2], to.Max[2])}
+
+		t, hit := float32(1), false
+		var normal mgl32.Vec3
+		for x := floor(lo[0] - skin); x <= ceil(hi[0]+skin)-1; x++ {
+			for y := floor(lo[1] - skin); y <= ceil(hi[1]+skin)-1; y++ {
+				for z := floor(lo[2] - skin); z <= ceil(hi[2]+skin)-1; z++ {
+					if !g.Solid(x, y, z) {
+						continue
+					}
+					block := bounds.AABB{Min: mgl32.Vec3{float32(x), float32(y), float32(z)}, Max: mgl32.Vec3{float32(x + 1), float32(y + 1), float32(z + 1)}}
+					if bt, n, ok := s.sweep(position, delta, block); ok && bt <= t {
+						t, normal, hit = bt, n, true
+					}
+				}
+			}
+		}
+		if !hit {
+			position = position.Add(delta)
+			break
+		}
+		// stop a skin away, so the shape never ends up inside what it hit
+		position = position.Add(delta.Mul(t)).Add(normal.Mul(skin))
+		normals = append(normals, normal)
+		delta = delta.Mul(1 - t)
+		delta = delta.Sub(normal.Mul(delta.Dot(normal)))
+	}
+	return position.Sub(start), normals
+}
+
+// Overlaps reports whether the box intersects any solid block
+func Overlaps(g Grid, box bounds.AABB) bool {
+	for x := floor(box.Min[0] + skin); x <= ceil(box.Max[0]-skin)-1; x++ {
+		for y := floor(box.Min[1] + skin); y <= ceil(box.Max[1]-skin)-1; y++ {
+			for z := floor(box.Min[2] + skin); z <= ceil(box.Max[2]-skin)-1; z++ {
+				if g.Solid(x, y, z) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func floor(v float32) int {
+	return int(math.Floor(float64(v)))
+}
+
+func ceil(v float32) int {
+	return int(math.Ceil(float64(v)))
+}
+
+func min32(a, b float32) float32 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max32(a, b float32) float32 {
+	if a > b {
+		return a
+	}
+	return b
+}
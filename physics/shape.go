@@ -0,0 +1,169 @@
+package physics
+
+import (
+	"math"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/tehcyx/goengine/bounds"
+)
+
+// Shape is the collision shape of a body. Every shape is an axis aligned box
+// with its corners rounded by a radius: a sphere is a box of size zero, a
+// capsule one that is only tall. That makes the distance between any two shapes
+// the distance between their boxes minus the radii.
+type Shape struct {
+	half   mgl32.Vec3 // half size of the inner box
+	radius float32
+}
+
+// Sphere returns a sphere shape
+func Sphere(radius float32) Shape {
+	return Shape{radius: radius}
+}
+
+// Box returns an axis aligned box shape
+func Box(halfExtents mgl32.Vec3) Shape {
+	return Shape{half: halfExtents}
+}
+
+// Capsule returns an upright capsule shape, height includes both caps
+func Capsule(radius, height float32) Shape {
+	return Shape{half: mgl32.Vec3{0, max32(height/2-radius, 0), 0}, radius: radius}
+}
+
+// Bounds returns the box around the shape centered at position
+func (s Shape) Bounds(position mgl32.Vec3) bounds.AABB {
+	e := s.half.Add(mgl32.Vec3{s.radius, s.radius, s.radius})
+	return bounds.AABB{Min: position.Sub(e), Max: position.Add(e)}
+}
+
+// collide returns the direction from shape a to b and how deep they overlap,
+// ok is false if they don't touch
+func collide(a Shape, pa mgl32.Vec3, b Shape, pb mgl32.Vec3) (normal mgl32.Vec3, depth float32, ok bool) {
+	normal, dist := separation(a, pa, b, pb)
+	if dist >= 0 {
+		return mgl32.Vec3{}, 0, false
+	}
+	return normal, -dist, true
+}
+
+// separation returns the direction from shape a to b and the distance between
+// their surfaces, negative if they overlap
+func separation(a Shape, pa mgl32.Vec3, b Shape, pb mgl32.Vec3) (normal mgl32.Vec3, dist float32) {
+	aMin, aMax := pa.Sub(a.half), pa.Add(a.half)
+	bMin, bMax := pb.Sub(b.half), pb.Add(b.half)
+	r := a.radius + b.radius
+
+	var gap mgl32.Vec3
+	for i := 0; i < 3; i++ {
+		if bMin[i] > aMax[i] {
+			gap[i] = bMin[i] - aMax[i]
+		} else if aMin[i] > bMax[i] {
+			gap[i] = bMax[i] - aMin[i]
+		}
+	}
+	if dist := gap.Len(); dist > 0 {
+		return gap.Mul(1 / dist), dist - r
+	}
+
+	// the inner boxes overlap, push out along the axis they overlap least on
+	axis, overlap := 0, float32(0)
+	for i := 0; i < 3; i++ {
+		o := min32(aMax[i], bMax[i]) - max32(aMin[i], bMin[i])
+		if i == 0 || o < overlap {
+			axis, overlap = i, o
+		}
+	}
+	normal[axis] = 1
+	if pb[axis] < pa[axis] {
+		normal[axis] = -1
+	}
+	return normal, -overlap - r
+}
+
+// sweep returns when the shape moving from position by delta first touches box,
+// as a fraction of delta, and the normal of the box where it does, pointing to
+// the shape. ok is false if it doesn't touch it on the way or already overlaps it
+// by more than a skin.
+//
+// Moving the shape is the same as moving its center through the box grown by
+// the shape: the box grown by the inner box, with its corners rounded by the
+// radius. That is hit on one of its faces, edges or corners.
+func (s Shape) sweep(position, delta mgl32.Vec3, box bounds.AABB) (t float32, normal mgl32.Vec3, ok bool) {
+	lo, hi := box.Min.Sub(s.half), box.Max.Add(s.half)
+	r := s.radius
+	t = 1
+	hit := func(ht float32, n mgl32.Vec3) {
+		// a start less than a skin inside from rounding still counts as touching
+		if d := n.Dot(delta); d < 0 && ht*d <= skin && ht <= t {
+			t, normal, ok = max32(ht, 0), n, true
+		}
+	}
+
+	// faces, moved out by the radius
+	for i := 0; i < 3; i++ {
+		if delta[i] == 0 {
+			continue
+		}
+		var n mgl32.Vec3
+		plane := hi[i] + r
+		n[i] = 1
+		if delta[i] > 0 {
+			plane = lo[i] - r
+			n[i] = -1
+		}
+		ht := (plane - position[i]) / delta[i]
+		p := position.Add(delta.Mul(ht))
+		j, k := (i+1)%3, (i+2)%3
+		if p[j] >= lo[j] && p[j] <= hi[j] && p[k] >= lo[k] && p[k] <= hi[k] {
+			hit(ht, n)
+		}
+	}
+	if r == 0 {
+		return t, normal, ok
+	}
+
+	// edges, cylinders along axis i
+	for i := 0; i < 3; i++ {
+		j, k := (i+1)%3, (i+2)%3
+		for _, cj := range [2]float32{lo[j], hi[j]} {
+			for _, ck := range [2]float32{lo[k], hi[k]} {
+				ht, ok := enterSphere(mgl32.Vec2{position[j] - cj, position[k] - ck}.Vec3(0), mgl32.Vec2{delta[j], delta[k]}.Vec3(0), r)
+				if !ok {
+					continue
+				}
+				p := position.Add(delta.Mul(ht))
+				if p[i] < lo[i] || p[i] > hi[i] {
+					continue
+				}
+				var n mgl32.Vec3
+				n[j], n[k] = (p[j]-cj)/r, (p[k]-ck)/r
+				hit(ht, n)
+			}
+		}
+	}
+
+	// corners
+	for _, cx := range [2]float32{lo[0], hi[0]} {
+		for _, cy := range [2]float32{lo[1], hi[1]} {
+			for _, cz := range [2]float32{lo[2], hi[2]} {
+				c := mgl32.Vec3{cx, cy, cz}
+				if ht, ok := enterSphere(position.Sub(c), delta, r); ok {
+					hit(ht, position.Add(delta.Mul(ht)).Sub(c).Mul(1/r))
+				}
+			}
+		}
+	}
+	return t, normal, ok
+}
+
+// enterSphere returns when a point starting at o relative to the center of a
+// sphere and moving by d enters it
+func enterSphere(o, d mgl32.Vec3, r float32) (float32, bool) {
+	a, b, c := d.Dot(d), o.Dot(d), o.Dot(o)-r*r
+	disc := b*b - a*c
+	if a == 0 || disc < 0 {
+		return 0, false
+	}
+	return (-b - float32(math.Sqrt(float64(disc)))) / a, true
+}
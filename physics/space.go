@@ -0,0 +1,467 @@
+package physics
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/tehcyx/goengine/bounds"
+)
+
+// Contact is a collision found during a step. B is nil when A hit the terrain.
+type Contact struct {
+	A, B    *Body
+	Normal  mgl32.Vec3 // from A to B
+	Depth   float32    // how far the bodies overlapped, 0 for the terrain which is never entered
+	Impulse float32    // how hard they hit, mass times the change in speed along the normal
+	Began   bool       // the bodies didn't touch in the step before
+}
+
+// contact is a body pair being resolved
+type contact struct {
+	a, b     *Body
+	normal   mgl32.Vec3
+	depth    float32
+	bounce   float32 // separating speed the restitution asks for
+	tangent  mgl32.Vec3
+	impulse  float32
+	friction float32 // accumulated friction impulse along tangent
+	feature  [3]int  // the block touched for the terrain
+}
+
+// pairKey finds a contact again in the next step to start resolving it from its
+// last impulse: the bodies and, for the terrain, the block they touch. It doesn't
+// depend on the normal, which turns a little every step a body rolls.
+type pairKey struct {
+	a, b    *Body
+	feature [3]int
+}
+
+// touchKey tells contacts apart to find the new ones. Terrain contacts are told
+// apart by the side they are on, so landing on the floor and hitting a wall are separate.
+type touchKey struct {
+	a, b *Body
+	side mgl32.Vec3
+}
+
+// Space simulates rigid bodies colliding with each other and the voxel terrain.
+// It is meant to be stepped at a fixed rate from the update of the game loop.
+// The terrain doesn't bounce and has a friction of 1.
+// Bodies that came to rest fall asleep and cost nothing until something hits
+// them, an impulse is applied or they are woken with WakeInside.
+type Space struct {
+	Grid       Grid // terrain, nil for none
+	Gravity    mgl32.Vec3
+	Iterations int           // solver passes per step, more makes stacks stiffer
+	SleepSpeed float32       // bodies slower than this are at rest
+	SleepTime  time.Duration // bodies at rest for this long fall asleep
+
+	bodies    []*Body
+	terrain   *Body // stands in for the terrain in contacts
+	touching  map[touchKey]bool
+	impulses  map[pairKey]float32 // of the last step, to start resolving from
+	listeners map[int]func(Contact)
+	nextID    int
+}
+
+// blockShape is the shape of a block of the terrain
+var blockShape = Box(mgl32.Vec3{0.5, 0.5, 0.5})
+
+// bounceSpeed is the speed below which bodies stop bouncing, so resting ones settle
+const bounceSpeed = 1
+
+// probe is how close a body has to be to the terrain to lean on it
+const probe = 0.01
+
+// slop is how far bodies may overlap before they are pushed apart, to keep
+// resting contacts from jittering
+const slop = 0.01
+
+// NewSpace creates a new space colliding with the terrain in g
+func NewSpace(g Grid) *Space {
+	s := new(Space)
+	s.Grid = g
+	s.Gravity = mgl32.Vec3{0, -28, 0}
+	s.Iterations = 8
+	s.SleepSpeed = 0.1
+	s.SleepTime = 500 * time.Millisecond
+	s.terrain = NewBody(Shape{}, mgl32.Vec3{}, 0)
+	s.terrain.Friction = 1
+	s.touching = make(map[touchKey]bool)
+	s.impulses = make(map[pairKey]float32)
+	s.listeners = make(map[int]func(Contact))
+	return s
+}
+
+// Add adds a body to the space
+func (s *Space) Add(b *Body) {
+	s.bodies = append(s.bodies, b)
+}
+
+// Remove takes a body out of the space
+func (s *Space) Remove(b *Body) {
+	for i, o := range s.bodies {
+		if o == b {
+			s.bodies = append(s.bodies[:i], s.bodies[i+1:]...)
+			break
+		}
+	}
+	for key := range s.touching {
+		if key.a == b || key.b == b {
+			delete(s.touching, key)
+		}
+	}
+}
+
+// Bodies returns the bodies in the space
+func (s *Space) Bodies() []*Body {
+	return s.bodies
+}
+
+// Subscribe calls fn for every contact of every step until unsubscribe is called
+func (s *Space) Subscribe(fn func(Contact)) (unsubscribe func()) {
+	id := s.nextID
+	s.nextID++
+	s.listeners[id] = fn
+	return func() {
+		delete(s.listeners, id)
+	}
+}
+
+// WakeInside wakes the bodies touching box, e.g. after the terrain below them changed
+func (s *Space) WakeInside(box bounds.AABB) {
+	for _, b := range s.bodies {
+		if b.sleeping && b.Bounds().Intersects(box) {
+			b.Wake()
+		}
+	}
+}
+
+// Step advances the simulation by dt, nothing happens for a dt that isn't positive
+func (s *Space) Step(dt time.Duration) {
+	if dt <= 0 {
+		return
+	}
+	t := float32(dt.Seconds())
+	// find the contacts before gravity pulls, so resting bodies don't look like
+	// they move and wake everything they lean on
+	contacts := s.contacts(t)
+	for _, b := range s.bodies {
+		if s.awake(b) {
+			b.Velocity = b.Velocity.Add(s.Gravity.Mul(t))
+		}
+	}
+
+	// resting contacts push about as hard as in the last step, starting from that
+	// lets stacks settle in a few iterations
+	for i := range contacts {
+		c := &contacts[i]
+		c.impulse = s.impulses[c.key()]
+		c.apply(c.normal.Mul(c.impulse))
+	}
+	for i := 0; i < s.Iterations; i++ {
+		for j := range contacts {
+			contacts[j].solve()
+		}
+	}
+	s.impulses = make(map[pairKey]float32, len(contacts))
+	for _, c := range contacts {
+		s.impulses[c.key()] = c.impulse
+	}
+
+	// move, pushing overlapping bodies apart on the way
+	correction := make(map[*Body]mgl32.Vec3)
+	for _, c := range contacts {
+		ia, ib := c.a.solverMass(), c.b.solverMass()
+		if ia+ib == 0 {
+			continue
+		}
+		push := c.normal.Mul(max32(c.depth-slop, 0) * 0.4 / (ia + ib))
+		correction[c.a] = correction[c.a].Sub(push.Mul(ia))
+		correction[c.b] = correction[c.b].Add(push.Mul(ib))
+	}
+	var events []Contact
+	for _, b := range s.bodies {
+		if !s.awake(b) {
+			continue
+		}
+		events = append(events, s.move(b, b.Velocity.Mul(t).Add(correction[b]))...)
+		if b.Velocity.Len() < s.SleepSpeed {
+			b.stillTime += dt
+			if b.stillTime >= s.SleepTime {
+				b.sleeping = true
+				b.Velocity = mgl32.Vec3{}
+			}
+		} else {
+			b.stillTime = 0
+		}
+	}
+	for _, c := range contacts {
+		e := Contact{A: c.a, B: c.b, Normal: c.normal, Depth: c.depth, Impulse: c.impulse}
+		if c.b == s.terrain {
+			e.B = nil
+		}
+		events = append(events, e)
+	}
+	s.notify(events)
+}
+
+func (s *Space) awake(b *Body) bool {
+	return !b.Static() && !b.sleeping
+}
+
+// contacts returns what the bodies touch. Contacts with the terrain come first
+// and the others from the bottom up, so weight rests on the ground sooner when
+// resolving them.
+func (s *Space) contacts(t float32) []contact {
+	boxes := make([]bounds.AABB, len(s.bodies))
+	for i, b := range s.bodies {
+		boxes[i] = b.Bounds()
+	}
+
+	var contacts []contact
+	if s.Grid != nil {
+		for i, b := range s.bodies {
+			if !s.awake(b) {
+				continue
+			}
+			// every block closer to the shape than probe
+			lo, hi := boxes[i].Min, boxes[i].Max
+			for x := floor(lo[0] - probe); x <= ceil(hi[0]+probe)-1; x++ {
+				for y := floor(lo[1] - probe); y <= ceil(hi[1]+probe)-1; y++ {
+					for z := floor(lo[2] - probe); z <= ceil(hi[2]+probe)-1; z++ {
+						if !s.Grid.Solid(x, y, z) {
+							continue
+						}
+						normal, dist := s.blockSeparation(b, [3]int{x, y, z})
+						if dist >= probe {
+							continue
+						}
+						c := s.newContact(b, s.terrain, normal, 0)
+						c.feature = [3]int{x, y, z}
+						if c.bounce == 0 {
+							// let it close the gap instead of hovering above the ground
+							c.bounce = -max32(dist-skin, 0) / t
+						}
+						contacts = append(contacts, c)
+					}
+				}
+			}
+		}
+	}
+
+	terrain := len(contacts)
+	for _, pair := range s.pairs(boxes) {
+		a, b := s.bodies[pair[0]], s.bodies[pair[1]]
+		normal, depth, ok := collide(a.Shape, a.Position, b.Shape, b.Position)
+		if !ok {
+			continue
+		}
+		// a moving body wakes a sleeping one, a resting one leans on it like on a static body
+		for _, p := range [2][2]*Body{{a, b}, {b, a}} {
+			if p[0].sleeping && s.awake(p[1]) && p[1].Velocity.Len() >= s.SleepSpeed {
+				p[0].Wake()
+			}
+		}
+		contacts = append(contacts, s.newContact(a, b, normal, depth))
+	}
+	bodies := contacts[terrain:]
+	sort.SliceStable(bodies, func(i, j int) bool {
+		return min32(bodies[i].a.Position[1], bodies[i].b.Position[1]) < min32(bodies[j].a.Position[1], bodies[j].b.Position[1])
+	})
+	return contacts
+}
+
+// blockSeparation returns the direction from a body to a solid block and the
+// distance between them. Faces covered by a neighbouring block aren't part of the
+// surface, so the direction only points at the exposed ones. Otherwise a body
+// sliding over the seam between two blocks would lean on the edge of the next.
+func (s *Space) blockSeparation(b *Body, block [3]int) (mgl32.Vec3, float32) {
+	center := mgl32.Vec3{float32(block[0]) + 0.5, float32(block[1]) + 0.5, float32(block[2]) + 0.5}
+	normal, dist := separation(b.Shape, b.Position, blockShape, center)
+	if dist+b.Shape.radius <= 0 {
+		// the inner box is inside the block
+		return normal, dist
+	}
+	gap := normal.Mul(dist + b.Shape.radius)
+	for i := range gap {
+		n := block
+		if gap[i] > 0 {
+			n[i]--
+		} else if gap[i] < 0 {
+			n[i]++
+		}
+		if n != block && s.Grid.Solid(n[0], n[1], n[2]) {
+			gap[i] = 0
+		}
+	}
+	l := gap.Len()
+	if l == 0 {
+		return normal, float32(math.Inf(1))
+	}
+	return gap.Mul(1 / l), l - b.Shape.radius
+}
+
+// pairs returns the indices of the bodies whose bounds overlap and of which at
+// least one is awake, found by sorting them along x and only comparing those
+// that overlap on it
+func (s *Space) pairs(boxes []bounds.AABB) [][2]int {
+	order := make([]int, len(boxes))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return boxes[order[i]].Min[0] < boxes[order[j]].Min[0]
+	})
+
+	var pairs [][2]int
+	for i, a := range order {
+		for _, b := range order[i+1:] {
+			if boxes[b].Min[0] > boxes[a].Max[0] {
+				break
+			}
+			if (s.awake(s.bodies[a]) || s.awake(s.bodies[b])) && boxes[a].Intersects(boxes[b]) {
+				pairs = append(pairs, [2]int{a, b})
+			}
+		}
+	}
+	return pairs
+}
+
+func (s *Space) newContact(a, b *Body, normal mgl32.Vec3, depth float32) contact {
+	c := contact{a: a, b: b, normal: normal, depth: depth}
+	rv := b.Velocity.Sub(a.Velocity)
+	if vn := rv.Dot(normal); -vn > bounceSpeed {
+		c.bounce = -vn * max32(a.Restitution, b.Restitution)
+	}
+	if tangent := rv.Sub(normal.Mul(rv.Dot(normal))); tangent.Len() > 1e-6 {
+		c.tangent = tangent.Normalize()
+	}
+	return c
+}
+
+func (c *contact) key() pairKey {
+	return pairKey{a: c.a, b: c.b, feature: c.feature}
+}
+
+// solverMass returns the inverse mass used while resolving contacts, sleeping
+// bodies don't give way
+func (b *Body) solverMass() float32 {
+	if b.sleeping {
+		return 0
+	}
+	return b.inverseMass()
+}
+
+// solve runs one pass of sequential impulses on the contact
+func (c *contact) solve() {
+	ia, ib := c.a.solverMass(), c.b.solverMass()
+	if ia+ib == 0 {
+		return
+	}
+	// push apart until they separate at the bounce speed, never pull together
+	vn := c.b.Velocity.Sub(c.a.Velocity).Dot(c.normal)
+	old := c.impulse
+	c.impulse = max32(old+(c.bounce-vn)/(ia+ib), 0)
+	c.apply(c.normal.Mul(c.impulse - old))
+
+	// friction slows the sliding, but never by more than the normal impulse allows
+	if c.tangent.Len() == 0 {
+		return
+	}
+	mu := float32(math.Sqrt(float64(c.a.Friction * c.b.Friction)))
+	vt := c.b.Velocity.Sub(c.a.Velocity).Dot(c.tangent)
+	old = c.friction
+	c.friction = mgl32.Clamp(old-vt/(ia+ib), -mu*c.impulse, mu*c.impulse)
+	c.apply(c.tangent.Mul(c.friction - old))
+}
+
+func (c *contact) apply(impulse mgl32.Vec3) {
+	c.a.Velocity = c.a.Velocity.Sub(impulse.Mul(c.a.solverMass()))
+	c.b.Velocity = c.b.Velocity.Add(impulse.Mul(c.b.solverMass()))
+}
+
+// move sweeps the body through the terrain, bouncing and rubbing off speed where
+// it hits, and returns the terrain contacts
+func (s *Space) move(b *Body, delta mgl32.Vec3) []Contact {
+	if s.Grid == nil {
+		b.Position = b.Position.Add(delta)
+		return nil
+	}
+	moved, normals := MoveShape(s.Grid, b.Shape, b.Position, delta)
+	b.Position = b.Position.Add(moved)
+
+	var contacts []Contact
+	for _, normal := range normals {
+		before := b.Velocity.Dot(normal)
+		if before >= 0 {
+			continue
+		}
+		after := float32(0)
+		if -before > bounceSpeed {
+			after = -before * b.Restitution
+		}
+		b.Velocity = b.Velocity.Add(normal.Mul(after - before))
+		change := after - before
+
+		// the rest slides along the terrain
+		slide := b.Velocity.Sub(normal.Mul(b.Velocity.Dot(normal)))
+		if speed := slide.Len(); speed > 0 {
+			b.Velocity = b.Velocity.Sub(slide.Mul(min32(b.Friction*change/speed, 1)))
+		}
+		contacts = append(contacts, Contact{A: b, Normal: normal.Mul(-1), Impulse: change * b.Mass})
+	}
+	return contacts
+}
+
+// side returns the axis direction closest to the normal
+func side(normal mgl32.Vec3) mgl32.Vec3 {
+	axis := 0
+	for i := 1; i < 3; i++ {
+		if math.Abs(float64(normal[i])) > math.Abs(float64(normal[axis])) {
+			axis = i
+		}
+	}
+	var v mgl32.Vec3
+	v[axis] = 1
+	if normal[axis] < 0 {
+		v[axis] = -1
+	}
+	return v
+}
+
+// notify merges the contacts of the same bodies, marks which ones are new and
+// hands them to the listeners
+func (s *Space) notify(events []Contact) {
+	touching := make(map[touchKey]bool, len(events))
+	// sleeping bodies keep touching what they lay on
+	for key := range s.touching {
+		if !s.awake(key.a) && (key.b == nil || !s.awake(key.b)) {
+			touching[key] = true
+		}
+	}
+	var merged []Contact
+	index := make(map[touchKey]int)
+	for _, e := range events {
+		key := touchKey{a: e.A, b: e.B}
+		if e.B == nil {
+			key.side = side(e.Normal)
+		}
+		if i, ok := index[key]; ok {
+			merged[i].Impulse += e.Impulse
+			merged[i].Depth = max32(merged[i].Depth, e.Depth)
+			continue
+		}
+		e.Began = !s.touching[key] && !s.touching[touchKey{a: e.B, b: e.A}]
+		index[key] = len(merged)
+		merged = append(merged, e)
+		touching[key] = true
+	}
+	s.touching = touching
+	for _, e := range merged {
+		for _, fn := range s.listeners {
+			fn(e)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package physics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// steps the space n times
+func simulate(s *Space, n int) {
+	for i := 0; i < n; i++ {
+		s.Step(step)
+	}
+}
+
+func TestSphereRollsOffEdge(t *testing.T) {
+	// a single block on the floor, the sphere falls just past its edge where its
+	// bounds but not the sphere itself would land on top
+	g := GridFunc(func(x, y, z int) bool { return y < 0 || x == 0 && y == 0 && z == 0 })
+	s := NewSpace(g)
+	b := NewBody(Sphere(0.3), mgl32.Vec3{1.1, 3, 0.5}, 1)
+	s.Add(b)
+	simulate(s, 180)
+	if !near32(b.Position.Y(), 0.3) || b.Position.X() < 1.3 {
+		t.Errorf("sphere came to rest at %v, want it rolled off onto the floor", b.Position)
+	}
+
+	// a box there lands on top
+	s = NewSpace(g)
+	b = NewBody(Box(mgl32.Vec3{0.3, 0.3, 0.3}), mgl32.Vec3{1.1, 3, 0.5}, 1)
+	s.Add(b)
+	simulate(s, 180)
+	if b.Position.Y() < 1.3-0.01 {
+		t.Errorf("box came to rest at %v, want it on top of the block", b.Position)
+	}
+}
+
+func TestShapesRestOnFloor(t *testing.T) {
+	tests := []struct {
+		name   string
+		shape  Shape
+		height float32 // of the center above the floor
+	}{
+		{"box", Box(mgl32.Vec3{0.25, 0.25, 0.25}), 0.25},
+		{"sphere", Sphere(0.3), 0.3},
+		{"capsule", Capsule(0.2, 0.8), 0.4},
+	}
+	for _, test := range tests {
+		s := NewSpace(GridFunc(flat))
+		b := NewBody(test.shape, mgl32.Vec3{0.5, 2, 0.5}, 1)
+		s.Add(b)
+		simulate(s, 120)
+		if !near32(b.Position.Y(), test.height) || !b.Sleeping() {
+			t.Errorf("%v: at %v sleeping %v, want it resting at %v", test.name, b.Position, b.Sleeping(), test.height)
+		}
+	}
+}
+
+func TestSphereRollsAcrossBlocks(t *testing.T) {
+	// the seams between the blocks of the floor don't catch a sliding sphere
+	s := NewSpace(GridFunc(flat))
+	b := NewBody(Sphere(0.3), mgl32.Vec3{0.5, 0.3 + skin, 0.5}, 1)
+	b.Friction = 0
+	s.Add(b)
+	simulate(s, 1)
+	b.Velocity = mgl32.Vec3{6, 0, 2}
+	for i := 0; i < 60; i++ {
+		s.Step(step)
+		if !near32(b.Position.Y(), 0.3) || b.Velocity.Y() > 0 {
+			t.Fatalf("step %v: sphere at %v moving %v, want it rolling on the floor", i, b.Position, b.Velocity)
+		}
+	}
+	if b.Position.X() < 5 || b.Position.Z() < 1.5 {
+		t.Errorf("sphere was slowed down to %v", b.Position)
+	}
+}
+
+func TestStepZero(t *testing.T) {
+	// the contact with the floor below doesn't divide by the zero step
+	s := NewSpace(GridFunc(flat))
+	start := mgl32.Vec3{0.5, 0.3 + 2*skin, 0.5}
+	b := NewBody(Sphere(0.3), start, 1)
+	b.Velocity = mgl32.Vec3{1, 0, 0}
+	s.Add(b)
+	for _, dt := range []time.Duration{0, -step} {
+		s.Step(dt)
+		if b.Position != start || b.Velocity != (mgl32.Vec3{1, 0, 0}) {
+			t.Errorf("Step(%v) moved the sphere to %v at %v", dt, b.Position, b.Velocity)
+		}
+	}
+	simulate(s, 60)
+	if !near32(b.Position.Y(), 0.3) {
+		t.Errorf("sphere is at %v after stepping on, want it on the floor", b.Position)
+	}
+}
+
+func TestWarmStartKey(t *testing.T) {
+	s := NewSpace(GridFunc(flat))
+	s.SleepTime = 1 << 62
+	b := NewBody(Sphere(0.3), mgl32.Vec3{0.5, 0.3 + skin, 0.5}, 2)
+	s.Add(b)
+	simulate(s, 30)
+	// the block below carries the weight from step to step
+	impulse := s.impulses[pairKey{a: b, b: s.terrain, feature: [3]int{0, -1, 0}}]
+	if want := b.Mass * -s.Gravity.Y() * float32(step.Seconds()); !near32(impulse, want) {
+		t.Errorf("cached impulse of the block below is %v, want %v", impulse, want)
+	}
+	if len(s.impulses) != 1 {
+		t.Errorf("got %v cached contacts, want 1: %v", len(s.impulses), s.impulses)
+	}
+}
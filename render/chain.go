@@ -0,0 +1,182 @@
+package render
+
+import (
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/tehcyx/goengine/shader"
+)
+
+// Pass is a full screen pass of a Chain. Its program is drawn over a triangle
+// covering the screen, with the output of the pass before bound to the sampler
+// "source" on unit 0 and the size of its texels in the vec2 "texelSize".
+type Pass struct {
+	Name    string
+	Enabled bool
+	Program *shader.Program
+	Params  map[string]float32 // float uniforms set before drawing
+
+	// Prepare is called before drawing with the input of the pass, for
+	// rendering extra inputs into targets of its own
+	Prepare func(input *Target)
+	// Bind is called with the program in use right before drawing, for binding
+	// extra textures
+	Bind func(p *shader.Program)
+
+	delete func()
+}
+
+// NewPass creates an enabled pass drawing with program
+func NewPass(name string, program *shader.Program) *Pass {
+	p := new(Pass)
+	p.Name = name
+	p.Enabled = true
+	p.Program = program
+	p.Params = make(map[string]float32)
+	return p
+}
+
+// Delete frees the program and whatever else the pass created
+func (p *Pass) Delete() {
+	if p.delete != nil {
+		p.delete()
+	}
+	p.Program.Delete()
+}
+
+// Chain runs a scene rendered into a Target through a list of passes, the
+// passes in between render into two targets taking turns
+type Chain struct {
+	Passes []*Pass
+
+	targets [2]*Target
+	vao     uint32
+}
+
+// NewChain creates an empty chain for scenes of width by height pixels, must be called on the GL thread
+func NewChain(width, height int32) (*Chain, error) {
+	c := new(Chain)
+	for i := range c.targets {
+		t, err := NewTarget(width, height, 0, gl.RGBA16F, false)
+		if err != nil {
+			c.Delete()
+			return nil, err
+		}
+		c.targets[i] = t
+	}
+	// the full screen triangle is made up from gl_VertexID, but core GL wants a vertex array anyway
+	gl.GenVertexArrays(1, &c.vao)
+	return c, nil
+}
+
+// Add appends a pass and returns it
+func (c *Chain) Add(p *Pass) *Pass {
+	c.Passes = append(c.Passes, p)
+	return p
+}
+
+// Pass returns the pass with the name, nil if there is none
+func (c *Chain) Pass(name string) *Pass {
+	for _, p := range c.Passes {
+		if p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// Toggle enables or disables the named pass and returns whether it's enabled now
+func (c *Chain) Toggle(name string) bool {
+	p := c.Pass(name)
+	if p == nil {
+		return false
+	}
+	p.Enabled = !p.Enabled
+	return p.Enabled
+}
+
+// Resize resizes the targets between the passes
+func (c *Chain) Resize(width, height int32) error {
+	for _, t := range c.targets {
+		if err := t.Resize(width, height); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run resolves source and draws it through the enabled passes into the
+// framebuffer fbo of width by height pixels, 0 is the window. Without enabled
+// passes source is copied as it is.
+func (c *Chain) Run(source *Target, fbo uint32, width, height int32) {
+	source.Resolve()
+	var passes []*Pass
+	for _, p := range c.Passes {
+		if p.Enabled {
+			passes = append(passes, p)
+		}
+	}
+	if len(passes) == 0 {
+		source.BlitTo(fbo, width, height)
+		gl.Viewport(0, 0, width, height)
+		return
+	}
+
+	depthTest, blend := gl.IsEnabled(gl.DEPTH_TEST), gl.IsEnabled(gl.BLEND)
+	gl.Disable(gl.DEPTH_TEST)
+	gl.Disable(gl.BLEND)
+	gl.BindVertexArray(c.vao)
+
+	input := source
+	for i, p := range passes {
+		if p.Prepare != nil {
+			p.Prepare(input)
+		}
+		var output *Target
+		if i == len(passes)-1 {
+			gl.BindFramebuffer(gl.FRAMEBUFFER, fbo)
+			gl.Viewport(0, 0, width, height)
+		} else {
+			output = c.targets[i%2]
+			output.Bind()
+		}
+		p.Program.Use()
+		input.BindTexture(0)
+		gl.Uniform1i(p.Program.Uniform("source"), 0)
+		gl.Uniform2f(p.Program.Uniform("texelSize"), 1/float32(input.Width), 1/float32(input.Height))
+		for name, value := range p.Params {
+			gl.Uniform1f(p.Program.Uniform(name), value)
+		}
+		if p.Bind != nil {
+			p.Bind(p.Program)
+		}
+		drawFullscreen()
+		input = output
+	}
+
+	gl.BindVertexArray(0)
+	if depthTest {
+		gl.Enable(gl.DEPTH_TEST)
+	}
+	if blend {
+		gl.Enable(gl.BLEND)
+	}
+}
+
+// drawFullscreen draws the triangle covering the screen, the vertex array of
+// the chain has to be bound
+func drawFullscreen() {
+	gl.DrawArrays(gl.TRIANGLES, 0, 3)
+}
+
+// Delete frees the targets and the passes, must be called on the GL thread
+func (c *Chain) Delete() {
+	for _, p := range c.Passes {
+		p.Delete()
+	}
+	c.Passes = nil
+	for _, t := range c.targets {
+		if t != nil {
+			t.Delete()
+		}
+	}
+	gl.DeleteVertexArrays(1, &c.vao)
+}
@@ -0,0 +1,178 @@
+package render
+
+import (
+	"path/filepath"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/tehcyx/goengine/shader"
+)
+
+// loadPass creates a pass drawing fragment, a shader in dir next to fullscreen.vert
+func loadPass(name, dir, fragment string) (*Pass, error) {
+	p, err := loadProgram(dir, fragment)
+	if err != nil {
+		return nil, err
+	}
+	return NewPass(name, p), nil
+}
+
+func loadProgram(dir, fragment string) (*shader.Program, error) {
+	return shader.Load(filepath.Join(dir, "fullscreen.vert"), filepath.Join(dir, fragment))
+}
+
+// NewTonemap creates a pass mapping HDR colors to 0 to 1 with the ACES filmic
+// curve, after scaling them by the param "exposure". The shaders are in dir.
+func NewTonemap(dir string) (*Pass, error) {
+	p, err := loadPass("tonemap", dir, "tonemap.frag")
+	if err != nil {
+		return nil, err
+	}
+	p.Params["exposure"] = 1
+	return p, nil
+}
+
+// NewGamma creates a pass encoding linear colors for the screen with the param "gamma"
+func NewGamma(dir string) (*Pass, error) {
+	p, err := loadPass("gamma", dir, "gamma.frag")
+	if err != nil {
+		return nil, err
+	}
+	p.Params["gamma"] = 2.2
+	return p, nil
+}
+
+// NewFXAA creates a pass smoothing jagged edges, it belongs after tonemapping
+// and gamma correction since it works on perceived brightness
+func NewFXAA(dir string) (*Pass, error) {
+	return loadPass("fxaa", dir, "fxaa.frag")
+}
+
+// NewVignette creates a pass darkening the corners. The param "strength" is
+// how dark the corners get, "radius" where the darkening starts, 0 in the
+// middle and 1 in the corners.
+func NewVignette(dir string) (*Pass, error) {
+	p, err := loadPass("vignette", dir, "vignette.frag")
+	if err != nil {
+		return nil, err
+	}
+	p.Params["strength"] = 0.4
+	p.Params["radius"] = 0.5
+	return p, nil
+}
+
+// bloomBlurs is how often the bright parts are blurred in both directions
+const bloomBlurs = 3
+
+// bloom blurs the parts brighter than a threshold at half the resolution
+type bloom struct {
+	pass         *Pass
+	bright, blur *shader.Program
+	targets      [2]*Target
+}
+
+// NewBloom creates a pass making the parts brighter than the param
+// "threshold" glow, "strength" scales the glow. It belongs before tonemapping.
+func NewBloom(dir string) (*Pass, error) {
+	b := new(bloom)
+	var err error
+	if b.bright, err = loadProgram(dir, "bright.frag"); err != nil {
+		return nil, err
+	}
+	if b.blur, err = loadProgram(dir, "blur.frag"); err != nil {
+		b.bright.Delete()
+		return nil, err
+	}
+	b.pass, err = loadPass("bloom", dir, "bloom.frag")
+	if err != nil {
+		b.bright.Delete()
+		b.blur.Delete()
+		return nil, err
+	}
+	b.pass.Params["threshold"] = 1
+	b.pass.Params["strength"] = 0.5
+	b.pass.Prepare = b.prepare
+	b.pass.Bind = b.bind
+	b.pass.delete = b.delete
+	return b.pass, nil
+}
+
+func (b *bloom) prepare(input *Target) {
+	width, height := input.Width/2, input.Height/2
+	if width < 1 {
+		width = 1
+	}
+	if height < 1 {
+		height = 1
+	}
+	for i, t := range b.targets {
+		var err error
+		if t == nil {
+			t, err = NewTarget(width, height, 0, gl.RGBA16F, false)
+			b.targets[i] = t
+		} else if t.Width != width || t.Height != height {
+			err = t.Resize(width, height)
+		}
+		if err != nil {
+			// no glow is better than no frame
+			return
+		}
+	}
+
+	b.bright.Use()
+	gl.Uniform1i(b.bright.Uniform("source"), 0)
+	gl.Uniform1f(b.bright.Uniform("threshold"), b.pass.Params["threshold"])
+	b.targets[0].Bind()
+	input.BindTexture(0)
+	drawFullscreen()
+
+	b.blur.Use()
+	gl.Uniform1i(b.blur.Uniform("source"), 0)
+	for i := 0; i < bloomBlurs; i++ {
+		b.targets[1].Bind()
+		b.targets[0].BindTexture(0)
+		gl.Uniform2f(b.blur.Uniform("direction"), 1/float32(width), 0)
+		drawFullscreen()
+		b.targets[0].Bind()
+		b.targets[1].BindTexture(0)
+		gl.Uniform2f(b.blur.Uniform("direction"), 0, 1/float32(height))
+		drawFullscreen()
+	}
+}
+
+func (b *bloom) bind(p *shader.Program) {
+	if b.targets[0] != nil {
+		b.targets[0].BindTexture(1)
+	} else {
+		gl.ActiveTexture(gl.TEXTURE1)
+		gl.BindTexture(gl.TEXTURE_2D, 0)
+	}
+	gl.Uniform1i(p.Uniform("bloom"), 1)
+}
+
+func (b *bloom) delete() {
+	b.bright.Delete()
+	b.blur.Delete()
+	for _, t := range b.targets {
+		if t != nil {
+			t.Delete()
+		}
+	}
+}
+
+// NewDefaultChain creates a chain with bloom, tonemapping, gamma correction,
+// FXAA and a vignette, loading the shaders from dir
+func NewDefaultChain(width, height int32, dir string) (*Chain, error) {
+	c, err := NewChain(width, height)
+	if err != nil {
+		return nil, err
+	}
+	for _, create := range []func(string) (*Pass, error){NewBloom, NewTonemap, NewGamma, NewFXAA, NewVignette} {
+		p, err := create(dir)
+		if err != nil {
+			c.Delete()
+			return nil, err
+		}
+		c.Add(p)
+	}
+	return c, nil
+}
@@ -0,0 +1,197 @@
+// Package render draws into offscreen framebuffers and runs chains of full
+// screen post processing passes over them
+package render
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+)
+
+// Target is an offscreen framebuffer with a color texture and an optional depth
+// buffer. With more than one sample it renders into multisampled buffers, which
+// Resolve downsamples into the texture.
+type Target struct {
+	Width, Height int32
+	Samples       int32  // 0 or 1 for no multisampling
+	Format        uint32 // internal format of the color texture, e.g. gl.RGBA16F for HDR
+	Depth         bool   // whether it has a depth buffer
+
+	fbo, color, depth uint32 // what the texture is read from
+	msaaFBO           uint32 // what is drawn into with multisampling
+	msaaColor         uint32
+	msaaDepth         uint32
+}
+
+// NewTarget creates a render target of width by height pixels, must be called on the GL thread
+func NewTarget(width, height, samples int32, format uint32, depth bool) (*Target, error) {
+	t := new(Target)
+	t.Samples = samples
+	t.Format = format
+	t.Depth = depth
+	if err := t.Resize(width, height); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Resize recreates the buffers in a new size, the contents are lost
+func (t *Target) Resize(width, height int32) error {
+	t.Delete()
+	t.Width, t.Height = width, height
+
+	var previous int32
+	gl.GetIntegerv(gl.FRAMEBUFFER_BINDING, &previous)
+	defer gl.BindFramebuffer(gl.FRAMEBUFFER, uint32(previous))
+
+	gl.GenTextures(1, &t.color)
+	gl.BindTexture(gl.TEXTURE_2D, t.color)
+	gl.TexImage2D(gl.TEXTURE_2D, 0, int32(t.Format), width, height, 0, gl.RGBA, gl.FLOAT, nil)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+	gl.BindTexture(gl.TEXTURE_2D, 0)
+
+	gl.GenFramebuffers(1, &t.fbo)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, t.fbo)
+	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_2D, t.color, 0)
+	if t.Depth && !t.multisampled() {
+		t.depth = renderbuffer(gl.DEPTH_COMPONENT24, 0, width, height)
+		gl.FramebufferRenderbuffer(gl.FRAMEBUFFER, gl.DEPTH_ATTACHMENT, gl.RENDERBUFFER, t.depth)
+	}
+	if status := gl.CheckFramebufferStatus(gl.FRAMEBUFFER); status != gl.FRAMEBUFFER_COMPLETE {
+		t.Delete()
+		return fmt.Errorf("failed to create render target: framebuffer status 0x%x", status)
+	}
+	if !t.multisampled() {
+		return nil
+	}
+
+	gl.GenFramebuffers(1, &t.msaaFBO)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, t.msaaFBO)
+	t.msaaColor = renderbuffer(t.Format, t.Samples, width, height)
+	gl.FramebufferRenderbuffer(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.RENDERBUFFER, t.msaaColor)
+	if t.Depth {
+		t.msaaDepth = renderbuffer(gl.DEPTH_COMPONENT24, t.Samples, width, height)
+		gl.FramebufferRenderbuffer(gl.FRAMEBUFFER, gl.DEPTH_ATTACHMENT, gl.RENDERBUFFER, t.msaaDepth)
+	}
+	if status := gl.CheckFramebufferStatus(gl.FRAMEBUFFER); status != gl.FRAMEBUFFER_COMPLETE {
+		t.Delete()
+		return fmt.Errorf("failed to create render target with %v samples: framebuffer status 0x%x", t.Samples, status)
+	}
+	return nil
+}
+
+func renderbuffer(format uint32, samples, width, height int32) uint32 {
+	var id uint32
+	gl.GenRenderbuffers(1, &id)
+	gl.BindRenderbuffer(gl.RENDERBUFFER, id)
+	if samples > 1 {
+		gl.RenderbufferStorageMultisample(gl.RENDERBUFFER, samples, format, width, height)
+	} else {
+		gl.RenderbufferStorage(gl.RENDERBUFFER, format, width, height)
+	}
+	gl.BindRenderbuffer(gl.RENDERBUFFER, 0)
+	return id
+}
+
+func (t *Target) multisampled() bool {
+	return t.Samples > 1
+}
+
+// Bind makes the target the one drawn into and covers it with the viewport
+func (t *Target) Bind() {
+	if t.multisampled() {
+		gl.BindFramebuffer(gl.FRAMEBUFFER, t.msaaFBO)
+	} else {
+		gl.BindFramebuffer(gl.FRAMEBUFFER, t.fbo)
+	}
+	gl.Viewport(0, 0, t.Width, t.Height)
+}
+
+// Resolve downsamples what was drawn with multisampling into the texture, it
+// does nothing without multisampling. The framebuffer drawn into stays bound.
+func (t *Target) Resolve() {
+	if !t.multisampled() {
+		return
+	}
+	var previous int32
+	gl.GetIntegerv(gl.FRAMEBUFFER_BINDING, &previous)
+	gl.BindFramebuffer(gl.READ_FRAMEBUFFER, t.msaaFBO)
+	gl.BindFramebuffer(gl.DRAW_FRAMEBUFFER, t.fbo)
+	gl.BlitFramebuffer(0, 0, t.Width, t.Height, 0, 0, t.Width, t.Height, gl.COLOR_BUFFER_BIT, gl.NEAREST)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, uint32(previous))
+}
+
+// BlitTo copies the texture into a framebuffer of width by height pixels,
+// 0 is the window
+func (t *Target) BlitTo(fbo uint32, width, height int32) {
+	gl.BindFramebuffer(gl.READ_FRAMEBUFFER, t.fbo)
+	gl.BindFramebuffer(gl.DRAW_FRAMEBUFFER, fbo)
+	gl.BlitFramebuffer(0, 0, t.Width, t.Height, 0, 0, width, height, gl.COLOR_BUFFER_BIT, gl.LINEAR)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, fbo)
+}
+
+// ReadPixels resolves the target and reads it back, top row first like images
+// are stored. Must be called on the GL thread.
+func (t *Target) ReadPixels() *image.RGBA {
+	t.Resolve()
+	var previous int32
+	gl.GetIntegerv(gl.READ_FRAMEBUFFER_BINDING, &previous)
+	gl.BindFramebuffer(gl.READ_FRAMEBUFFER, t.fbo)
+	img := ReadPixels(0, 0, t.Width, t.Height)
+	gl.BindFramebuffer(gl.READ_FRAMEBUFFER, uint32(previous))
+	return img
+}
+
+// ReadPixels reads a rectangle of the framebuffer bound for reading, top row
+// first like images are stored. Must be called on the GL thread.
+func ReadPixels(x, y, width, height int32) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, int(width), int(height)))
+	gl.PixelStorei(gl.PACK_ALIGNMENT, 1)
+	gl.ReadPixels(x, y, width, height, gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(img.Pix))
+	FlipRows(img)
+	return img
+}
+
+// FlipRows turns an image upside down in place, GL reads the bottom row first
+func FlipRows(img *image.RGBA) {
+	h := img.Rect.Dy()
+	row := make([]uint8, img.Rect.Dx()*4)
+	for y := 0; y < h/2; y++ {
+		top := img.Pix[y*img.Stride : y*img.Stride+len(row)]
+		bottom := img.Pix[(h-1-y)*img.Stride : (h-1-y)*img.Stride+len(row)]
+		copy(row, top)
+		copy(top, bottom)
+		copy(bottom, row)
+	}
+}
+
+// BindTexture binds the color texture to a texture unit
+func (t *Target) BindTexture(unit uint32) {
+	gl.ActiveTexture(gl.TEXTURE0 + unit)
+	gl.BindTexture(gl.TEXTURE_2D, t.color)
+}
+
+// Texture returns the name of the color texture
+func (t *Target) Texture() uint32 {
+	return t.color
+}
+
+// Framebuffer returns the name of the framebuffer holding the texture
+func (t *Target) Framebuffer() uint32 {
+	return t.fbo
+}
+
+// Delete frees the buffers, must be called on the GL thread
+func (t *Target) Delete() {
+	gl.DeleteFramebuffers(1, &t.fbo)
+	gl.DeleteFramebuffers(1, &t.msaaFBO)
+	gl.DeleteTextures(1, &t.color)
+	gl.DeleteRenderbuffers(1, &t.depth)
+	gl.DeleteRenderbuffers(1, &t.msaaColor)
+	gl.DeleteRenderbuffers(1, &t.msaaDepth)
+	t.fbo, t.msaaFBO, t.color, t.depth, t.msaaColor, t.msaaDepth = 0, 0, 0, 0, 0, 0
+}
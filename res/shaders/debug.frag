@@ -0,0 +1,6 @@
+#version 330
+in vec4 fragColor;
+out vec4 outputColor;
+void main() {
+    outputColor = fragColor;
+}
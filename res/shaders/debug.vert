@@ -0,0 +1,10 @@
+#version 330
+uniform mat4 viewProjection;
+layout(location = 0) in vec3 vert;
+layout(location = 4) in vec4 vertColor;
+out vec4 fragColor;
+void main() {
+    // colors are picked in sRGB like block colors, the frame is linear
+    fragColor = vec4(pow(vertColor.rgb, vec3(2.2)), vertColor.a);
+    gl_Position = viewProjection * vec4(vert, 1);
+}
@@ -0,0 +1,4 @@
+#version 330
+// only depth is written
+void main() {
+}
@@ -0,0 +1,7 @@
+#version 330
+uniform mat4 viewProjection;
+uniform mat4 model;
+layout(location = 0) in vec3 vert;
+void main() {
+    gl_Position = viewProjection * model * vec4(vert, 1);
+}
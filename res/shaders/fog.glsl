@@ -0,0 +1,11 @@
+// exponential squared fog from fogStart on, it begins thin and closes in quickly
+uniform vec3 fogColor;
+uniform float fogStart;
+uniform float fogDensity;
+
+vec4 applyFog(vec4 color, float distance) {
+    float d = fogDensity * max(distance - fogStart, 0.0);
+    float fog = 1.0 - exp(-d * d);
+    // translucent surfaces become opaque in the fog, or the sky would show through them
+    return vec4(mix(color.rgb, fogColor, fog), mix(color.a, 1.0, fog));
+}
@@ -0,0 +1,63 @@
+// Blinn-Phong lighting from the Lights uniform buffer, see the light package
+
+struct PointLight {
+    vec4 position; // w is the range
+    vec4 color;
+};
+
+struct SpotLight {
+    vec4 position;  // w is the range
+    vec4 direction; // w is the cosine of the outer angle
+    vec4 color;     // w is the cosine of the inner angle
+};
+
+layout(std140) uniform Lights {
+    vec4 ambient;
+    vec4 sunDirection; // towards the sun
+    vec4 sunColor;
+    ivec4 lightCounts; // points, spots
+    PointLight pointLights[16];
+    SpotLight spotLights[8];
+};
+
+// blinnPhong returns the diffuse and specular light from a direction
+vec3 blinnPhong(vec3 normal, vec3 toLight, vec3 toEye, vec3 color, vec3 albedo, vec3 specular, float shininess) {
+    float diffuse = max(dot(normal, toLight), 0.0);
+    if (diffuse == 0.0) {
+        return vec3(0.0);
+    }
+    vec3 halfway = normalize(toLight + toEye);
+    float highlight = shininess > 0.0 ? pow(max(dot(normal, halfway), 0.0), shininess) : 0.0;
+    return color * (albedo * diffuse + specular * highlight);
+}
+
+// attenuation fades light smoothly to 0 at its range
+float attenuation(float dist, float range) {
+    float fade = clamp(1.0 - pow(dist / range, 4.0), 0.0, 1.0);
+    return fade * fade / (dist * dist + 1.0);
+}
+
+// sunLight returns the ambient and sun light on a surface, shadow scales the
+// sun from 0 to 1
+vec3 sunLight(vec3 normal, vec3 toEye, vec3 albedo, vec3 specular, float shininess, float shadow) {
+    return ambient.rgb * albedo + shadow * blinnPhong(normal, sunDirection.xyz, toEye, sunColor.rgb, albedo, specular, shininess);
+}
+
+// localLights returns the light of the point and spot lights on a surface
+vec3 localLights(vec3 position, vec3 normal, vec3 toEye, vec3 albedo, vec3 specular, float shininess) {
+    vec3 result = vec3(0.0);
+    for (int i = 0; i < lightCounts.x; i++) {
+        vec3 toLight = pointLights[i].position.xyz - position;
+        float dist = length(toLight);
+        float a = attenuation(dist, pointLights[i].position.w);
+        result += a * blinnPhong(normal, toLight / dist, toEye, pointLights[i].color.rgb, albedo, specular, shininess);
+    }
+    for (int i = 0; i < lightCounts.y; i++) {
+        vec3 toLight = spotLights[i].position.xyz - position;
+        float dist = length(toLight);
+        float cone = smoothstep(spotLights[i].direction.w, spotLights[i].color.w, dot(-toLight / dist, spotLights[i].direction.xyz));
+        float a = cone * attenuation(dist, spotLights[i].position.w);
+        result += a * blinnPhong(normal, toLight / dist, toEye, spotLights[i].color.rgb, albedo, specular, shininess);
+    }
+    return result;
+}
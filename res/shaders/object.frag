@@ -0,0 +1,19 @@
+#version 330
+#include "lighting.glsl"
+uniform vec3 eye;
+uniform sampler2D diffuseMap;
+uniform vec4 diffuse;
+uniform vec3 specular;
+uniform float shininess;
+in vec2 fragTexCoord;
+in vec3 fragPosition;
+in vec3 fragNormal;
+out vec4 outputColor;
+void main() {
+    vec4 albedo = texture(diffuseMap, fragTexCoord) * diffuse;
+    vec3 normal = normalize(fragNormal);
+    vec3 toEye = normalize(eye - fragPosition);
+    vec3 color = sunLight(normal, toEye, albedo.rgb, specular, shininess, 1.0);
+    color += localLights(fragPosition, normal, toEye, albedo.rgb, specular, shininess);
+    outputColor = vec4(color, albedo.a);
+}
@@ -0,0 +1,17 @@
+#version 330
+uniform mat4 projection;
+uniform mat4 camera;
+uniform mat4 model;
+layout(location = 0) in vec3 vert;
+layout(location = 1) in vec2 vertTexCoord;
+layout(location = 2) in vec3 vertNormal;
+out vec2 fragTexCoord;
+out vec3 fragPosition;
+out vec3 fragNormal;
+void main() {
+    fragTexCoord = vertTexCoord;
+    vec4 position = model * vec4(vert, 1);
+    fragPosition = position.xyz;
+    fragNormal = mat3(transpose(inverse(model))) * vertNormal;
+    gl_Position = projection * camera * position;
+}
@@ -0,0 +1,10 @@
+#version 330
+uniform sampler2D source;
+uniform sampler2D bloom;
+uniform float strength;
+in vec2 fragTexCoord;
+out vec4 outputColor;
+void main() {
+    vec4 color = texture(source, fragTexCoord);
+    outputColor = vec4(color.rgb + texture(bloom, fragTexCoord).rgb * strength, color.a);
+}
@@ -0,0 +1,16 @@
+#version 330
+// 9 tap gaussian blur along direction, taking 5 samples between texels
+uniform sampler2D source;
+uniform vec2 direction; // one texel along the blur
+in vec2 fragTexCoord;
+out vec4 outputColor;
+const float offsets[3] = float[](0.0, 1.3846153846, 3.2307692308);
+const float weights[3] = float[](0.2270270270, 0.3162162162, 0.0702702703);
+void main() {
+    vec3 color = texture(source, fragTexCoord).rgb * weights[0];
+    for (int i = 1; i < 3; i++) {
+        color += texture(source, fragTexCoord + direction * offsets[i]).rgb * weights[i];
+        color += texture(source, fragTexCoord - direction * offsets[i]).rgb * weights[i];
+    }
+    outputColor = vec4(color, 1.0);
+}
@@ -0,0 +1,11 @@
+#version 330
+uniform sampler2D source;
+uniform float threshold;
+in vec2 fragTexCoord;
+out vec4 outputColor;
+void main() {
+    vec3 color = texture(source, fragTexCoord).rgb;
+    float brightness = max(color.r, max(color.g, color.b));
+    // keep what is above the threshold without changing the hue
+    outputColor = vec4(color * max(brightness - threshold, 0.0) / max(brightness, 0.0001), 1.0);
+}
@@ -0,0 +1,8 @@
+#version 330
+out vec2 fragTexCoord;
+void main() {
+    // one triangle covering the screen, made up from the vertex index
+    vec2 corner = vec2((gl_VertexID << 1) & 2, gl_VertexID & 2);
+    fragTexCoord = corner;
+    gl_Position = vec4(corner * 2.0 - 1.0, 0.0, 1.0);
+}
@@ -0,0 +1,40 @@
+#version 330
+// FXAA after Timothy Lottes, blurring along the edges found by the luma of the corners
+uniform sampler2D source;
+uniform vec2 texelSize;
+in vec2 fragTexCoord;
+out vec4 outputColor;
+const float spanMax = 8.0;
+const float reduceMul = 1.0 / 8.0;
+const float reduceMin = 1.0 / 128.0;
+float luma(vec3 color) {
+    return dot(color, vec3(0.299, 0.587, 0.114));
+}
+vec3 at(vec2 offset) {
+    return texture(source, fragTexCoord + offset).rgb;
+}
+void main() {
+    vec4 center = texture(source, fragTexCoord);
+    float lumaNW = luma(at(vec2(-1.0, -1.0) * texelSize));
+    float lumaNE = luma(at(vec2(1.0, -1.0) * texelSize));
+    float lumaSW = luma(at(vec2(-1.0, 1.0) * texelSize));
+    float lumaSE = luma(at(vec2(1.0, 1.0) * texelSize));
+    float lumaM = luma(center.rgb);
+    float lumaMin = min(lumaM, min(min(lumaNW, lumaNE), min(lumaSW, lumaSE)));
+    float lumaMax = max(lumaM, max(max(lumaNW, lumaNE), max(lumaSW, lumaSE)));
+
+    vec2 dir = vec2(-((lumaNW + lumaNE) - (lumaSW + lumaSE)), (lumaNW + lumaSW) - (lumaNE + lumaSE));
+    float dirReduce = max((lumaNW + lumaNE + lumaSW + lumaSE) * 0.25 * reduceMul, reduceMin);
+    float rcpDirMin = 1.0 / (min(abs(dir.x), abs(dir.y)) + dirReduce);
+    dir = clamp(dir * rcpDirMin, -spanMax, spanMax) * texelSize;
+
+    vec3 colorA = 0.5 * (at(dir * (1.0 / 3.0 - 0.5)) + at(dir * (2.0 / 3.0 - 0.5)));
+    vec3 colorB = colorA * 0.5 + 0.25 * (at(dir * -0.5) + at(dir * 0.5));
+    float lumaB = luma(colorB);
+    // the wider sample reached past the edge, stick to the narrow one
+    if (lumaB < lumaMin || lumaB > lumaMax) {
+        outputColor = vec4(colorA, center.a);
+    } else {
+        outputColor = vec4(colorB, center.a);
+    }
+}
@@ -0,0 +1,9 @@
+#version 330
+uniform sampler2D source;
+uniform float gamma;
+in vec2 fragTexCoord;
+out vec4 outputColor;
+void main() {
+    vec4 color = texture(source, fragTexCoord);
+    outputColor = vec4(pow(max(color.rgb, 0.0), vec3(1.0 / gamma)), color.a);
+}
@@ -0,0 +1,13 @@
+#version 330
+uniform sampler2D source;
+uniform float exposure;
+in vec2 fragTexCoord;
+out vec4 outputColor;
+// fit of the ACES filmic curve by Krzysztof Narkowicz
+vec3 aces(vec3 x) {
+    return clamp((x * (2.51 * x + 0.03)) / (x * (2.43 * x + 0.59) + 0.14), 0.0, 1.0);
+}
+void main() {
+    vec4 color = texture(source, fragTexCoord);
+    outputColor = vec4(aces(color.rgb * exposure), color.a);
+}
@@ -0,0 +1,12 @@
+#version 330
+uniform sampler2D source;
+uniform float strength;
+uniform float radius;
+in vec2 fragTexCoord;
+out vec4 outputColor;
+void main() {
+    vec4 color = texture(source, fragTexCoord);
+    // 0 in the middle, 1 in the corners
+    float d = length(fragTexCoord - 0.5) * 1.41421356;
+    outputColor = vec4(color.rgb * (1.0 - strength * smoothstep(radius, 1.0, d)), color.a);
+}
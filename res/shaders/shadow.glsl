@@ -0,0 +1,51 @@
+// Cascaded sun shadows, see the shadow package
+
+uniform sampler2DArrayShadow shadowMap;
+uniform mat4 shadowMatrices[4];
+uniform float cascadeSplits[4]; // far distance of every cascade from the camera
+uniform float cascadeTexels[4]; // world size of a texel of every cascade
+uniform int cascadeCount;
+
+// cascadeOf returns the cascade covering a distance from the camera, -1 past the last one
+int cascadeOf(float viewDepth) {
+    for (int i = 0; i < cascadeCount; i++) {
+        if (viewDepth < cascadeSplits[i]) {
+            return i;
+        }
+    }
+    return -1;
+}
+
+// sunShadow returns how much sun reaches a surface, from 0 in full shadow to 1
+float sunShadow(vec3 position, vec3 normal, float viewDepth) {
+    int cascade = cascadeOf(viewDepth);
+    if (cascade < 0) {
+        return 1.0;
+    }
+    // looking up a bit in front of the surface keeps it from shadowing itself
+    vec3 offset = normal * cascadeTexels[cascade] * 1.5;
+    vec4 p = shadowMatrices[cascade] * vec4(position + offset, 1.0);
+    vec3 uv = p.xyz / p.w * 0.5 + 0.5;
+    if (uv.z > 1.0) {
+        return 1.0;
+    }
+    // 3x3 percentage closer filtering, every lookup already averages 2x2 comparisons
+    vec2 texel = 1.0 / vec2(textureSize(shadowMap, 0).xy);
+    float lit = 0.0;
+    for (int y = -1; y <= 1; y++) {
+        for (int x = -1; x <= 1; x++) {
+            lit += texture(shadowMap, vec4(uv.xy + vec2(x, y) * texel, float(cascade), uv.z));
+        }
+    }
+    return lit / 9.0;
+}
+
+// cascadeColor tints every cascade differently, to debug their splits
+vec3 cascadeColor(float viewDepth) {
+    int cascade = cascadeOf(viewDepth);
+    if (cascade == 0) return vec3(1.0, 0.4, 0.4);
+    if (cascade == 1) return vec3(0.4, 1.0, 0.4);
+    if (cascade == 2) return vec3(0.4, 0.4, 1.0);
+    if (cascade == 3) return vec3(1.0, 1.0, 0.4);
+    return vec3(1.0);
+}
@@ -0,0 +1,29 @@
+#version 330
+uniform vec3 zenith;
+uniform vec3 horizon;
+uniform vec3 toSun;
+uniform vec3 sunColor;
+uniform float daylight;
+in vec3 fragDirection;
+out vec4 outputColor;
+float hash(vec3 p) {
+    return fract(sin(dot(p, vec3(12.9898, 78.233, 45.164))) * 43758.5453);
+}
+void main() {
+    vec3 direction = normalize(fragDirection);
+    // the ground below the horizon fades to a darker horizon
+    vec3 color = direction.y > 0.0
+        ? mix(horizon, zenith, pow(direction.y, 0.5))
+        : mix(horizon, horizon * 0.3, min(-direction.y * 4.0, 1.0));
+
+    float sun = dot(direction, toSun);
+    color += sunColor * (smoothstep(0.9995, 0.9997, sun) * 20.0 + pow(max(sun, 0.0), 200.0) * 0.5);
+    float moon = dot(direction, -toSun);
+    color += vec3(0.6, 0.7, 1.0) * smoothstep(0.9996, 0.9998, moon) * 2.0 * (1.0 - daylight);
+
+    // stars are single cells of a grid over the sky, hidden by the day
+    vec3 cell = floor(direction * 300.0);
+    float star = step(0.998, hash(cell)) * hash(cell + 1.0);
+    color += vec3(star) * (1.0 - daylight) * step(0.0, direction.y);
+    outputColor = vec4(color, 1.0);
+}
@@ -0,0 +1,10 @@
+#version 330
+uniform mat4 inverseViewProjection;
+out vec3 fragDirection;
+void main() {
+    // one triangle covering the screen, made up from the vertex index
+    vec2 corner = vec2((gl_VertexID << 1) & 2, gl_VertexID & 2) * 2.0 - 1.0;
+    // the far plane behind the corner, interpolating before dividing by w keeps the directions right
+    fragDirection = (inverseViewProjection * vec4(corner, 1.0, 1.0)).xyz;
+    gl_Position = vec4(corner, 1.0, 1.0);
+}
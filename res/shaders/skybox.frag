@@ -0,0 +1,9 @@
+#version 330
+uniform samplerCube skybox;
+uniform float daylight;
+in vec3 fragDirection;
+out vec4 outputColor;
+void main() {
+    vec3 color = texture(skybox, fragDirection).rgb;
+    outputColor = vec4(color * mix(0.05, 1.0, daylight), 1.0);
+}
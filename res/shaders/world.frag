@@ -0,0 +1,33 @@
+#version 330
+#include "lighting.glsl"
+#include "shadow.glsl"
+#include "fog.glsl"
+uniform bool showCascades;
+uniform vec3 eye;
+uniform sampler2DArray blocks;
+in vec4 fragColor;
+in vec3 fragTexCoord;
+in vec3 fragPosition;
+in vec3 fragNormal;
+in vec2 fragLight;
+in float fragViewDepth;
+out vec4 outputColor;
+void main() {
+    // a negative layer marks vertices without a texture
+    vec4 tex = fragTexCoord.z < 0.0 ? vec4(1.0) : texture(blocks, fragTexCoord);
+    vec4 albedo = fragColor * tex;
+    vec3 normal = normalize(fragNormal);
+    vec3 toEye = normalize(eye - fragPosition);
+    // only translucent blocks, water, are shiny. Interpolating an alpha of 1 can
+    // end up a little below it.
+    vec3 specular = fragColor.a < 0.99 ? vec3(0.5) : vec3(0.0);
+    if (showCascades) {
+        albedo.rgb = mix(albedo.rgb, cascadeColor(fragViewDepth), 0.5);
+    }
+    // the sun and the sky only reach as far as the sky light, block light glows on its own
+    float shadow = sunShadow(fragPosition, normal, fragViewDepth);
+    vec3 sky = fragLight.x * sunLight(normal, toEye, albedo.rgb, specular, 64.0, shadow);
+    vec3 color = max(sky, albedo.rgb * max(fragLight.y, 0.05));
+    color += localLights(fragPosition, normal, toEye, albedo.rgb, specular, 64.0);
+    outputColor = applyFog(vec4(color, albedo.a), length(fragPosition - eye));
+}
@@ -0,0 +1,28 @@
+#version 330
+uniform mat4 projection;
+uniform mat4 camera;
+layout(location = 0) in vec3 vert;
+layout(location = 1) in vec2 vertTexCoord;
+layout(location = 2) in vec3 vertNormal;
+layout(location = 4) in vec4 vertColor;
+layout(location = 5) in vec2 vertLight;
+layout(location = 6) in float vertLayer;
+out vec4 fragColor;
+out vec3 fragTexCoord;
+out vec3 fragPosition;
+out vec3 fragNormal;
+out vec2 fragLight;
+out float fragViewDepth;
+void main() {
+    // block colors are picked in sRGB like the textures, lighting works in linear
+    fragColor = vec4(pow(vertColor.rgb, vec3(2.2)), vertColor.a);
+    fragTexCoord = vec3(vertTexCoord, vertLayer);
+    fragPosition = vert;
+    fragNormal = vertNormal;
+    // every light level is 80% as bright as the one above
+    fragLight.x = vertLight.x > 0.0 ? pow(0.8, 15.0 * (1.0 - vertLight.x)) : 0.0;
+    fragLight.y = vertLight.y > 0.0 ? pow(0.8, 15.0 * (1.0 - vertLight.y)) : 0.0;
+    vec4 view = camera * vec4(vert, 1);
+    fragViewDepth = -view.z;
+    gl_Position = projection * view;
+}
@@ -0,0 +1,228 @@
+package scene
+
+import (
+	"errors"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// ErrCycle is returned when a node would become its own ancestor
+var ErrCycle = errors.New("scene: node can't be a child of itself or its descendants")
+
+// Drawable is anything a node can render, like a *mesh.Mesh
+type Drawable interface {
+	Draw()
+}
+
+// Node is a node of a scene graph. Its transform is relative to its parent, the
+// world matrix is computed lazily and cached until the node or an ancestor moves.
+type Node struct {
+	Name     string
+	Visible  bool     // invisible nodes and their children are skipped by Draw
+	Drawable Drawable // optional
+
+	position mgl32.Vec3
+	rotation mgl32.Quat
+	scale    mgl32.Vec3
+
+	parent   *Node
+	children []*Node
+
+	world      mgl32.Mat4
+	worldDirty bool
+}
+
+// NewNode creates a new visible node without transformation
+func NewNode(name string) *Node {
+	n := new(Node)
+	n.Name = name
+	n.Visible = true
+	n.rotation = mgl32.QuatIdent()
+	n.scale = mgl32.Vec3{1, 1, 1}
+	n.worldDirty = true
+	return n
+}
+
+// Position returns the position relative to the parent
+func (n *Node) Position() mgl32.Vec3 {
+	return n.position
+}
+
+// SetPosition sets the position relative to the parent
+func (n *Node) SetPosition(p mgl32.Vec3) {
+	n.position = p
+	n.markDirty()
+}
+
+// Translate moves the node by d in parent space
+func (n *Node) Translate(d mgl32.Vec3) {
+	n.SetPosition(n.position.Add(d))
+}
+
+// Rotation returns the rotation relative to the parent
+func (n *Node) Rotation() mgl32.Quat {
+	return n.rotation
+}
+
+// SetRotation sets the rotation relative to the parent
+func (n *Node) SetRotation(q mgl32.Quat) {
+	n.rotation = q.Normalize()
+	n.markDirty()
+}
+
+// Rotate applies q after the current rotation
+func (n *Node) Rotate(q mgl32.Quat) {
+	n.SetRotation(q.Mul(n.rotation))
+}
+
+// Scale returns the scale relative to the parent
+func (n *Node) Scale() mgl32.Vec3 {
+	return n.scale
+}
+
+// SetScale sets the scale relative to the parent
+func (n *Node) SetScale(s mgl32.Vec3) {
+	n.scale = s
+	n.markDirty()
+}
+
+// LocalMatrix returns the transform relative to the parent: scale, then rotate, then translate
+func (n *Node) LocalMatrix() mgl32.Mat4 {
+	return mgl32.Translate3D(n.position.X(), n.position.Y(), n.position.Z()).
+		Mul4(n.rotation.Mat4()).
+		Mul4(mgl32.Scale3D(n.scale.X(), n.scale.Y(), n.scale.Z()))
+}
+
+// WorldMatrix returns the transform from node to world space, parent world
+// matrix times local matrix
+func (n *Node) WorldMatrix() mgl32.Mat4 {
+	if n.worldDirty {
+		n.world = n.LocalMatrix()
+		if n.parent != nil {
+			n.world = n.parent.WorldMatrix().Mul4(n.world)
+		}
+		n.worldDirty = false
+	}
+	return n.world
+}
+
+// WorldPosition returns the origin of the node in world space
+func (n *Node) WorldPosition() mgl32.Vec3 {
+	return n.WorldMatrix().Col(3).Vec3()
+}
+
+// markDirty invalidates the cached world matrices of the node and its
+// descendants. A dirty node only has dirty descendants, so it stops there.
+func (n *Node) markDirty() {
+	if n.worldDirty {
+		return
+	}
+	n.worldDirty = true
+	for _, c := range n.children {
+		c.markDirty()
+	}
+}
+
+// Parent returns the parent, nil for a root
+func (n *Node) Parent() *Node {
+	return n.parent
+}
+
+// Children returns the children in the order they were added. The slice must not be modified.
+func (n *Node) Children() []*Node {
+	return n.children
+}
+
+// AddChild makes c a child of n, see SetParent
+func (n *Node) AddChild(c *Node) error {
+	return c.SetParent(n)
+}
+
+// SetParent moves the node under parent, or makes it a root if parent is nil.
+// The local transform is kept, so the node moves along with its new parent.
+func (n *Node) SetParent(parent *Node) error {
+	for p := parent; p != nil; p = p.parent {
+		if p == n {
+			return ErrCycle
+		}
+	}
+	if n.parent != nil {
+		siblings := n.parent.children
+		for i, c := range siblings {
+			if c == n {
+				n.parent.children = append(siblings[:i], siblings[i+1:]...)
+				break
+			}
+		}
+	}
+	n.parent = parent
+	if parent != nil {
+		parent.children = append(parent.children, n)
+	}
+	n.worldDirty = false
+	n.markDirty()
+	return nil
+}
+
+// SetParentKeepWorld moves the node under parent like SetParent, but changes the
+// local transform so the node stays where it is in world space. Shearing, caused
+// by rotated parents with non uniform scale, can't be kept.
+func (n *Node) SetParentKeepWorld(parent *Node) error {
+	world := n.WorldMatrix()
+	if err := n.SetParent(parent); err != nil {
+		return err
+	}
+	local := world
+	if parent != nil {
+		local = parent.WorldMatrix().Inv().Mul4(world)
+	}
+	n.position = local.Col(3).Vec3()
+	n.scale = mgl32.Vec3{local.Col(0).Vec3().Len(), local.Col(1).Vec3().Len(), local.Col(2).Vec3().Len()}
+	rot := mgl32.Mat4FromCols(
+		local.Col(0).Mul(1/n.scale[0]),
+		local.Col(1).Mul(1/n.scale[1]),
+		local.Col(2).Mul(1/n.scale[2]),
+		mgl32.Vec4{0, 0, 0, 1},
+	)
+	n.rotation = mgl32.Mat4ToQuat(rot).Normalize()
+	n.worldDirty = false
+	n.markDirty()
+	return nil
+}
+
+// Find returns the first node named name in the subtree, searching depth first
+func (n *Node) Find(name string) *Node {
+	var found *Node
+	n.Walk(func(c *Node) bool {
+		if found == nil && c.Name == name {
+			found = c
+		}
+		return found == nil
+	})
+	return found
+}
+
+// Walk calls fn for the node and its descendants, parents before children.
+// Returning false from fn skips the children of that node.
+func (n *Node) Walk(fn func(n *Node) bool) {
+	if !fn(n) {
+		return
+	}
+	for _, c := range n.children {
+		c.Walk(fn)
+	}
+}
+
+// Draw calls draw with the world matrix of every visible node with a Drawable,
+// skipping the subtrees of invisible nodes
+func (n *Node) Draw(draw func(n *Node, world mgl32.Mat4)) {
+	n.Walk(func(c *Node) bool {
+		if !c.Visible {
+			return false
+		}
+		if c.Drawable != nil {
+			draw(c, c.WorldMatrix())
+		}
+		return true
+	})
+}
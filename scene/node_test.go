@@ -0,0 +1,177 @@
+package scene
+
+import (
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+const epsilon = 1e-5
+
+// tree returns a parent translated, turned a quarter around y and scaled by 2,
+// a child moved along x and stretched along z, and a grandchild moved along y
+// and turned a quarter around z
+func tree() (parent, child, grandchild *Node) {
+	parent, child, grandchild = NewNode("parent"), NewNode("child"), NewNode("grandchild")
+	parent.SetPosition(mgl32.Vec3{1, 2, 3})
+	parent.SetRotation(mgl32.QuatRotate(mgl32.DegToRad(90), mgl32.Vec3{0, 1, 0}))
+	parent.SetScale(mgl32.Vec3{2, 2, 2})
+	child.SetPosition(mgl32.Vec3{1, 0, 0})
+	child.SetScale(mgl32.Vec3{1, 1, 3})
+	grandchild.SetPosition(mgl32.Vec3{0, 1, 0})
+	grandchild.SetRotation(mgl32.QuatRotate(mgl32.DegToRad(90), mgl32.Vec3{0, 0, 1}))
+	parent.AddChild(child)
+	child.AddChild(grandchild)
+	return parent, child, grandchild
+}
+
+// near reports whether a and b differ by less than epsilon in every element,
+// mgl32's thresholds are relative and fail around zero
+func near(a, b []float32) bool {
+	for i := range a {
+		if d := a[i] - b[i]; d > epsilon || d < -epsilon {
+			return false
+		}
+	}
+	return true
+}
+
+func nearVec(a, b mgl32.Vec3) bool {
+	return near(a[:], b[:])
+}
+
+func expectMatrix(t *testing.T, name string, got, want mgl32.Mat4) {
+	t.Helper()
+	if !near(got[:], want[:]) {
+		t.Errorf("%v: got %v, want %v", name, got, want)
+	}
+}
+
+func TestWorldMatrix(t *testing.T) {
+	parent, child, grandchild := tree()
+	// the quarter turn around y maps x to -z and z to x
+	expectMatrix(t, "parent", parent.WorldMatrix(), mgl32.Mat4FromCols(
+		mgl32.Vec4{0, 0, -2, 0},
+		mgl32.Vec4{0, 2, 0, 0},
+		mgl32.Vec4{2, 0, 0, 0},
+		mgl32.Vec4{1, 2, 3, 1},
+	))
+	expectMatrix(t, "child", child.WorldMatrix(), mgl32.Mat4FromCols(
+		mgl32.Vec4{0, 0, -2, 0},
+		mgl32.Vec4{0, 2, 0, 0},
+		mgl32.Vec4{6, 0, 0, 0},
+		mgl32.Vec4{1, 2, 1, 1},
+	))
+	// the quarter turn around z maps x to y and y to -x
+	expectMatrix(t, "grandchild", grandchild.WorldMatrix(), mgl32.Mat4FromCols(
+		mgl32.Vec4{0, 2, 0, 0},
+		mgl32.Vec4{0, 0, 2, 0},
+		mgl32.Vec4{6, 0, 0, 0},
+		mgl32.Vec4{1, 4, 1, 1},
+	))
+	if got := grandchild.WorldPosition(); !nearVec(got, mgl32.Vec3{1, 4, 1}) {
+		t.Errorf("grandchild world position is %v", got)
+	}
+}
+
+func TestDirtyPropagation(t *testing.T) {
+	parent, child, grandchild := tree()
+	grandchild.WorldMatrix()
+
+	parent.Translate(mgl32.Vec3{0, 10, 0})
+	if !child.worldDirty || !grandchild.worldDirty {
+		t.Fatal("moving the parent didn't invalidate its descendants")
+	}
+	if got := grandchild.WorldPosition(); !nearVec(got, mgl32.Vec3{1, 14, 1}) {
+		t.Errorf("grandchild is at %v after the parent moved, want 1,14,1", got)
+	}
+
+	// only the child is evaluated, the grandchild stays dirty through the next move
+	child.WorldMatrix()
+	parent.SetScale(mgl32.Vec3{1, 1, 1})
+	parent.SetPosition(mgl32.Vec3{})
+	if got := grandchild.WorldPosition(); !nearVec(got, mgl32.Vec3{0, 1, -1}) {
+		t.Errorf("grandchild is at %v after two moves, want 0,1,-1", got)
+	}
+
+	// moving the child leaves the parent alone
+	parent.WorldMatrix()
+	child.Translate(mgl32.Vec3{1, 0, 0})
+	if parent.worldDirty {
+		t.Error("moving the child invalidated the parent")
+	}
+}
+
+func TestSetParent(t *testing.T) {
+	parent, _, _ := tree()
+	n := NewNode("n")
+	n.SetPosition(mgl32.Vec3{5, 0, 0})
+
+	// the local transform is kept, the node moves with its parent
+	if err := n.SetParent(parent); err != nil {
+		t.Fatal(err)
+	}
+	if got := n.WorldPosition(); !nearVec(got, mgl32.Vec3{1, 2, -7}) {
+		t.Errorf("SetParent: world position %v, want 1,2,-7", got)
+	}
+	if n.Position() != (mgl32.Vec3{5, 0, 0}) {
+		t.Errorf("SetParent changed the local position to %v", n.Position())
+	}
+	if got := parent.Children(); got[len(got)-1] != n || n.Parent() != parent {
+		t.Error("SetParent didn't link the node")
+	}
+
+	// the world transform is kept, the local one changes
+	world := n.WorldMatrix()
+	if err := n.SetParentKeepWorld(nil); err != nil {
+		t.Fatal(err)
+	}
+	expectMatrix(t, "SetParentKeepWorld to the root", n.WorldMatrix(), world)
+	if n.Parent() != nil || len(parent.Children()) != 1 {
+		t.Error("SetParentKeepWorld didn't unlink the node")
+	}
+	if !nearVec(n.Position(), mgl32.Vec3{1, 2, -7}) || !nearVec(n.Scale(), mgl32.Vec3{2, 2, 2}) {
+		t.Errorf("root local transform is %v scaled %v", n.Position(), n.Scale())
+	}
+
+	_, child, grandchild := tree()
+	world = n.WorldMatrix()
+	if err := n.SetParentKeepWorld(grandchild); err != nil {
+		t.Fatal(err)
+	}
+	expectMatrix(t, "SetParentKeepWorld to a nested node", n.WorldMatrix(), world)
+
+	// moving the old parent no longer moves the node
+	parent.Translate(mgl32.Vec3{100, 0, 0})
+	expectMatrix(t, "after moving the old parent", n.WorldMatrix(), world)
+	child.Translate(mgl32.Vec3{0, 1, 0})
+	if got := n.WorldPosition(); !nearVec(got, mgl32.Vec3{1, 4, -7}) {
+		t.Errorf("node is at %v after its new ancestor moved, want 1,4,-7", got)
+	}
+}
+
+func TestSetParentCycle(t *testing.T) {
+	parent, child, grandchild := tree()
+	for _, test := range []struct {
+		name         string
+		node, parent *Node
+	}{
+		{"itself", child, child},
+		{"its child", child, grandchild},
+		{"its grandchild", parent, grandchild},
+	} {
+		if err := test.node.SetParent(test.parent); err != ErrCycle {
+			t.Errorf("%v: got error %v, want ErrCycle", test.name, err)
+		}
+		if err := test.node.SetParentKeepWorld(test.parent); err != ErrCycle {
+			t.Errorf("%v: SetParentKeepWorld got error %v, want ErrCycle", test.name, err)
+		}
+	}
+	// the tree is unchanged
+	if parent.Parent() != nil || child.Parent() != parent || grandchild.Parent() != child {
+		t.Error("a failed SetParent changed the tree")
+	}
+	if len(parent.Children()) != 1 || len(child.Children()) != 1 || len(grandchild.Children()) != 0 {
+		t.Error("a failed SetParent changed the children")
+	}
+}
@@ -0,0 +1,167 @@
+// Package shader compiles and links OpenGL shader programs
+package shader
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/tehcyx/goengine/util"
+)
+
+// Program is a linked shader program
+type Program struct {
+	ID       uint32
+	uniforms map[string]int32
+}
+
+// New compiles and links a program from the sources of a vertex and a fragment
+// shader, must be called on the GL thread
+func New(vertexSource, fragmentSource string) (*Program, error) {
+	defer util.TimeTrack(time.Now(), "shader.New")
+	vertexShader, err := compile(vertexSource, gl.VERTEX_SHADER)
+	if err != nil {
+		return nil, err
+	}
+	defer gl.DeleteShader(vertexShader)
+
+	fragmentShader, err := compile(fragmentSource, gl.FRAGMENT_SHADER)
+	if err != nil {
+		return nil, err
+	}
+	defer gl.DeleteShader(fragmentShader)
+
+	p := new(Program)
+	p.ID = gl.CreateProgram()
+	p.uniforms = make(map[string]int32)
+	gl.AttachShader(p.ID, vertexShader)
+	gl.AttachShader(p.ID, fragmentShader)
+	gl.LinkProgram(p.ID)
+
+	var status int32
+	gl.GetProgramiv(p.ID, gl.LINK_STATUS, &status)
+	if status == gl.FALSE {
+		var logLength int32
+		gl.GetProgramiv(p.ID, gl.INFO_LOG_LENGTH, &logLength)
+
+		log := strings.Repeat("\x00", int(logLength+1))
+		gl.GetProgramInfoLog(p.ID, logLength, nil, gl.Str(log))
+		gl.DeleteProgram(p.ID)
+
+		return nil, fmt.Errorf("failed to link program: %v", log)
+	}
+	return p, nil
+}
+
+// Load reads the sources of a vertex and a fragment shader from files and
+// builds a program from them, must be called on the GL thread. A line
+// #include "file" is replaced by the file, relative to the including one.
+func Load(vertexPath, fragmentPath string) (*Program, error) {
+	vertexSource, err := ReadSource(vertexPath)
+	if err != nil {
+		return nil, err
+	}
+	fragmentSource, err := ReadSource(fragmentPath)
+	if err != nil {
+		return nil, err
+	}
+	p, err := New(vertexSource, fragmentSource)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %v and %v: %v", vertexPath, fragmentPath, err)
+	}
+	return p, nil
+}
+
+// ReadSource reads a shader file and resolves its includes
+func ReadSource(path string) (string, error) {
+	return readSource(path, nil)
+}
+
+func readSource(path string, including []string) (string, error) {
+	for _, p := range including {
+		if p == path {
+			return "", fmt.Errorf("failed to read %v: it includes itself", path)
+		}
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(trimmed, "#include") {
+			continue
+		}
+		name := strings.Trim(strings.TrimSpace(strings.TrimPrefix(trimmed, "#include")), `"`)
+		included, err := readSource(filepath.Join(filepath.Dir(path), name), append(including, path))
+		if err != nil {
+			return "", err
+		}
+		lines[i] = included
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// Use makes the program the current one
+func (p *Program) Use() {
+	gl.UseProgram(p.ID)
+}
+
+// Uniform returns the location of the named uniform, -1 if the program has no
+// such uniform or the compiler optimized it away
+func (p *Program) Uniform(name string) int32 {
+	location, ok := p.uniforms[name]
+	if !ok {
+		location = gl.GetUniformLocation(p.ID, gl.Str(name+"\x00"))
+		p.uniforms[name] = location
+	}
+	return location
+}
+
+// BindBlock connects the named uniform block to a uniform buffer binding
+// point, reports whether the program has the block
+func (p *Program) BindBlock(name string, binding uint32) bool {
+	index := gl.GetUniformBlockIndex(p.ID, gl.Str(name+"\x00"))
+	if index == gl.INVALID_INDEX {
+		return false
+	}
+	gl.UniformBlockBinding(p.ID, index, binding)
+	return true
+}
+
+// Delete frees the program, must be called on the GL thread
+func (p *Program) Delete() {
+	gl.DeleteProgram(p.ID)
+}
+
+func compile(source string, shaderType uint32) (uint32, error) {
+	defer util.TimeTrack(time.Now(), "shader.compile")
+	if !strings.HasSuffix(source, "\x00") {
+		source += "\x00"
+	}
+	shader := gl.CreateShader(shaderType)
+
+	csources, free := gl.Strs(source)
+	gl.ShaderSource(shader, 1, csources, nil)
+	free()
+	gl.CompileShader(shader)
+
+	var status int32
+	gl.GetShaderiv(shader, gl.COMPILE_STATUS, &status)
+	if status == gl.FALSE {
+		var logLength int32
+		gl.GetShaderiv(shader, gl.INFO_LOG_LENGTH, &logLength)
+
+		log := strings.Repeat("\x00", int(logLength+1))
+		gl.GetShaderInfoLog(shader, logLength, nil, gl.Str(log))
+		gl.DeleteShader(shader)
+
+		return 0, fmt.Errorf("failed to compile %v: %v", source, log)
+	}
+
+	return shader, nil
+}
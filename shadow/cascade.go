@@ -0,0 +1,76 @@
+// Package shadow renders the depth of the scene as seen from the sun into
+// cascaded shadow maps, so shaders including res/shaders/shadow.glsl can tell
+// what is in the shadow
+package shadow
+
+import (
+	"math"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// Splits returns the far distances of count cascades covering near to far. Lambda
+// mixes logarithmic and uniform splits, 1 is fully logarithmic and gives close
+// cascades the most detail.
+func Splits(near, far float32, count int, lambda float32) []float32 {
+	splits := make([]float32, count)
+	for i := range splits {
+		p := float64(i+1) / float64(count)
+		log := float64(near) * math.Pow(float64(far/near), p)
+		uniform := float64(near) + float64(far-near)*p
+		splits[i] = float32(float64(lambda)*log + (1-float64(lambda))*uniform)
+	}
+	return splits
+}
+
+// Fit returns the view projection of the sun covering the part of the camera
+// frustum between near and far, with a shadow map of size texels. The
+// projection fits a sphere around that part, so it keeps its size when the
+// camera turns, and moves in whole texels, so shadow edges don't shimmer when
+// the camera moves. Casters up to extend behind the sphere are caught.
+// Returns the world size of a texel as well.
+func Fit(cameraView mgl32.Mat4, fovy, aspect, near, far float32, toSun mgl32.Vec3, size int32, extend float32) (mgl32.Mat4, float32) {
+	inverse := cameraView.Inv()
+	tanY := float32(math.Tan(float64(fovy) / 2))
+	tanX := tanY * aspect
+
+	var corners [8]mgl32.Vec3
+	var center mgl32.Vec3
+	for i, d := range [2]float32{near, far} {
+		for j, s := range [4][2]float32{{-1, -1}, {1, -1}, {1, 1}, {-1, 1}} {
+			view := mgl32.Vec4{s[0] * tanX * d, s[1] * tanY * d, -d, 1}
+			corners[i*4+j] = inverse.Mul4x1(view).Vec3()
+			center = center.Add(corners[i*4+j])
+		}
+	}
+	center = center.Mul(1.0 / 8)
+	var radius float32
+	for _, c := range corners {
+		if d := c.Sub(center).Len(); d > radius {
+			radius = d
+		}
+	}
+	// whole sixteenths keep the size from flickering with rounding errors
+	radius = float32(math.Ceil(float64(radius)*16)) / 16
+
+	toSun = toSun.Normalize()
+	up := mgl32.Vec3{0, 1, 0}
+	if abs(toSun.Dot(up)) > 0.99 {
+		up = mgl32.Vec3{0, 0, 1}
+	}
+	view := mgl32.LookAtV(center.Add(toSun.Mul(radius+extend)), center, up)
+	projection := mgl32.Ortho(-radius, radius, -radius, radius, 0, 2*radius+extend)
+
+	// move the projection so the world origin lands on a texel corner
+	origin := projection.Mul4(view).Mul4x1(mgl32.Vec4{0, 0, 0, 1}).Mul(float32(size) / 2)
+	projection[12] += (float32(math.Round(float64(origin[0]))) - origin[0]) * 2 / float32(size)
+	projection[13] += (float32(math.Round(float64(origin[1]))) - origin[1]) * 2 / float32(size)
+	return projection.Mul4(view), 2 * radius / float32(size)
+}
+
+func abs(v float32) float32 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,132 @@
+package shadow
+
+import (
+	"fmt"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/tehcyx/goengine/shader"
+)
+
+// MaxCascades is the size of the cascade arrays in shadow.glsl
+const MaxCascades = 4
+
+// Map is a sun shadow map, a depth texture array with one layer per cascade
+// rendered through an offscreen framebuffer
+type Map struct {
+	Size      int32   // width and height of every cascade in texels
+	Cascades  int     // from 1 to MaxCascades
+	Distance  float32 // shadows end this far from the camera
+	Lambda    float32 // how logarithmic the cascade splits are, see Splits
+	Extend    float32 // casters this far behind a cascade still throw shadows into it
+	SlopeBias float32 // depth offset scaled with the slope of a surface, against acne on steep faces
+	DepthBias float32 // constant depth offset in units of the depth buffer
+
+	Splits    []float32    // far distance of every cascade, set by Update
+	Matrices  []mgl32.Mat4 // sun view projection of every cascade, set by Update
+	TexelSize []float32    // world size of a texel of every cascade, set by Update
+
+	texture uint32
+	fbo     uint32
+}
+
+// New creates a shadow map with cascades layers of size texels, must be called on the GL thread
+func New(size int32, cascades int) (*Map, error) {
+	if cascades < 1 || cascades > MaxCascades {
+		return nil, fmt.Errorf("failed to create shadow map: %v cascades, 1 to %v are supported", cascades, MaxCascades)
+	}
+	m := new(Map)
+	m.Size = size
+	m.Cascades = cascades
+	m.Distance = 128
+	m.Lambda = 0.75
+	m.Extend = 256
+	m.SlopeBias = 2
+	m.DepthBias = 4
+	m.Splits = make([]float32, cascades)
+	m.Matrices = make([]mgl32.Mat4, cascades)
+	m.TexelSize = make([]float32, cascades)
+
+	gl.GenTextures(1, &m.texture)
+	gl.BindTexture(gl.TEXTURE_2D_ARRAY, m.texture)
+	gl.TexImage3D(gl.TEXTURE_2D_ARRAY, 0, gl.DEPTH_COMPONENT24, size, size, int32(cascades), 0, gl.DEPTH_COMPONENT, gl.FLOAT, nil)
+	// linear filtering of a comparing sampler averages 4 comparisons for free
+	gl.TexParameteri(gl.TEXTURE_2D_ARRAY, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D_ARRAY, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D_ARRAY, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_BORDER)
+	gl.TexParameteri(gl.TEXTURE_2D_ARRAY, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_BORDER)
+	border := [4]float32{1, 1, 1, 1}
+	gl.TexParameterfv(gl.TEXTURE_2D_ARRAY, gl.TEXTURE_BORDER_COLOR, &border[0])
+	gl.TexParameteri(gl.TEXTURE_2D_ARRAY, gl.TEXTURE_COMPARE_MODE, gl.COMPARE_REF_TO_TEXTURE)
+	gl.TexParameteri(gl.TEXTURE_2D_ARRAY, gl.TEXTURE_COMPARE_FUNC, gl.LEQUAL)
+	gl.BindTexture(gl.TEXTURE_2D_ARRAY, 0)
+
+	var previous int32
+	gl.GetIntegerv(gl.FRAMEBUFFER_BINDING, &previous)
+	gl.GenFramebuffers(1, &m.fbo)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, m.fbo)
+	gl.FramebufferTextureLayer(gl.FRAMEBUFFER, gl.DEPTH_ATTACHMENT, m.texture, 0, 0)
+	gl.DrawBuffer(gl.NONE)
+	gl.ReadBuffer(gl.NONE)
+	status := gl.CheckFramebufferStatus(gl.FRAMEBUFFER)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, uint32(previous))
+	if status != gl.FRAMEBUFFER_COMPLETE {
+		m.Delete()
+		return nil, fmt.Errorf("failed to create shadow map: framebuffer status 0x%x", status)
+	}
+	return m, nil
+}
+
+// Update fits the cascades to the camera, whose perspective projection starts
+// at near, for a sun in the direction toSun
+func (m *Map) Update(cameraView mgl32.Mat4, fovy, aspect, near float32, toSun mgl32.Vec3) {
+	splits := Splits(near, m.Distance, m.Cascades, m.Lambda)
+	from := near
+	for i, to := range splits {
+		m.Matrices[i], m.TexelSize[i] = Fit(cameraView, fovy, aspect, from, to, toSun, m.Size, m.Extend)
+		m.Splits[i] = to
+		from = to
+	}
+}
+
+// Render renders the depth of every cascade. draw is called once per cascade
+// with its sun view projection and has to draw the shadow casters with a depth
+// only program. The framebuffer and viewport are restored afterwards.
+func (m *Map) Render(draw func(cascade int, viewProjection mgl32.Mat4)) {
+	var previous int32
+	var viewport [4]int32
+	gl.GetIntegerv(gl.FRAMEBUFFER_BINDING, &previous)
+	gl.GetIntegerv(gl.VIEWPORT, &viewport[0])
+
+	gl.BindFramebuffer(gl.FRAMEBUFFER, m.fbo)
+	gl.Viewport(0, 0, m.Size, m.Size)
+	gl.Enable(gl.POLYGON_OFFSET_FILL)
+	gl.PolygonOffset(m.SlopeBias, m.DepthBias)
+	for i := 0; i < m.Cascades; i++ {
+		gl.FramebufferTextureLayer(gl.FRAMEBUFFER, gl.DEPTH_ATTACHMENT, m.texture, 0, int32(i))
+		gl.Clear(gl.DEPTH_BUFFER_BIT)
+		draw(i, m.Matrices[i])
+	}
+	gl.Disable(gl.POLYGON_OFFSET_FILL)
+
+	gl.BindFramebuffer(gl.FRAMEBUFFER, uint32(previous))
+	gl.Viewport(viewport[0], viewport[1], viewport[2], viewport[3])
+}
+
+// Bind binds the shadow map to a texture unit and sets the uniforms of
+// shadow.glsl in the program, which has to be in use
+func (m *Map) Bind(p *shader.Program, unit uint32) {
+	gl.ActiveTexture(gl.TEXTURE0 + unit)
+	gl.BindTexture(gl.TEXTURE_2D_ARRAY, m.texture)
+	gl.Uniform1i(p.Uniform("shadowMap"), int32(unit))
+	gl.Uniform1i(p.Uniform("cascadeCount"), int32(m.Cascades))
+	gl.UniformMatrix4fv(p.Uniform("shadowMatrices"), int32(m.Cascades), false, &m.Matrices[0][0])
+	gl.Uniform1fv(p.Uniform("cascadeSplits"), int32(m.Cascades), &m.Splits[0])
+	gl.Uniform1fv(p.Uniform("cascadeTexels"), int32(m.Cascades), &m.TexelSize[0])
+}
+
+// Delete frees the texture and framebuffer, must be called on the GL thread
+func (m *Map) Delete() {
+	gl.DeleteFramebuffers(1, &m.fbo)
+	gl.DeleteTextures(1, &m.texture)
+}
@@ -0,0 +1,131 @@
+// Package sky draws the sky behind a scene and moves the sun and the moon
+// through the day, lighting the scene and coloring its fog to match
+package sky
+
+import (
+	"math"
+	"time"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/tehcyx/goengine/light"
+)
+
+// Colors of the sky, in linear RGB
+var (
+	dayZenith    = mgl32.Vec3{0.15, 0.35, 0.85}
+	dayHorizon   = mgl32.Vec3{0.6, 0.75, 0.95}
+	nightZenith  = mgl32.Vec3{0.002, 0.003, 0.01}
+	nightHorizon = mgl32.Vec3{0.01, 0.015, 0.03}
+	sunsetGlow   = mgl32.Vec3{1, 0.35, 0.1}
+	noonSun      = mgl32.Vec3{1, 0.95, 0.85}
+	lowSun       = mgl32.Vec3{1, 0.45, 0.15}
+	moonlight    = mgl32.Vec3{0.6, 0.7, 1}
+)
+
+// Cycle is the time of day. It moves the sun and the moon on opposite sides of
+// the sky and colors the sky and the light.
+type Cycle struct {
+	Hour      float32       // hours since midnight, from 0 to 24, the sun rises at 6 and sets at 18
+	DayLength time.Duration // real time a whole day takes
+	Paused    bool
+	Tilt      float32 // radians the path of the sun leans away from straight overhead
+
+	SunIntensity  float32
+	MoonIntensity float32
+	DayAmbient    mgl32.Vec3
+	NightAmbient  mgl32.Vec3
+}
+
+// NewCycle creates a day of 10 minutes starting at hour
+func NewCycle(hour float32) *Cycle {
+	c := new(Cycle)
+	c.Hour = hour
+	c.DayLength = 10 * time.Minute
+	c.Tilt = mgl32.DegToRad(25)
+	c.SunIntensity = 0.8
+	c.MoonIntensity = 0.08
+	c.DayAmbient = mgl32.Vec3{0.35, 0.4, 0.5}
+	c.NightAmbient = mgl32.Vec3{0.02, 0.025, 0.04}
+	return c
+}
+
+// Advance moves the time of day on by dt
+func (c *Cycle) Advance(dt time.Duration) {
+	if c.Paused || c.DayLength <= 0 {
+		return
+	}
+	c.Skip(float32(dt.Seconds() / c.DayLength.Seconds() * 24))
+}
+
+// Skip moves the time of day on by hours, wrapping around at midnight
+func (c *Cycle) Skip(hours float32) {
+	c.Hour = float32(math.Mod(float64(c.Hour+hours), 24))
+	if c.Hour < 0 {
+		c.Hour += 24
+	}
+}
+
+// ToSun returns the direction towards the sun, it points below the horizon at night
+func (c *Cycle) ToSun() mgl32.Vec3 {
+	angle := float64(c.Hour-6) / 12 * math.Pi
+	sin, cos := math.Sincos(angle)
+	tiltSin, tiltCos := math.Sincos(float64(c.Tilt))
+	return mgl32.Vec3{float32(cos), float32(sin * tiltCos), float32(sin * tiltSin)}
+}
+
+// ToMoon returns the direction towards the moon, opposite of the sun
+func (c *Cycle) ToMoon() mgl32.Vec3 {
+	return c.ToSun().Mul(-1)
+}
+
+// Daylight returns how bright the day is, from 0 at night to 1 once the sun is up
+func (c *Cycle) Daylight() float32 {
+	return smoothstep(-0.1, 0.15, c.ToSun().Y())
+}
+
+// SunColor returns the color of the sun, red close to the horizon
+func (c *Cycle) SunColor() mgl32.Vec3 {
+	return mix(lowSun, noonSun, smoothstep(0, 0.35, c.ToSun().Y()))
+}
+
+// Colors returns the colors of the sky straight up and at the horizon, which
+// glows around sunrise and sunset. The fog takes the horizon color.
+func (c *Cycle) Colors() (zenith, horizon mgl32.Vec3) {
+	day := c.Daylight()
+	zenith = mix(nightZenith, dayZenith, day)
+	horizon = mix(nightHorizon, dayHorizon, day)
+	height := c.ToSun().Y()
+	glow := 1 - smoothstep(0, 0.3, abs(height+0.05))
+	return zenith, mix(horizon, sunsetGlow, glow*0.6)
+}
+
+// Apply sets the ambient light and the directional light of a set. By day the
+// sun is the directional light, by night the much dimmer moon. The world shader
+// scales both by the sky light of a block, so only block light stays at night.
+func (c *Cycle) Apply(set *light.Set) {
+	set.Ambient = mix(c.NightAmbient, c.DayAmbient, c.Daylight())
+	toSun := c.ToSun()
+	if toSun.Y() >= 0 {
+		// fade in over the first degrees so the light doesn't pop when it swaps
+		set.Sun = light.Directional{Direction: toSun.Mul(-1), Color: c.SunColor(), Intensity: c.SunIntensity * smoothstep(0, 0.1, toSun.Y())}
+	} else {
+		toMoon := c.ToMoon()
+		set.Sun = light.Directional{Direction: toMoon.Mul(-1), Color: moonlight, Intensity: c.MoonIntensity * smoothstep(0, 0.1, toMoon.Y())}
+	}
+}
+
+func mix(a, b mgl32.Vec3, t float32) mgl32.Vec3 {
+	return a.Add(b.Sub(a).Mul(t))
+}
+
+func smoothstep(edge0, edge1, x float32) float32 {
+	t := mgl32.Clamp((x-edge0)/(edge1-edge0), 0, 1)
+	return t * t * (3 - 2*t)
+}
+
+func abs(v float32) float32 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,102 @@
+package sky
+
+import (
+	"math"
+	"path/filepath"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/tehcyx/goengine/shader"
+	"github.com/tehcyx/goengine/texture"
+)
+
+// Sky draws a gradient from the horizon to the zenith with the sun, the moon
+// and stars, or a cube map if one is set
+type Sky struct {
+	Cycle      *Cycle
+	Cubemap    *texture.Texture // drawn instead of the gradient if set, darkened at night
+	FogStart   float32          // distance where the fog begins
+	FogDensity float32          // how quickly the fog thickens past FogStart, 0 turns it off
+
+	gradient, skybox *shader.Program
+	vao              uint32
+}
+
+// New creates a sky following cycle, loading sky.vert, sky.frag and
+// skybox.frag from dir. Must be called on the GL thread.
+func New(dir string, cycle *Cycle) (*Sky, error) {
+	s := new(Sky)
+	s.Cycle = cycle
+	var err error
+	s.gradient, err = shader.Load(filepath.Join(dir, "sky.vert"), filepath.Join(dir, "sky.frag"))
+	if err != nil {
+		return nil, err
+	}
+	s.skybox, err = shader.Load(filepath.Join(dir, "sky.vert"), filepath.Join(dir, "skybox.frag"))
+	if err != nil {
+		s.gradient.Delete()
+		return nil, err
+	}
+	// the full screen triangle is made up from gl_VertexID, but core GL wants a vertex array anyway
+	gl.GenVertexArrays(1, &s.vao)
+	return s, nil
+}
+
+// FogDensity returns the density of fog beginning at start that hides 95% of what is at end
+func FogDensity(start, end float32) float32 {
+	// the fog is 1 - exp(-(density * (distance - start))²)
+	return float32(math.Sqrt(3)) / (end - start)
+}
+
+// Draw fills the screen with the sky as seen through camera and projection.
+// It writes no depth, so it belongs first in a frame.
+func (s *Sky) Draw(camera, projection mgl32.Mat4) {
+	// the sky is infinitely far away, moving the camera doesn't move it
+	camera.SetCol(3, mgl32.Vec4{0, 0, 0, 1})
+	inverse := projection.Mul4(camera).Inv()
+
+	p := s.gradient
+	if s.Cubemap != nil {
+		p = s.skybox
+	}
+	p.Use()
+	zenith, horizon := s.Cycle.Colors()
+	toSun, sunColor := s.Cycle.ToSun(), s.Cycle.SunColor()
+	gl.UniformMatrix4fv(p.Uniform("inverseViewProjection"), 1, false, &inverse[0])
+	gl.Uniform3fv(p.Uniform("zenith"), 1, &zenith[0])
+	gl.Uniform3fv(p.Uniform("horizon"), 1, &horizon[0])
+	gl.Uniform3fv(p.Uniform("toSun"), 1, &toSun[0])
+	gl.Uniform3fv(p.Uniform("sunColor"), 1, &sunColor[0])
+	gl.Uniform1f(p.Uniform("daylight"), s.Cycle.Daylight())
+	if s.Cubemap != nil {
+		s.Cubemap.Bind(0)
+		gl.Uniform1i(p.Uniform("skybox"), 0)
+	}
+
+	depthTest := gl.IsEnabled(gl.DEPTH_TEST)
+	gl.Disable(gl.DEPTH_TEST)
+	gl.DepthMask(false)
+	gl.BindVertexArray(s.vao)
+	gl.DrawArrays(gl.TRIANGLES, 0, 3)
+	gl.BindVertexArray(0)
+	gl.DepthMask(true)
+	if depthTest {
+		gl.Enable(gl.DEPTH_TEST)
+	}
+}
+
+// Fog sets the uniforms of fog.glsl in the program, which has to be in use.
+// The fog takes the color of the horizon, so far away things fade into the sky.
+func (s *Sky) Fog(p *shader.Program) {
+	_, horizon := s.Cycle.Colors()
+	gl.Uniform3fv(p.Uniform("fogColor"), 1, &horizon[0])
+	gl.Uniform1f(p.Uniform("fogStart"), s.FogStart)
+	gl.Uniform1f(p.Uniform("fogDensity"), s.FogDensity)
+}
+
+// Delete frees the programs, the cube map stays. Must be called on the GL thread.
+func (s *Sky) Delete() {
+	s.gradient.Delete()
+	s.skybox.Delete()
+	gl.DeleteVertexArrays(1, &s.vao)
+}
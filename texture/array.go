@@ -0,0 +1,42 @@
+package texture
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+)
+
+// NewArray uploads images of the same size as the layers of a 2D array
+// texture, e.g. the ones made by PackArray. The rows are flipped like in New.
+// Must be called on the GL thread.
+func NewArray(layers []*image.NRGBA, options Options) (*Texture, error) {
+	if len(layers) == 0 {
+		return nil, fmt.Errorf("failed to create array texture: no layers")
+	}
+	size := layers[0].Rect.Size()
+	t := new(Texture)
+	t.Target = gl.TEXTURE_2D_ARRAY
+	t.Width, t.Height = int32(size.X), int32(size.Y)
+	t.Layers = int32(len(layers))
+	t.Options = options
+
+	pixels := make([]uint8, 0, size.X*size.Y*4*len(layers))
+	for i, layer := range layers {
+		if layer.Rect.Size() != size {
+			return nil, fmt.Errorf("failed to create array texture: layer %v is %vx%v, layer 0 is %vx%v", i, layer.Rect.Dx(), layer.Rect.Dy(), size.X, size.Y)
+		}
+		pixels = append(pixels, flip(ToNRGBA(layer)).Pix...)
+	}
+
+	gl.GenTextures(1, &t.ID)
+	gl.BindTexture(gl.TEXTURE_2D_ARRAY, t.ID)
+	gl.PixelStorei(gl.UNPACK_ALIGNMENT, 1)
+	gl.TexImage3D(gl.TEXTURE_2D_ARRAY, 0, internalFormat(options.SRGB), t.Width, t.Height, t.Layers, 0, gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(pixels))
+	if options.Mipmaps {
+		gl.GenerateMipmap(gl.TEXTURE_2D_ARRAY)
+	}
+	applySampler(gl.TEXTURE_2D_ARRAY, options.Sampler)
+	gl.BindTexture(gl.TEXTURE_2D_ARRAY, 0)
+	return t, nil
+}
@@ -0,0 +1,268 @@
+package texture
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// maxAtlasSize is the largest atlas PackAtlas creates, most drivers support at least this
+const maxAtlasSize = 16384
+
+// Rect is an area of a texture in texture coordinates, which start at the
+// bottom left like the ones of a texture created by New
+type Rect struct {
+	U0, V0, U1, V1 float32
+}
+
+// Map maps texture coordinates from 0 to 1 into the rect
+func (r Rect) Map(uv mgl32.Vec2) mgl32.Vec2 {
+	return mgl32.Vec2{r.U0 + (r.U1-r.U0)*uv[0], r.V0 + (r.V1-r.V0)*uv[1]}
+}
+
+// Tile is where a packed image ended up
+type Tile struct {
+	Rect  Rect
+	Layer int // layer of an array texture, 0 in an atlas
+}
+
+// Lookup finds the tiles packed by PackAtlas or PackArray by the name of their image
+type Lookup struct {
+	Width    int // size of the atlas or of one array layer in pixels
+	Height   int
+	Layers   int // 1 for an atlas
+	MaxLevel int // highest mipmap level that doesn't mix neighbouring tiles, -1 if every level is safe
+	Tiles    map[string]Tile
+}
+
+// Tile returns the tile of the named image
+func (l *Lookup) Tile(name string) (Tile, bool) {
+	t, ok := l.Tiles[name]
+	return t, ok
+}
+
+// Write writes the lookup as JSON
+func (l *Lookup) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(l)
+}
+
+// ReadLookup reads a lookup written by Write
+func ReadLookup(r io.Reader) (*Lookup, error) {
+	l := new(Lookup)
+	if err := json.NewDecoder(r).Decode(l); err != nil {
+		return nil, fmt.Errorf("failed to read lookup: %v", err)
+	}
+	return l, nil
+}
+
+// LoadDir decodes every PNG and JPEG file in dir, keyed by file name without extension
+func LoadDir(dir string) (map[string]image.Image, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	images := make(map[string]image.Image)
+	for _, f := range files {
+		ext := strings.ToLower(filepath.Ext(f.Name()))
+		if f.IsDir() || ext != ".png" && ext != ".jpg" && ext != ".jpeg" {
+			continue
+		}
+		img, err := Decode(filepath.Join(dir, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		images[strings.TrimSuffix(f.Name(), filepath.Ext(f.Name()))] = img
+	}
+	return images, nil
+}
+
+type packItem struct {
+	name string
+	img  image.Image
+	x, y int // top left of the padded cell
+}
+
+// PackAtlas packs images into one power of two sized image, row by row from the
+// tallest to the shortest. Every image is surrounded by padding pixels that
+// repeat its edge, so filtering and mipmaps up to Lookup.MaxLevel don't bleed
+// neighbouring tiles into it.
+func PackAtlas(images map[string]image.Image, padding int) (*image.NRGBA, *Lookup, error) {
+	items, err := sortedItems(images)
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].img.Bounds().Dy() > items[j].img.Bounds().Dy()
+	})
+
+	// a texel of mipmap level n covers 2^n pixels, cells starting on a multiple of
+	// that keep every texel inside one cell, the padding keeps filtering inside
+	maxLevel, align := 0, 1
+	for align*2 <= padding {
+		maxLevel++
+		align *= 2
+	}
+
+	area, widest := 0, 0
+	for _, it := range items {
+		b := it.img.Bounds()
+		w, h := roundUp(b.Dx()+2*padding, align), roundUp(b.Dy()+2*padding, align)
+		area += w * h
+		if w > widest {
+			widest = w
+		}
+	}
+	width, height := 1, 1
+	for width < widest || width*width < area {
+		width *= 2
+	}
+	for height*width < area {
+		height *= 2
+	}
+	for !shelfPack(items, width, height, padding, align) {
+		if width > height {
+			height *= 2
+		} else {
+			width *= 2
+		}
+		if width > maxAtlasSize || height > maxAtlasSize {
+			return nil, nil, fmt.Errorf("failed to pack %v images into a %vx%v atlas", len(items), maxAtlasSize, maxAtlasSize)
+		}
+	}
+
+	atlas := image.NewNRGBA(image.Rect(0, 0, width, height))
+	l := &Lookup{Width: width, Height: height, Layers: 1, MaxLevel: maxLevel, Tiles: make(map[string]Tile)}
+	for _, it := range items {
+		b := it.img.Bounds()
+		bleed(atlas, it.img, it.x, it.y, padding)
+		x0, y0 := it.x+padding, it.y+padding
+		l.Tiles[it.name] = Tile{Rect: Rect{
+			U0: float32(x0) / float32(width),
+			V0: 1 - float32(y0+b.Dy())/float32(height),
+			U1: float32(x0+b.Dx()) / float32(width),
+			V1: 1 - float32(y0)/float32(height),
+		}}
+	}
+	return atlas, l, nil
+}
+
+// PackArray puts every image into its own layer of an array texture, sorted by
+// name. All images must have the same size.
+func PackArray(images map[string]image.Image) ([]*image.NRGBA, *Lookup, error) {
+	items, err := sortedItems(images)
+	if err != nil {
+		return nil, nil, err
+	}
+	size := items[0].img.Bounds().Size()
+	l := &Lookup{Width: size.X, Height: size.Y, Layers: len(items), MaxLevel: -1, Tiles: make(map[string]Tile)}
+	layers := make([]*image.NRGBA, len(items))
+	for i, it := range items {
+		if s := it.img.Bounds().Size(); s != size {
+			return nil, nil, fmt.Errorf("failed to pack %v: it is %vx%v, %v is %vx%v", it.name, s.X, s.Y, items[0].name, size.X, size.Y)
+		}
+		layers[i] = ToNRGBA(it.img)
+		l.Tiles[it.name] = Tile{Rect: Rect{0, 0, 1, 1}, Layer: i}
+	}
+	return layers, l, nil
+}
+
+// JoinLayers stacks the layers of an array texture on top of each other into
+// one image, the first layer at the top
+func JoinLayers(layers []*image.NRGBA) *image.NRGBA {
+	size := layers[0].Rect.Size()
+	strip := image.NewNRGBA(image.Rect(0, 0, size.X, size.Y*len(layers)))
+	for i, layer := range layers {
+		draw.Draw(strip, image.Rect(0, i*size.Y, size.X, (i+1)*size.Y), layer, layer.Rect.Min, draw.Src)
+	}
+	return strip
+}
+
+// SplitLayers cuts an image made by JoinLayers back into n layers
+func SplitLayers(strip image.Image, n int) ([]*image.NRGBA, error) {
+	b := strip.Bounds()
+	if n <= 0 || b.Dy()%n != 0 {
+		return nil, fmt.Errorf("failed to split a %vx%v image into %v layers", b.Dx(), b.Dy(), n)
+	}
+	h := b.Dy() / n
+	layers := make([]*image.NRGBA, n)
+	for i := range layers {
+		layers[i] = image.NewNRGBA(image.Rect(0, 0, b.Dx(), h))
+		draw.Draw(layers[i], layers[i].Rect, strip, image.Pt(b.Min.X, b.Min.Y+i*h), draw.Src)
+	}
+	return layers, nil
+}
+
+// sortedItems returns the images sorted by name, so packing is deterministic
+func sortedItems(images map[string]image.Image) ([]packItem, error) {
+	if len(images) == 0 {
+		return nil, fmt.Errorf("failed to pack: no images")
+	}
+	items := make([]packItem, 0, len(images))
+	for name, img := range images {
+		if img.Bounds().Empty() {
+			return nil, fmt.Errorf("failed to pack %v: the image is empty", name)
+		}
+		items = append(items, packItem{name: name, img: img})
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].name < items[j].name })
+	return items, nil
+}
+
+// shelfPack places the padded cells of items left to right in rows, reports
+// whether they fit
+func shelfPack(items []packItem, width, height, padding, align int) bool {
+	x, y, rowHeight := 0, 0, 0
+	for i := range items {
+		b := items[i].img.Bounds()
+		w, h := b.Dx()+2*padding, b.Dy()+2*padding
+		if x+w > width {
+			x, y, rowHeight = 0, roundUp(y+rowHeight, align), 0
+		}
+		if x+w > width || y+h > height {
+			return false
+		}
+		items[i].x, items[i].y = x, y
+		x = roundUp(x+w, align)
+		if h > rowHeight {
+			rowHeight = h
+		}
+	}
+	return true
+}
+
+// bleed draws img into the cell at x, y and fills the padding around it with its edge pixels
+func bleed(dst *image.NRGBA, img image.Image, x, y, padding int) {
+	src := ToNRGBA(img)
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	for dy := 0; dy < h+2*padding; dy++ {
+		sy := clamp(dy-padding, 0, h-1)
+		for dx := 0; dx < w+2*padding; dx++ {
+			sx := clamp(dx-padding, 0, w-1)
+			copy(dst.Pix[dst.PixOffset(x+dx, y+dy):][:4], src.Pix[src.PixOffset(sx, sy):][:4])
+		}
+	}
+}
+
+func roundUp(v, multiple int) int {
+	return (v + multiple - 1) / multiple * multiple
+}
+
+func clamp(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
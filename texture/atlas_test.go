@@ -0,0 +1,133 @@
+package texture
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+)
+
+// tile returns an image whose every pixel tells which tile and texel it is
+func tile(id uint8, w, h int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{id, uint8(x), uint8(y), 255})
+		}
+	}
+	return img
+}
+
+func testTiles() map[string]image.Image {
+	return map[string]image.Image{
+		"square": tile(1, 16, 16),
+		"tall":   tile(2, 8, 24),
+		"wide":   tile(3, 32, 8),
+		"odd":    tile(4, 5, 7),
+		"pixel":  tile(5, 1, 1),
+	}
+}
+
+// pixelRect returns the pixels the tile covers in the atlas, y going down
+func pixelRect(l *Lookup, t Tile) image.Rectangle {
+	w, h := float32(l.Width), float32(l.Height)
+	return image.Rect(int(t.Rect.U0*w+0.5), int((1-t.Rect.V1)*h+0.5), int(t.Rect.U1*w+0.5), int((1-t.Rect.V0)*h+0.5))
+}
+
+func TestPackAtlas(t *testing.T) {
+	for _, padding := range []int{0, 1, 2, 3, 4, 8} {
+		images := testTiles()
+		atlas, l, err := PackAtlas(images, padding)
+		if err != nil {
+			t.Fatalf("padding %v: %v", padding, err)
+		}
+		if l.Width != atlas.Rect.Dx() || l.Height != atlas.Rect.Dy() || l.Layers != 1 {
+			t.Errorf("padding %v: lookup is %vx%v in %v layers, atlas %v", padding, l.Width, l.Height, l.Layers, atlas.Rect)
+		}
+
+		// the highest level whose texels of 2^n pixels stay inside the padding
+		wantLevel := 0
+		for 2<<wantLevel <= padding {
+			wantLevel++
+		}
+		if l.MaxLevel != wantLevel {
+			t.Errorf("padding %v: MaxLevel is %v, want %v", padding, l.MaxLevel, wantLevel)
+		}
+
+		cells := make(map[string]image.Rectangle)
+		for name, img := range images {
+			tl, ok := l.Tile(name)
+			if !ok {
+				t.Fatalf("padding %v: %v is missing", padding, name)
+			}
+			r := pixelRect(l, tl)
+			if r.Size() != img.Bounds().Size() {
+				t.Errorf("padding %v: %v covers %v, want %v", padding, name, r, img.Bounds().Size())
+				continue
+			}
+			cell := r.Inset(-padding)
+			cells[name] = cell
+			if !cell.In(atlas.Rect) {
+				t.Errorf("padding %v: cell %v of %v is outside of the atlas", padding, cell, name)
+			}
+			if align := 1 << l.MaxLevel; cell.Min.X%align != 0 || cell.Min.Y%align != 0 {
+				t.Errorf("padding %v: cell %v of %v isn't aligned to %v", padding, cell, name, align)
+			}
+
+			// the tile and, in the padding, its nearest edge texel
+			src := img.(*image.NRGBA)
+			for y := cell.Min.Y; y < cell.Max.Y; y++ {
+				for x := cell.Min.X; x < cell.Max.X; x++ {
+					sx, sy := clamp(x-r.Min.X, 0, r.Dx()-1), clamp(y-r.Min.Y, 0, r.Dy()-1)
+					if got, want := atlas.NRGBAAt(x, y), src.NRGBAAt(sx, sy); got != want {
+						t.Fatalf("padding %v: %v pixel %v,%v is %v, want %v", padding, name, x, y, got, want)
+					}
+				}
+			}
+
+			// Map puts the corners of the tile on the corners of its pixels
+			if uv := tl.Rect.Map([2]float32{0, 1}); uv[0] != float32(r.Min.X)/float32(l.Width) || uv[1] != 1-float32(r.Min.Y)/float32(l.Height) {
+				t.Errorf("padding %v: the top left of %v maps to %v", padding, name, uv)
+			}
+		}
+
+		for a, ca := range cells {
+			for b, cb := range cells {
+				if a < b && ca.Overlaps(cb) {
+					t.Errorf("padding %v: %v at %v overlaps %v at %v", padding, a, ca, b, cb)
+				}
+			}
+		}
+	}
+}
+
+func TestPackAtlasErrors(t *testing.T) {
+	if _, _, err := PackAtlas(nil, 1); err == nil {
+		t.Error("packing no images didn't fail")
+	}
+	if _, _, err := PackAtlas(map[string]image.Image{"empty": tile(1, 0, 4)}, 1); err == nil {
+		t.Error("packing an empty image didn't fail")
+	}
+	if _, _, err := PackAtlas(map[string]image.Image{"huge": image.NewNRGBA(image.Rect(0, 0, maxAtlasSize, 1))}, 1); err == nil {
+		t.Error("packing an image larger than the atlas didn't fail")
+	}
+}
+
+func TestLookupRoundTrip(t *testing.T) {
+	_, l, err := PackAtlas(testTiles(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := l.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadLookup(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read, l) {
+		t.Errorf("read %+v, wrote %+v", read, l)
+	}
+}
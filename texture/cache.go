@@ -0,0 +1,49 @@
+package texture
+
+import "path/filepath"
+
+type cacheKey struct {
+	path    string
+	options Options
+}
+
+// Cache loads every texture once and hands out the same one afterwards. The
+// same file loaded with different Options is a different texture. It must only
+// be used from the GL thread.
+type Cache struct {
+	textures map[cacheKey]*Texture
+}
+
+// NewCache creates a new empty texture cache
+func NewCache() *Cache {
+	c := new(Cache)
+	c.textures = make(map[cacheKey]*Texture)
+	return c
+}
+
+// Get returns the texture of the file at path, loading it on first use
+func (c *Cache) Get(path string, options Options) (*Texture, error) {
+	key := cacheKey{filepath.Clean(path), options}
+	if t, ok := c.textures[key]; ok {
+		return t, nil
+	}
+	t, err := Load(path, options)
+	if err != nil {
+		return nil, err
+	}
+	c.textures[key] = t
+	return t, nil
+}
+
+// Len returns the number of cached textures
+func (c *Cache) Len() int {
+	return len(c.textures)
+}
+
+// Delete frees all cached textures
+func (c *Cache) Delete() {
+	for key, t := range c.textures {
+		t.Delete()
+		delete(c.textures, key)
+	}
+}
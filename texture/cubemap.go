@@ -0,0 +1,65 @@
+package texture
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+)
+
+// CubeFaces are the names of the images of a cube map directory, in the order
+// of the GL faces starting at gl.TEXTURE_CUBE_MAP_POSITIVE_X
+var CubeFaces = [6]string{"px", "nx", "py", "ny", "pz", "nz"}
+
+// LoadCubemap loads the six square faces of a cube map from the PNG or JPEG
+// files px, nx, py, ny, pz and nz in dir, must be called on the GL thread
+func LoadCubemap(dir string, options Options) (*Texture, error) {
+	images, err := LoadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var faces [6]image.Image
+	for i, name := range CubeFaces {
+		img, ok := images[name]
+		if !ok {
+			return nil, fmt.Errorf("failed to load cube map: %v has no %v", dir, name)
+		}
+		faces[i] = img
+	}
+	return NewCubemap(faces, options)
+}
+
+// NewCubemap uploads six square images of the same size as the faces of a cube
+// map, in the order of CubeFaces. Unlike in New the rows aren't flipped, cube
+// maps start at the top left. Must be called on the GL thread.
+func NewCubemap(faces [6]image.Image, options Options) (*Texture, error) {
+	size := faces[0].Bounds().Size()
+	t := new(Texture)
+	t.Target = gl.TEXTURE_CUBE_MAP
+	t.Width, t.Height = int32(size.X), int32(size.Y)
+	t.Layers = 6
+	t.Options = options
+	for i, face := range faces {
+		if s := face.Bounds().Size(); s != size || s.X != s.Y {
+			return nil, fmt.Errorf("failed to create cube map: face %v is %vx%v, all faces have to be %vx%v squares", CubeFaces[i], s.X, s.Y, size.X, size.X)
+		}
+	}
+
+	gl.GenTextures(1, &t.ID)
+	gl.BindTexture(gl.TEXTURE_CUBE_MAP, t.ID)
+	gl.PixelStorei(gl.UNPACK_ALIGNMENT, 1)
+	for i, face := range faces {
+		pixels := ToNRGBA(face)
+		gl.TexImage2D(gl.TEXTURE_CUBE_MAP_POSITIVE_X+uint32(i), 0, internalFormat(options.SRGB), t.Width, t.Height, 0, gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(pixels.Pix))
+	}
+	if options.Mipmaps {
+		gl.GenerateMipmap(gl.TEXTURE_CUBE_MAP)
+	}
+	applySampler(gl.TEXTURE_CUBE_MAP, options.Sampler)
+	// seams show between the faces unless they're clamped, whatever the sampler says
+	for _, wrap := range []uint32{gl.TEXTURE_WRAP_S, gl.TEXTURE_WRAP_T, gl.TEXTURE_WRAP_R} {
+		gl.TexParameteri(gl.TEXTURE_CUBE_MAP, wrap, gl.CLAMP_TO_EDGE)
+	}
+	gl.BindTexture(gl.TEXTURE_CUBE_MAP, 0)
+	return t, nil
+}
@@ -0,0 +1,175 @@
+// Package texture loads images into OpenGL textures
+package texture
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/jpeg" // register the decoders used by Decode
+	_ "image/png"
+	"os"
+	"time"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/tehcyx/goengine/util"
+)
+
+// Sampler tells how a texture is filtered and wrapped
+type Sampler struct {
+	MinFilter  int32 // e.g. gl.LINEAR_MIPMAP_LINEAR, mipmap filters need Options.Mipmaps
+	MagFilter  int32 // gl.LINEAR or gl.NEAREST
+	WrapS      int32 // e.g. gl.REPEAT or gl.CLAMP_TO_EDGE
+	WrapT      int32
+	Anisotropy float32 // max anisotropic filtering, clamped to what the driver supports, 1 turns it off
+	MaxLevel   int32   // highest mipmap level sampled, 0 leaves the GL default of all levels
+}
+
+// Options tell how an image is uploaded
+type Options struct {
+	SRGB    bool // the image holds colors, which GL converts to linear when sampling. Leave it off for normal maps and other data.
+	Mipmaps bool
+	Sampler Sampler
+}
+
+// DefaultOptions returns the options for color textures: sRGB, mipmapped,
+// trilinear with 8x anisotropic filtering and repeating
+func DefaultOptions() Options {
+	return Options{
+		SRGB:    true,
+		Mipmaps: true,
+		Sampler: Sampler{
+			MinFilter:  gl.LINEAR_MIPMAP_LINEAR,
+			MagFilter:  gl.LINEAR,
+			WrapS:      gl.REPEAT,
+			WrapT:      gl.REPEAT,
+			Anisotropy: 8,
+		},
+	}
+}
+
+// Texture is a 2D or 2D array texture uploaded to the GPU
+type Texture struct {
+	ID      uint32
+	Target  uint32 // gl.TEXTURE_2D or gl.TEXTURE_2D_ARRAY
+	Width   int32
+	Height  int32
+	Layers  int32 // 1 unless it's an array texture
+	Options Options
+}
+
+// Decode reads a PNG or JPEG file into non premultiplied RGBA pixels
+func Decode(path string) (*image.NRGBA, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %v: %v", path, err)
+	}
+	return ToNRGBA(img), nil
+}
+
+// ToNRGBA converts img to non premultiplied RGBA pixels, starting at 0, 0
+func ToNRGBA(img image.Image) *image.NRGBA {
+	if nrgba, ok := img.(*image.NRGBA); ok && nrgba.Rect.Min == (image.Point{}) {
+		return nrgba
+	}
+	b := img.Bounds()
+	nrgba := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(nrgba, nrgba.Rect, img, b.Min, draw.Src)
+	return nrgba
+}
+
+// Load decodes an image file and uploads it, must be called on the GL thread
+func Load(path string, options Options) (*Texture, error) {
+	defer util.TimeTrack(time.Now(), "texture.Load")
+	img, err := Decode(path)
+	if err != nil {
+		return nil, err
+	}
+	return New(img, options), nil
+}
+
+// New uploads an image. The rows are flipped, so texture coordinates start at
+// the bottom left of the image like in OBJ files. Must be called on the GL thread.
+func New(img image.Image, options Options) *Texture {
+	pixels := flip(ToNRGBA(img))
+	t := new(Texture)
+	t.Target = gl.TEXTURE_2D
+	t.Width, t.Height = int32(pixels.Rect.Dx()), int32(pixels.Rect.Dy())
+	t.Layers = 1
+	t.Options = options
+
+	gl.GenTextures(1, &t.ID)
+	gl.BindTexture(gl.TEXTURE_2D, t.ID)
+	gl.PixelStorei(gl.UNPACK_ALIGNMENT, 1)
+	gl.TexImage2D(gl.TEXTURE_2D, 0, internalFormat(options.SRGB), t.Width, t.Height, 0, gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(pixels.Pix))
+	if options.Mipmaps {
+		gl.GenerateMipmap(gl.TEXTURE_2D)
+	}
+	applySampler(gl.TEXTURE_2D, options.Sampler)
+	gl.BindTexture(gl.TEXTURE_2D, 0)
+	return t
+}
+
+// SetSampler changes how the texture is filtered and wrapped, must be called on the GL thread
+func (t *Texture) SetSampler(s Sampler) {
+	t.Options.Sampler = s
+	gl.BindTexture(t.Target, t.ID)
+	applySampler(t.Target, s)
+	gl.BindTexture(t.Target, 0)
+}
+
+// Bind binds the texture to a texture unit, 0 for gl.TEXTURE0
+func (t *Texture) Bind(unit uint32) {
+	gl.ActiveTexture(gl.TEXTURE0 + unit)
+	gl.BindTexture(t.Target, t.ID)
+}
+
+// Delete frees the texture, must be called on the GL thread
+func (t *Texture) Delete() {
+	gl.DeleteTextures(1, &t.ID)
+}
+
+func internalFormat(srgb bool) int32 {
+	if srgb {
+		return gl.SRGB8_ALPHA8
+	}
+	return gl.RGBA8
+}
+
+// applySampler sets the sampler parameters of the texture bound to target
+func applySampler(target uint32, s Sampler) {
+	gl.TexParameteri(target, gl.TEXTURE_MIN_FILTER, s.MinFilter)
+	gl.TexParameteri(target, gl.TEXTURE_MAG_FILTER, s.MagFilter)
+	gl.TexParameteri(target, gl.TEXTURE_WRAP_S, s.WrapS)
+	gl.TexParameteri(target, gl.TEXTURE_WRAP_T, s.WrapT)
+	if s.MaxLevel > 0 {
+		gl.TexParameteri(target, gl.TEXTURE_MAX_LEVEL, s.MaxLevel)
+	}
+	if s.Anisotropy > 1 {
+		// anisotropic filtering is an extension before GL 4.6, without it the
+		// query fails and max stays 0
+		var max float32
+		gl.GetFloatv(gl.MAX_TEXTURE_MAX_ANISOTROPY, &max)
+		gl.GetError()
+		if max > 1 {
+			if s.Anisotropy < max {
+				max = s.Anisotropy
+			}
+			gl.TexParameterf(target, gl.TEXTURE_MAX_ANISOTROPY, max)
+		}
+	}
+}
+
+// flip returns the image upside down
+func flip(img *image.NRGBA) *image.NRGBA {
+	h := img.Rect.Dy()
+	flipped := image.NewNRGBA(img.Rect)
+	for y := 0; y < h; y++ {
+		copy(flipped.Pix[y*flipped.Stride:(y+1)*flipped.Stride], img.Pix[(h-1-y)*img.Stride:])
+	}
+	return flipped
+}
@@ -0,0 +1,136 @@
+package vox
+
+import (
+	"image/color"
+	"sort"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/tehcyx/goengine/mesh"
+	"github.com/tehcyx/goengine/world"
+)
+
+// cube faces in engine axes, corners counter clockwise seen from outside
+var cubeFaces = [6]struct {
+	dir     [3]int
+	corners [4]mgl32.Vec3
+}{
+	{[3]int{1, 0, 0}, [4]mgl32.Vec3{{1, 0, 0}, {1, 1, 0}, {1, 1, 1}, {1, 0, 1}}},
+	{[3]int{-1, 0, 0}, [4]mgl32.Vec3{{0, 0, 1}, {0, 1, 1}, {0, 1, 0}, {0, 0, 0}}},
+	{[3]int{0, 1, 0}, [4]mgl32.Vec3{{0, 1, 0}, {0, 1, 1}, {1, 1, 1}, {1, 1, 0}}},
+	{[3]int{0, -1, 0}, [4]mgl32.Vec3{{0, 0, 0}, {1, 0, 0}, {1, 0, 1}, {0, 0, 1}}},
+	{[3]int{0, 0, 1}, [4]mgl32.Vec3{{1, 0, 1}, {1, 1, 1}, {0, 1, 1}, {0, 0, 1}}},
+	{[3]int{0, 0, -1}, [4]mgl32.Vec3{{0, 0, 0}, {0, 1, 0}, {1, 1, 0}, {1, 0, 0}}},
+}
+
+// MeshData meshes the whole scene with vertex colors, one unit per voxel in engine axes.
+// Faces between touching voxels are skipped, also across instances.
+func (f *File) MeshData() *mesh.Data {
+	voxels := make(map[[3]int]uint8)
+	for _, inst := range f.Instances() {
+		f.Voxels(inst, func(pos [3]int, colorIndex uint8) {
+			voxels[ToEngine(pos)] = colorIndex
+		})
+	}
+	return meshVoxels(voxels, &f.Palette)
+}
+
+// ModelMeshData meshes a single model centered on the origin, ignoring the scene graph
+func (f *File) ModelMeshData(model int) *mesh.Data {
+	voxels := make(map[[3]int]uint8)
+	f.Voxels(Instance{Model: model, Rotation: IdentityRotation}, func(pos [3]int, colorIndex uint8) {
+		voxels[ToEngine(pos)] = colorIndex
+	})
+	return meshVoxels(voxels, &f.Palette)
+}
+
+// LoadMesh reads the .vox file at path and uploads its scene as a mesh, must be called on the GL thread
+func LoadMesh(path string) (*mesh.Mesh, error) {
+	f, err := Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return mesh.NewMeshFromData(f.MeshData()), nil
+}
+
+func meshVoxels(voxels map[[3]int]uint8, palette *Palette) *mesh.Data {
+	// mesh in x, y, z order, so the vertices come out the same on every run
+	order := make([][3]int, 0, len(voxels))
+	for pos := range voxels {
+		order = append(order, pos)
+	}
+	sort.Slice(order, func(i, j int) bool {
+		a, b := order[i], order[j]
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		if a[1] != b[1] {
+			return a[1] < b[1]
+		}
+		return a[2] < b[2]
+	})
+
+	data := new(mesh.Data)
+	for _, pos := range order {
+		c := palette[voxels[pos]]
+		col := mgl32.Vec4{float32(c.R) / 255, float32(c.G) / 255, float32(c.B) / 255, float32(c.A) / 255}
+		origin := mgl32.Vec3{float32(pos[0]), float32(pos[1]), float32(pos[2])}
+		for _, face := range cubeFaces {
+			if _, ok := voxels[[3]int{pos[0] + face.dir[0], pos[1] + face.dir[1], pos[2] + face.dir[2]}]; ok {
+				continue
+			}
+			normal := mgl32.Vec3{float32(face.dir[0]), float32(face.dir[1]), float32(face.dir[2])}
+			base := uint32(len(data.Positions))
+			for _, corner := range face.corners {
+				data.Positions = append(data.Positions, origin.Add(corner))
+				data.Normals = append(data.Normals, normal)
+				data.Colors = append(data.Colors, col)
+			}
+			data.Indices = append(data.Indices, base, base+1, base+2, base, base+2, base+3)
+		}
+	}
+	return data
+}
+
+// BlockMapper picks the block type for a palette color
+type BlockMapper func(c color.RGBA) world.Block
+
+// NearestBlock maps a color to the block whose color is closest, alpha included
+// so translucent blocks like water come back as themselves
+func NearestBlock(c color.RGBA) world.Block {
+	best, bestDist := world.Stone, float32(-1)
+	v := mgl32.Vec4{float32(c.R) / 255, float32(c.G) / 255, float32(c.B) / 255, float32(c.A) / 255}
+	for b := world.Block(1); b < world.NumBlocks; b++ {
+		d := v.Sub(b.Info().Color)
+		if dist := d.Dot(d); bestDist < 0 || dist < bestDist {
+			best, bestDist = b, dist
+		}
+	}
+	return best
+}
+
+// PlaceInWorld writes the scene into the loaded chunks of the world with the scene
+// origin at origin, given in engine axes. Voxels in chunks that aren't loaded are
+// skipped. It returns the chunks that changed and need to be remeshed.
+func PlaceInWorld(w *world.World, f *File, origin [3]int, mapper BlockMapper) []world.ChunkPos {
+	blocks := make(map[uint8]world.Block)
+	changed := make(map[world.ChunkPos]bool)
+	for _, inst := range f.Instances() {
+		f.Voxels(inst, func(pos [3]int, colorIndex uint8) {
+			b, ok := blocks[colorIndex]
+			if !ok {
+				b = mapper(f.Palette[colorIndex])
+				blocks[colorIndex] = b
+			}
+			p := ToEngine(pos)
+			x, y, z := origin[0]+p[0], origin[1]+p[1], origin[2]+p[2]
+			if w.SetBlock(x, y, z, b) {
+				changed[world.ChunkPosAt(x, z)] = true
+			}
+		})
+	}
+	var dirty []world.ChunkPos
+	for pos := range changed {
+		dirty = append(dirty, pos)
+	}
+	return dirty
+}
@@ -0,0 +1,225 @@
+package vox
+
+import (
+	"fmt"
+	"image/color"
+	"sort"
+	"strconv"
+
+	"github.com/tehcyx/goengine/world"
+)
+
+// BlockColor returns the color of a block from its block info
+func BlockColor(b world.Block) color.RGBA {
+	c := b.Info().Color
+	return color.RGBA{uint8(c[0] * 255), uint8(c[1] * 255), uint8(c[2] * 255), uint8(c[3] * 255)}
+}
+
+// ExportRegion copies the blocks in the box from min to max (exclusive), given in
+// engine axes, into a new .vox scene. Blocks in chunks that aren't loaded export
+// as air. More than 255 distinct colors are reduced with median cut, boxes larger
+// than 256 voxels along an axis are split into several models. Importing the
+// result with PlaceInWorld at min restores the blocks at their original position.
+func ExportRegion(w *world.World, min, max [3]int, colorOf func(world.Block) color.RGBA) (*File, error) {
+	for i := range min {
+		if max[i] <= min[i] {
+			return nil, fmt.Errorf("vox: empty region %v to %v", min, max)
+		}
+	}
+
+	// collect the voxels in MagicaVoxel axes relative to min
+	type cell struct {
+		pos   [3]int
+		color color.RGBA
+	}
+	var cells []cell
+	counts := make(map[color.RGBA]int)
+	for x := min[0]; x < max[0]; x++ {
+		for y := min[1]; y < max[1]; y++ {
+			for z := min[2]; z < max[2]; z++ {
+				b := w.Block(x, y, z)
+				if b == world.Air {
+					continue
+				}
+				c := colorOf(b)
+				counts[c]++
+				cells = append(cells, cell{FromEngine([3]int{x - min[0], y - min[1], z - min[2]}), c})
+			}
+		}
+	}
+
+	f := &File{Version: Version, Nodes: make(map[int32]*Node)}
+	indices := reducePalette(counts, &f.Palette)
+
+	// the box in MagicaVoxel axes, see FromEngine
+	lo := [3]int{0, -(max[2] - min[2]), 0}
+	hi := [3]int{max[0] - min[0], 0, max[1] - min[1]}
+
+	models := make(map[[3]int]int)
+	for _, c := range cells {
+		var tile [3]int
+		for i := range tile {
+			tile[i] = (c.pos[i] - lo[i]) / MaxModelSize
+		}
+		m, ok := models[tile]
+		if !ok {
+			m = len(f.Models)
+			models[tile] = m
+			var size [3]int
+			for i := range size {
+				start := lo[i] + tile[i]*MaxModelSize
+				size[i] = hi[i] - start
+				if size[i] > MaxModelSize {
+					size[i] = MaxModelSize
+				}
+			}
+			f.Models = append(f.Models, Model{Size: size})
+		}
+		f.Models[m].Voxels = append(f.Models[m].Voxels, Voxel{
+			X:          uint8(c.pos[0] - lo[0] - tile[0]*MaxModelSize),
+			Y:          uint8(c.pos[1] - lo[1] - tile[1]*MaxModelSize),
+			Z:          uint8(c.pos[2] - lo[2] - tile[2]*MaxModelSize),
+			ColorIndex: indices[c.color],
+		})
+	}
+
+	// root transform, a group, and a transform plus shape for every model
+	tiles := make([][3]int, 0, len(models))
+	for tile := range models {
+		tiles = append(tiles, tile)
+	}
+	sort.Slice(tiles, func(i, j int) bool { return models[tiles[i]] < models[tiles[j]] })
+	group := &Node{ID: 1, Kind: GroupNode, Attributes: map[string]string{}}
+	f.Nodes[0] = &Node{ID: 0, Kind: TransformNode, Attributes: map[string]string{}, Child: 1, Layer: -1, Frames: []map[string]string{{}}}
+	f.Nodes[1] = group
+	for _, tile := range tiles {
+		m := models[tile]
+		size := f.Models[m].Size
+		// Voxels places v at v - size/2 + translation
+		var t [3]int
+		for i := range t {
+			t[i] = lo[i] + tile[i]*MaxModelSize + size[i]/2
+		}
+		trn := &Node{
+			ID:         int32(2 + 2*m),
+			Kind:       TransformNode,
+			Attributes: map[string]string{},
+			Child:      int32(3 + 2*m),
+			Frames:     []map[string]string{{"_t": strconv.Itoa(t[0]) + " " + strconv.Itoa(t[1]) + " " + strconv.Itoa(t[2])}},
+		}
+		shp := &Node{ID: int32(3 + 2*m), Kind: ShapeNode, Attributes: map[string]string{}, Models: []int32{int32(m)}}
+		f.Nodes[trn.ID] = trn
+		f.Nodes[shp.ID] = shp
+		group.Children = append(group.Children, trn.ID)
+	}
+	return f, nil
+}
+
+// colorBox is a set of colors for median cut
+type colorBox struct {
+	colors []color.RGBA
+}
+
+func (b *colorBox) widest() (channel, extent int) {
+	lo, hi := [4]int{255, 255, 255, 255}, [4]int{}
+	for _, c := range b.colors {
+		for i, v := range [4]uint8{c.R, c.G, c.B, c.A} {
+			if int(v) < lo[i] {
+				lo[i] = int(v)
+			}
+			if int(v) > hi[i] {
+				hi[i] = int(v)
+			}
+		}
+	}
+	for i := range lo {
+		if hi[i]-lo[i] > extent {
+			channel, extent = i, hi[i]-lo[i]
+		}
+	}
+	return channel, extent
+}
+
+// reducePalette fills the palette with at most 255 colors and returns the palette
+// index of every input color. Up to 255 colors are kept exactly, more are reduced
+// with median cut, weighted by how often each color is used.
+func reducePalette(counts map[color.RGBA]int, palette *Palette) map[color.RGBA]uint8 {
+	all := make([]color.RGBA, 0, len(counts))
+	for c := range counts {
+		all = append(all, c)
+	}
+	// sort for a stable palette, map order is random
+	sort.Slice(all, func(i, j int) bool { return rgbaLess(all[i], all[j]) })
+
+	boxes := []*colorBox{{all}}
+	for len(boxes) < 255 {
+		best, bestExtent, bestChannel := -1, 0, 0
+		for i, b := range boxes {
+			if len(b.colors) < 2 {
+				continue
+			}
+			if channel, extent := b.widest(); extent > bestExtent {
+				best, bestExtent, bestChannel = i, extent, channel
+			}
+		}
+		if best < 0 {
+			break
+		}
+		b := boxes[best]
+		sort.SliceStable(b.colors, func(i, j int) bool {
+			return channelOf(b.colors[i], bestChannel) < channelOf(b.colors[j], bestChannel)
+		})
+		// split at the weighted median
+		total := 0
+		for _, c := range b.colors {
+			total += counts[c]
+		}
+		split, acc := 1, 0
+		for i, c := range b.colors[:len(b.colors)-1] {
+			acc += counts[c]
+			if acc*2 >= total {
+				split = i + 1
+				break
+			}
+		}
+		boxes[best] = &colorBox{b.colors[:split]}
+		boxes = append(boxes, &colorBox{b.colors[split:]})
+	}
+
+	indices := make(map[color.RGBA]uint8, len(all))
+	for i, b := range boxes {
+		if len(b.colors) == 0 {
+			continue
+		}
+		var sum [4]int
+		n := 0
+		for _, c := range b.colors {
+			w := counts[c]
+			sum[0] += int(c.R) * w
+			sum[1] += int(c.G) * w
+			sum[2] += int(c.B) * w
+			sum[3] += int(c.A) * w
+			n += w
+			indices[c] = uint8(i + 1)
+		}
+		palette[i+1] = color.RGBA{uint8(sum[0] / n), uint8(sum[1] / n), uint8(sum[2] / n), uint8(sum[3] / n)}
+	}
+	return indices
+}
+
+func channelOf(c color.RGBA, channel int) uint8 {
+	return [4]uint8{c.R, c.G, c.B, c.A}[channel]
+}
+
+func rgbaLess(a, b color.RGBA) bool {
+	if a.R != b.R {
+		return a.R < b.R
+	}
+	if a.G != b.G {
+		return a.G < b.G
+	}
+	if a.B != b.B {
+		return a.B < b.B
+	}
+	return a.A < b.A
+}
@@ -0,0 +1,194 @@
+package vox
+
+import (
+	"bytes"
+	"image/color"
+	"testing"
+
+	"github.com/tehcyx/goengine/world"
+)
+
+// emptyWorld returns a world with empty chunks loaded around the box from min to max
+func emptyWorld(min, max [3]int) *world.World {
+	w := world.NewWorld(1)
+	w.Generator.Passes = nil
+	from, to := world.ChunkPosAt(min[0], min[2]), world.ChunkPosAt(max[0]-1, max[2]-1)
+	for x := from.X; x <= to.X; x++ {
+		for z := from.Z; z <= to.Z; z++ {
+			w.GenerateChunk(world.ChunkPos{X: x, Z: z})
+		}
+	}
+	return w
+}
+
+// roundTrip exports the box, encodes and decodes it and places it into an empty world
+func roundTrip(t *testing.T, src *world.World, min, max [3]int, colorOf func(world.Block) color.RGBA) (*File, *world.World) {
+	t.Helper()
+	exported, err := ExportRegion(src, min, max, colorOf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := Encode(&buf, exported); err != nil {
+		t.Fatal(err)
+	}
+	f, err := Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst := emptyWorld(min, max)
+	PlaceInWorld(dst, f, min, NearestBlock)
+	return f, dst
+}
+
+func compareBlocks(t *testing.T, src, dst *world.World, min, max [3]int) {
+	t.Helper()
+	for x := min[0]; x < max[0]; x++ {
+		for y := min[1]; y < max[1]; y++ {
+			for z := min[2]; z < max[2]; z++ {
+				if a, b := src.Block(x, y, z), dst.Block(x, y, z); a != b {
+					t.Fatalf("block %v,%v,%v was %v and came back as %v", x, y, z, a, b)
+				}
+			}
+		}
+	}
+}
+
+func TestExportRoundTrip(t *testing.T) {
+	// every block type, water and lava included, away from the origin and across a chunk border
+	min, max := [3]int{-5, 40, 10}, [3]int{8, 45, 14}
+	src := emptyWorld(min, max)
+	i := 0
+	for x := min[0]; x < max[0]; x++ {
+		for y := min[1]; y < max[1]; y++ {
+			for z := min[2]; z < max[2]; z++ {
+				src.SetBlock(x, y, z, world.Block(i%int(world.NumBlocks)))
+				i++
+			}
+		}
+	}
+	f, dst := roundTrip(t, src, min, max, BlockColor)
+	if len(f.Models) != 1 {
+		t.Errorf("got %v models, want 1", len(f.Models))
+	}
+	compareBlocks(t, src, dst, min, max)
+}
+
+func TestExportRoundTripLarge(t *testing.T) {
+	// larger than a model along x and z, which are x and -y in MagicaVoxel axes
+	min, max := [3]int{-20, 60, -30}, [3]int{280, 63, 240}
+	src := emptyWorld(min, max)
+	blocks := []world.Block{world.Stone, world.Water, world.Lava, world.Leaves, world.GoldOre}
+	i := 0
+	for x := min[0]; x < max[0]; x += 7 {
+		for z := min[2]; z < max[2]; z += 5 {
+			src.SetBlock(x, min[1]+i%3, z, blocks[i%len(blocks)])
+			i++
+		}
+	}
+	// the corners decide the size of the edge models
+	src.SetBlock(min[0], min[1], min[2], world.Dirt)
+	src.SetBlock(max[0]-1, max[1]-1, max[2]-1, world.Dirt)
+
+	f, dst := roundTrip(t, src, min, max, BlockColor)
+	if len(f.Models) != 4 {
+		t.Errorf("got %v models, want 4", len(f.Models))
+	}
+	for i, m := range f.Models {
+		for _, v := range m.Size {
+			if v > MaxModelSize {
+				t.Errorf("model %v is %v large", i, m.Size)
+			}
+		}
+	}
+	compareBlocks(t, src, dst, min, max)
+}
+
+func TestExportRoundTripManyColors(t *testing.T) {
+	min, max := [3]int{0, 10, 0}, [3]int{30, 20, 30}
+	src := emptyWorld(min, max)
+	i := 0
+	for x := min[0]; x < max[0]; x++ {
+		for y := min[1]; y < max[1]; y++ {
+			for z := min[2]; z < max[2]; z++ {
+				src.SetBlock(x, y, z, world.Block(1+i%int(world.NumBlocks-1)))
+				i++
+			}
+		}
+	}
+	// shade every block a little differently, far more colors than a palette holds
+	shades := make(map[color.RGBA]bool)
+	n := 0
+	shade := func(v uint8, d int) uint8 {
+		if v > 250 {
+			return v - uint8(d)
+		}
+		return v + uint8(d)
+	}
+	shaded := func(b world.Block) color.RGBA {
+		c := BlockColor(b)
+		c.R, c.G, c.B = shade(c.R, n%5), shade(c.G, n/5%5), shade(c.B, n/25%5)
+		n++
+		shades[c] = true
+		return c
+	}
+	f, dst := roundTrip(t, src, min, max, shaded)
+	if len(shades) <= 255 {
+		t.Fatalf("only %v colors, the palette isn't reduced", len(shades))
+	}
+	used := make(map[uint8]bool)
+	for _, m := range f.Models {
+		for _, v := range m.Voxels {
+			used[v.ColorIndex] = true
+		}
+	}
+	if len(used) > 255 || used[0] {
+		t.Errorf("%v palette entries used, index 0 among them: %v", len(used), used[0])
+	}
+	compareBlocks(t, src, dst, min, max)
+}
+
+func TestReducePalette(t *testing.T) {
+	counts := make(map[color.RGBA]int)
+	for r := 0; r < 256; r += 16 {
+		for g := 0; g < 256; g += 32 {
+			for b := 0; b < 256; b += 64 {
+				counts[color.RGBA{uint8(r), uint8(g), uint8(b), 255}] = 1 + r
+			}
+		}
+	}
+	var p Palette
+	indices := reducePalette(counts, &p)
+	if len(indices) != len(counts) {
+		t.Fatalf("%v of %v colors have an index", len(indices), len(counts))
+	}
+	for c, i := range indices {
+		if i == 0 {
+			t.Fatalf("color %v got index 0", c)
+		}
+		// 512 colors in 255 boxes, each stays within one step of the grid of its entry
+		q := p[i]
+		for _, d := range []int{int(c.R) - int(q.R), int(c.G) - int(q.G), int(c.B) - int(q.B)} {
+			if d < -64 || d > 64 {
+				t.Errorf("color %v is reduced to %v", c, q)
+			}
+		}
+	}
+
+	// few colors are kept exactly
+	exact := map[color.RGBA]int{{1, 2, 3, 255}: 1, {200, 100, 0, 153}: 5}
+	p = Palette{}
+	for c, i := range reducePalette(exact, &p) {
+		if p[i] != c {
+			t.Errorf("color %v became %v", c, p[i])
+		}
+	}
+}
+
+func TestNearestBlock(t *testing.T) {
+	for b := world.Block(1); b < world.NumBlocks; b++ {
+		if got := NearestBlock(BlockColor(b)); got != b {
+			t.Errorf("the color of %v maps to %v", b, got)
+		}
+	}
+}
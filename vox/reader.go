@@ -0,0 +1,254 @@
+package vox
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"image/color"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/tehcyx/goengine/util"
+)
+
+// Open reads the .vox file at path
+func Open(path string) (*File, error) {
+	defer util.TimeTrack(time.Now(), "vox Open")
+	fileHandle, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fileHandle.Close()
+	return Decode(bufio.NewReader(fileHandle))
+}
+
+// Decode reads a .vox file. It understands the SIZE, XYZI and RGBA chunks and the
+// nTRN, nGRP and nSHP scene graph chunks, every other chunk is skipped.
+func Decode(r io.Reader) (*File, error) {
+	d := &decoder{r: r}
+	header := string(d.bytes(4))
+	version := d.int32()
+	if d.err != nil {
+		return nil, fmt.Errorf("vox: failed to read header: %v", d.err)
+	}
+	if header != magic {
+		return nil, fmt.Errorf("vox: not a .vox file")
+	}
+
+	f := &File{Version: int(version), Palette: DefaultPalette, Nodes: make(map[int32]*Node)}
+	id, content, children := d.chunkHeader()
+	if d.err != nil {
+		return nil, fmt.Errorf("vox: failed to read MAIN chunk: %v", d.err)
+	}
+	if id != "MAIN" {
+		return nil, fmt.Errorf("vox: expected MAIN chunk, got %q", id)
+	}
+	d.skip(content)
+
+	d.remaining = int64(children)
+	var size *[3]int
+	for d.remaining > 0 && d.err == nil {
+		id, content, children := d.chunkHeader()
+		if d.err != nil {
+			break
+		}
+		if content < 0 || children < 0 || int64(content)+int64(children) > d.remaining {
+			return nil, fmt.Errorf("vox: chunk %q exceeds the MAIN chunk", id)
+		}
+		start := d.remaining
+		switch id {
+		case "SIZE":
+			s := [3]int{int(d.int32()), int(d.int32()), int(d.int32())}
+			for _, v := range s {
+				if v < 0 || v > MaxModelSize {
+					return nil, fmt.Errorf("vox: model size %v out of range", s)
+				}
+			}
+			size = &s
+		case "XYZI":
+			if size == nil {
+				return nil, fmt.Errorf("vox: XYZI chunk without SIZE")
+			}
+			n := d.int32()
+			if n < 0 || int64(n)*4 > int64(content)-4 || int(n) > size[0]*size[1]*size[2] {
+				return nil, fmt.Errorf("vox: XYZI chunk holds %d voxels in %d bytes", n, content)
+			}
+			m := Model{Size: *size, Voxels: make([]Voxel, n)}
+			for i := range m.Voxels {
+				b := d.bytes(4)
+				if d.err != nil {
+					break
+				}
+				v := Voxel{b[0], b[1], b[2], b[3]}
+				if int(v.X) >= m.Size[0] || int(v.Y) >= m.Size[1] || int(v.Z) >= m.Size[2] {
+					return nil, fmt.Errorf("vox: voxel %v outside of model size %v", v, m.Size)
+				}
+				m.Voxels[i] = v
+			}
+			f.Models = append(f.Models, m)
+			size = nil
+		case "RGBA":
+			for i := 0; i < 256; i++ {
+				b := d.bytes(4)
+				if d.err != nil {
+					break
+				}
+				// palette entry i holds color index i+1, the last entry is unused
+				if i < 255 {
+					f.Palette[i+1] = color.RGBA{b[0], b[1], b[2], b[3]}
+				}
+			}
+		case "nTRN":
+			n := &Node{Kind: TransformNode}
+			n.ID = d.int32()
+			n.Attributes = d.dict()
+			n.Child = d.int32()
+			d.int32() // reserved
+			n.Layer = d.int32()
+			frames := d.int32()
+			if frames < 0 || int64(frames)*4 > int64(content) {
+				return nil, fmt.Errorf("vox: bad frame count %d", frames)
+			}
+			for i := int32(0); i < frames && d.err == nil; i++ {
+				n.Frames = append(n.Frames, d.dict())
+			}
+			f.Nodes[n.ID] = n
+		case "nGRP":
+			n := &Node{Kind: GroupNode}
+			n.ID = d.int32()
+			n.Attributes = d.dict()
+			count := d.int32()
+			if count < 0 || int64(count)*4 > int64(content) {
+				return nil, fmt.Errorf("vox: bad child count %d", count)
+			}
+			for i := int32(0); i < count && d.err == nil; i++ {
+				n.Children = append(n.Children, d.int32())
+			}
+			f.Nodes[n.ID] = n
+		case "nSHP":
+			n := &Node{Kind: ShapeNode}
+			n.ID = d.int32()
+			n.Attributes = d.dict()
+			count := d.int32()
+			if count < 0 || int64(count)*4 > int64(content) {
+				return nil, fmt.Errorf("vox: bad model count %d", count)
+			}
+			for i := int32(0); i < count && d.err == nil; i++ {
+				n.Models = append(n.Models, d.int32())
+				d.dict() // model attributes
+			}
+			f.Nodes[n.ID] = n
+		}
+		if d.err != nil {
+			break
+		}
+		read := start - d.remaining
+		if read > int64(content) {
+			return nil, fmt.Errorf("vox: chunk %q is larger than declared", id)
+		}
+		// skip what we didn't read, including children of chunks we don't know
+		d.skip(content - int32(read) + children)
+	}
+	if d.err != nil {
+		return nil, fmt.Errorf("vox: %v", d.err)
+	}
+	if err := f.validateNodes(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// validateNodes checks the scene graph references, so traversing it can't fail
+func (f *File) validateNodes() error {
+	for _, n := range f.Nodes {
+		switch n.Kind {
+		case TransformNode:
+			if _, ok := f.Nodes[n.Child]; !ok {
+				return fmt.Errorf("vox: node %d references missing child %d", n.ID, n.Child)
+			}
+		case GroupNode:
+			for _, c := range n.Children {
+				if _, ok := f.Nodes[c]; !ok {
+					return fmt.Errorf("vox: node %d references missing child %d", n.ID, c)
+				}
+			}
+		case ShapeNode:
+			for _, m := range n.Models {
+				if m < 0 || int(m) >= len(f.Models) {
+					return fmt.Errorf("vox: node %d references missing model %d", n.ID, m)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// decoder reads little endian values and remembers the first error
+type decoder struct {
+	r         io.Reader
+	err       error
+	remaining int64 // bytes left in the MAIN chunk
+	buf       [4]byte
+}
+
+func (d *decoder) bytes(n int) []byte {
+	if d.err != nil {
+		return make([]byte, n)
+	}
+	// small reads reuse the buffer, callers must not keep the returned slice
+	var b []byte
+	if n > len(d.buf) {
+		b = make([]byte, n)
+	} else {
+		b = d.buf[:n]
+	}
+	_, d.err = io.ReadFull(d.r, b)
+	d.remaining -= int64(n)
+	return b
+}
+
+func (d *decoder) int32() int32 {
+	return int32(binary.LittleEndian.Uint32(d.bytes(4)))
+}
+
+func (d *decoder) string() string {
+	n := d.int32()
+	if d.err == nil && (n < 0 || int64(n) > d.remaining) {
+		d.err = fmt.Errorf("string of %d bytes exceeds chunk", n)
+	}
+	if d.err != nil {
+		return ""
+	}
+	return string(d.bytes(int(n)))
+}
+
+func (d *decoder) dict() map[string]string {
+	n := d.int32()
+	if d.err == nil && (n < 0 || int64(n)*8 > d.remaining) {
+		d.err = fmt.Errorf("dictionary of %d entries exceeds chunk", n)
+	}
+	dict := make(map[string]string)
+	for i := int32(0); i < n && d.err == nil; i++ {
+		key := d.string()
+		dict[key] = d.string()
+	}
+	return dict
+}
+
+func (d *decoder) chunkHeader() (id string, content, children int32) {
+	id = string(d.bytes(4))
+	content = d.int32()
+	children = d.int32()
+	return id, content, children
+}
+
+func (d *decoder) skip(n int32) {
+	if d.err != nil || n <= 0 {
+		return
+	}
+	var copied int64
+	copied, d.err = io.CopyN(ioutil.Discard, d.r, int64(n))
+	d.remaining -= copied
+}
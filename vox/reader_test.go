@@ -0,0 +1,257 @@
+package vox
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image/color"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fixture builds .vox bytes by hand, independent of the encoder
+type fixture struct {
+	bytes.Buffer
+}
+
+func (b *fixture) int32(values ...int32) *fixture {
+	for _, v := range values {
+		binary.Write(b, binary.LittleEndian, v)
+	}
+	return b
+}
+
+func (b *fixture) string(s string) *fixture {
+	b.int32(int32(len(s)))
+	b.WriteString(s)
+	return b
+}
+
+// dict writes alternating keys and values
+func (b *fixture) dict(pairs ...string) *fixture {
+	b.int32(int32(len(pairs) / 2))
+	for _, s := range pairs {
+		b.string(s)
+	}
+	return b
+}
+
+func chunk(id string, content []byte, children ...[]byte) []byte {
+	var kids []byte
+	for _, c := range children {
+		kids = append(kids, c...)
+	}
+	b := new(fixture)
+	b.WriteString(id)
+	b.int32(int32(len(content)), int32(len(kids)))
+	b.Write(content)
+	b.Write(kids)
+	return b.Bytes()
+}
+
+func voxFile(children ...[]byte) []byte {
+	b := new(fixture)
+	b.WriteString("VOX ")
+	b.int32(150)
+	b.Write(chunk("MAIN", nil, children...))
+	return b.Bytes()
+}
+
+func sizeChunk(x, y, z int32) []byte {
+	return chunk("SIZE", new(fixture).int32(x, y, z).Bytes())
+}
+
+func xyziChunk(voxels ...Voxel) []byte {
+	b := new(fixture).int32(int32(len(voxels)))
+	for _, v := range voxels {
+		b.Write([]byte{v.X, v.Y, v.Z, v.ColorIndex})
+	}
+	return chunk("XYZI", b.Bytes())
+}
+
+func transformChunk(id, child int32, frame ...string) []byte {
+	b := new(fixture).int32(id).dict().int32(child, -1, 0, 1).dict(frame...)
+	return chunk("nTRN", b.Bytes())
+}
+
+func groupChunk(id int32, children ...int32) []byte {
+	b := new(fixture).int32(id).dict().int32(int32(len(children))).int32(children...)
+	return chunk("nGRP", b.Bytes())
+}
+
+func shapeChunk(id, model int32) []byte {
+	b := new(fixture).int32(id).dict().int32(1, model).dict()
+	return chunk("nSHP", b.Bytes())
+}
+
+func decode(t *testing.T, data []byte) *File {
+	t.Helper()
+	f, err := Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestDecodeModelAndPalette(t *testing.T) {
+	palette := new(fixture)
+	for i := 0; i < 256; i++ {
+		palette.Write([]byte{byte(i), 2, 3, 255})
+	}
+	f := decode(t, voxFile(
+		sizeChunk(2, 3, 4),
+		xyziChunk(Voxel{0, 0, 0, 1}, Voxel{1, 2, 3, 200}),
+		chunk("RGBA", palette.Bytes()),
+		chunk("XTRA", []byte{1, 2, 3}), // unknown chunks are skipped
+	))
+	if len(f.Models) != 1 {
+		t.Fatalf("got %v models, want 1", len(f.Models))
+	}
+	want := Model{Size: [3]int{2, 3, 4}, Voxels: []Voxel{{0, 0, 0, 1}, {1, 2, 3, 200}}}
+	if !reflect.DeepEqual(f.Models[0], want) {
+		t.Errorf("got model %v, want %v", f.Models[0], want)
+	}
+	// entry i of the chunk is color index i+1
+	if got := f.Palette[1]; got != (color.RGBA{0, 2, 3, 255}) {
+		t.Errorf("palette[1] = %v", got)
+	}
+	if got := f.Palette[200]; got != (color.RGBA{199, 2, 3, 255}) {
+		t.Errorf("palette[200] = %v", got)
+	}
+}
+
+func TestDecodeDefaultPalette(t *testing.T) {
+	f := decode(t, voxFile(sizeChunk(1, 1, 1), xyziChunk(Voxel{0, 0, 0, 1})))
+	if f.Palette != DefaultPalette {
+		t.Error("a file without RGBA chunk doesn't use the default palette")
+	}
+	if got := f.Palette[1]; got != (color.RGBA{255, 255, 255, 255}) {
+		t.Errorf("default palette[1] = %v, want white", got)
+	}
+}
+
+func TestDecodeMultipleModels(t *testing.T) {
+	f := decode(t, voxFile(
+		sizeChunk(1, 1, 1), xyziChunk(Voxel{0, 0, 0, 5}),
+		sizeChunk(4, 4, 4), xyziChunk(Voxel{3, 3, 3, 6}, Voxel{1, 2, 3, 7}),
+	))
+	if len(f.Models) != 2 {
+		t.Fatalf("got %v models, want 2", len(f.Models))
+	}
+	if f.Models[1].Size != [3]int{4, 4, 4} || len(f.Models[1].Voxels) != 2 {
+		t.Errorf("second model is %v", f.Models[1])
+	}
+	want := []Instance{{0, IdentityRotation, [3]int{}}, {1, IdentityRotation, [3]int{}}}
+	if got := f.Instances(); !reflect.DeepEqual(got, want) {
+		t.Errorf("instances without scene graph: got %v, want %v", got, want)
+	}
+}
+
+func TestDecodeSceneGraph(t *testing.T) {
+	// a quarter turn around z: x becomes y, y becomes -x
+	quarter := Rotation{{0, 1, 0}, {-1, 0, 0}, {0, 0, 1}}
+	f := decode(t, voxFile(
+		sizeChunk(2, 2, 2), xyziChunk(Voxel{1, 0, 0, 1}),
+		sizeChunk(2, 2, 2), xyziChunk(Voxel{1, 0, 0, 2}),
+		transformChunk(0, 1, "_t", "1 2 3"),
+		groupChunk(1, 2, 4),
+		transformChunk(2, 3, "_t", "10 0 0"),
+		shapeChunk(3, 0),
+		transformChunk(4, 5, "_r", "33", "_t", "0 0 5"),
+		shapeChunk(5, 1),
+	))
+	if r, err := ParseRotation(33); err != nil || r != quarter {
+		t.Fatalf("ParseRotation(33) = %v, %v, want %v", r, err, quarter)
+	}
+	want := []Instance{
+		{0, IdentityRotation, [3]int{11, 2, 3}},
+		{1, quarter, [3]int{1, 2, 8}},
+	}
+	instances := f.Instances()
+	if !reflect.DeepEqual(instances, want) {
+		t.Fatalf("got instances %v, want %v", instances, want)
+	}
+
+	// the voxel at 1,0,0 of a 2x2x2 model is the cell 0,-1,-1 around the center
+	wantPos := [][3]int{{11, 1, 2}, {0, 1, 7}}
+	for i, inst := range instances {
+		var got [][3]int
+		f.Voxels(inst, func(pos [3]int, colorIndex uint8) {
+			got = append(got, pos)
+		})
+		if len(got) != 1 || got[0] != wantPos[i] {
+			t.Errorf("instance %v: voxel at %v, want %v", i, got, wantPos[i])
+		}
+	}
+}
+
+func TestDecodeHiddenTransform(t *testing.T) {
+	hidden := new(fixture).int32(0).dict("_hidden", "1").int32(1, -1, 0, 1).dict()
+	f := decode(t, voxFile(
+		sizeChunk(1, 1, 1), xyziChunk(Voxel{0, 0, 0, 1}),
+		chunk("nTRN", hidden.Bytes()),
+		shapeChunk(1, 0),
+	))
+	if got := f.Instances(); len(got) != 0 {
+		t.Errorf("hidden transform placed %v", got)
+	}
+}
+
+func TestDecodeMalformed(t *testing.T) {
+	valid := voxFile(sizeChunk(2, 2, 2), xyziChunk(Voxel{1, 1, 1, 1}))
+	tests := []struct {
+		name string
+		data []byte
+		err  string
+	}{
+		{"empty", nil, "header"},
+		{"bad magic", append([]byte("VOXX"), valid[4:]...), "not a .vox file"},
+		{"no MAIN", append(append([]byte(nil), valid[:8]...), chunk("SIZE", nil)...), "expected MAIN"},
+		{"truncated header", valid[:6], "header"},
+		{"truncated chunk", valid[:len(valid)-3], "EOF"},
+		{"truncated MAIN", valid[:14], "MAIN"},
+		{"chunk exceeds MAIN", func() []byte {
+			d := append([]byte(nil), valid...)
+			binary.LittleEndian.PutUint32(d[20+4:], 1000) // content size of SIZE
+			return d
+		}(), "exceeds the MAIN chunk"},
+		{"XYZI without SIZE", voxFile(xyziChunk(Voxel{0, 0, 0, 1})), "without SIZE"},
+		{"size out of range", voxFile(sizeChunk(1, 300, 1)), "out of range"},
+		{"negative size", voxFile(sizeChunk(1, -1, 1)), "out of range"},
+		{"voxel outside model", voxFile(sizeChunk(2, 2, 2), xyziChunk(Voxel{2, 0, 0, 1})), "outside of model"},
+		{"too many voxels", voxFile(sizeChunk(1, 1, 1), xyziChunk(Voxel{0, 0, 0, 1}, Voxel{0, 0, 0, 2})), "holds 2 voxels"},
+		{"voxel count past chunk", voxFile(sizeChunk(2, 2, 2), chunk("XYZI", new(fixture).int32(5).Bytes())), "holds 5 voxels"},
+		{"missing child", voxFile(transformChunk(0, 7)), "missing child 7"},
+		{"missing group child", voxFile(groupChunk(0, 3)), "missing child 3"},
+		{"missing model", voxFile(shapeChunk(0, 2)), "missing model 2"},
+		{"bad dictionary", voxFile(chunk("nGRP", new(fixture).int32(0, 1000).Bytes())), "dictionary"},
+		{"bad string", voxFile(chunk("nGRP", new(fixture).int32(0, 1, 1000, 0, 0, 0).Bytes())), "string"},
+		{"bad child count", voxFile(chunk("nGRP", new(fixture).int32(0).dict().int32(-2).Bytes())), "child count"},
+		{"chunk larger than declared", voxFile(chunk("nSHP", new(fixture).int32(0).Bytes())), ""},
+	}
+	for _, test := range tests {
+		_, err := Decode(bytes.NewReader(test.data))
+		if err == nil {
+			t.Errorf("%v: no error", test.name)
+		} else if !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%v: got error %q, want it to mention %q", test.name, err, test.err)
+		}
+	}
+}
+
+func TestMeshDataDeterministic(t *testing.T) {
+	f := decode(t, voxFile(
+		sizeChunk(4, 4, 4),
+		xyziChunk(Voxel{0, 0, 0, 1}, Voxel{2, 1, 0, 2}, Voxel{3, 3, 3, 3}, Voxel{1, 2, 3, 4}, Voxel{0, 1, 0, 5}),
+	))
+	first := f.MeshData()
+	for i := 0; i < 10; i++ {
+		if !reflect.DeepEqual(f.MeshData(), first) {
+			t.Fatal("meshing the same file twice gave different vertices")
+		}
+	}
+	// 5 voxels with 30 faces, the two touching ones hide 2 of them
+	if got := len(first.Positions); got != 28*4 {
+		t.Errorf("got %v vertices, want %v", got, 28*4)
+	}
+}
@@ -0,0 +1,178 @@
+package vox
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Rotation is an axis aligned rotation, each row has a single entry of 1 or -1
+type Rotation [3][3]int
+
+// IdentityRotation leaves voxels unchanged
+var IdentityRotation = Rotation{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}
+
+// Mul returns r * o
+func (r Rotation) Mul(o Rotation) Rotation {
+	var m Rotation
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			for k := 0; k < 3; k++ {
+				m[i][j] += r[i][k] * o[k][j]
+			}
+		}
+	}
+	return m
+}
+
+// Apply rotates v
+func (r Rotation) Apply(v [3]int) [3]int {
+	var out [3]int
+	for i := 0; i < 3; i++ {
+		out[i] = r[i][0]*v[0] + r[i][1]*v[1] + r[i][2]*v[2]
+	}
+	return out
+}
+
+// ParseRotation decodes the packed rotation byte of a transform frame. Bits 0-1
+// hold the column of the non zero entry of the first row, bits 2-3 that of the
+// second row, bits 4-6 the signs of the three rows.
+func ParseRotation(b byte) (Rotation, error) {
+	c0, c1 := int(b&3), int(b>>2&3)
+	if c0 > 2 || c1 > 2 || c0 == c1 {
+		return Rotation{}, fmt.Errorf("vox: invalid rotation %d", b)
+	}
+	c2 := 3 - c0 - c1
+	var r Rotation
+	for row, col := range [3]int{c0, c1, c2} {
+		r[row][col] = 1
+		if b&(1<<uint(4+row)) != 0 {
+			r[row][col] = -1
+		}
+	}
+	return r, nil
+}
+
+// Byte packs the rotation the way ParseRotation reads it
+func (r Rotation) Byte() byte {
+	var b byte
+	for row := 0; row < 3; row++ {
+		for col := 0; col < 3; col++ {
+			if r[row][col] == 0 {
+				continue
+			}
+			if row < 2 {
+				b |= byte(col) << uint(2*row)
+			}
+			if r[row][col] < 0 {
+				b |= 1 << uint(4+row)
+			}
+		}
+	}
+	return b
+}
+
+// Instance is a model placed in the scene. A voxel v of the model ends up at
+// Rotation * (v - Size/2) + Translation, in MagicaVoxel axes. Rotations turn
+// voxels around the corner of the cell at the origin, so mirroring keeps cells intact.
+type Instance struct {
+	Model       int
+	Rotation    Rotation
+	Translation [3]int
+}
+
+// Instances flattens the scene graph into placed models, using the first frame of
+// every transform. Files without a scene graph place every model at the origin.
+func (f *File) Instances() []Instance {
+	root, ok := f.Nodes[0]
+	if !ok {
+		instances := make([]Instance, len(f.Models))
+		for i := range f.Models {
+			instances[i] = Instance{Model: i, Rotation: IdentityRotation}
+		}
+		return instances
+	}
+	var instances []Instance
+	f.walk(root, IdentityRotation, [3]int{}, make(map[int32]bool), &instances)
+	return instances
+}
+
+func (f *File) walk(n *Node, rot Rotation, trans [3]int, visited map[int32]bool, out *[]Instance) {
+	// a malformed file could contain cycles
+	if visited[n.ID] {
+		return
+	}
+	visited[n.ID] = true
+	defer delete(visited, n.ID)
+
+	switch n.Kind {
+	case TransformNode:
+		if n.Attributes["_hidden"] == "1" {
+			return
+		}
+		r, t := IdentityRotation, [3]int{}
+		if len(n.Frames) > 0 {
+			r, t = parseFrame(n.Frames[0])
+		}
+		// parent * (r * v + t) + parent translation
+		childRot := rot.Mul(r)
+		rt := rot.Apply(t)
+		childTrans := [3]int{trans[0] + rt[0], trans[1] + rt[1], trans[2] + rt[2]}
+		f.walk(f.Nodes[n.Child], childRot, childTrans, visited, out)
+	case GroupNode:
+		for _, c := range n.Children {
+			f.walk(f.Nodes[c], rot, trans, visited, out)
+		}
+	case ShapeNode:
+		for _, m := range n.Models {
+			*out = append(*out, Instance{int(m), rot, trans})
+		}
+	}
+}
+
+// parseFrame reads the _r and _t attributes of a transform frame, ignoring malformed values
+func parseFrame(frame map[string]string) (Rotation, [3]int) {
+	r := IdentityRotation
+	if v, err := strconv.Atoi(frame["_r"]); err == nil && v >= 0 && v < 256 {
+		if parsed, err := ParseRotation(byte(v)); err == nil {
+			r = parsed
+		}
+	}
+	var t [3]int
+	fields := strings.Fields(frame["_t"])
+	if len(fields) == 3 {
+		for i, field := range fields {
+			t[i], _ = strconv.Atoi(field)
+		}
+	}
+	return r, t
+}
+
+// Voxels calls fn for every voxel of the instance with its position in MagicaVoxel scene axes
+func (f *File) Voxels(inst Instance, fn func(pos [3]int, colorIndex uint8)) {
+	m := f.Models[inst.Model]
+	half := [3]int{m.Size[0] / 2, m.Size[1] / 2, m.Size[2] / 2}
+	for _, v := range m.Voxels {
+		if v.ColorIndex == 0 {
+			continue
+		}
+		// rotate the doubled cell center, which is always odd and maps back exactly
+		q := inst.Rotation.Apply([3]int{2*(int(v.X)-half[0]) + 1, 2*(int(v.Y)-half[1]) + 1, 2*(int(v.Z)-half[2]) + 1})
+		var pos [3]int
+		for i := range pos {
+			pos[i] = (q[i]-1)/2 + inst.Translation[i]
+		}
+		fn(pos, v.ColorIndex)
+	}
+}
+
+// ToEngine converts a voxel cell from MagicaVoxel axes, right handed with Z up,
+// to engine axes, right handed with Y up
+func ToEngine(p [3]int) [3]int {
+	return [3]int{p[0], p[2], -p[1] - 1}
+}
+
+// FromEngine is the inverse of ToEngine
+func FromEngine(p [3]int) [3]int {
+	return [3]int{p[0], -p[2] - 1, p[1]}
+}
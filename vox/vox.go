@@ -0,0 +1,104 @@
+package vox
+
+import (
+	"image/color"
+)
+
+// MagicaVoxel files are a tree of chunks below a MAIN chunk. Every chunk has a
+// four character id, the size of its content and the size of its children.
+const (
+	magic   = "VOX "
+	Version = 150
+
+	MaxModelSize = 256 // models are at most 256 voxels along each axis
+)
+
+// Voxel is a single voxel of a model. ColorIndex points into the palette, 0 is empty.
+type Voxel struct {
+	X, Y, Z    uint8
+	ColorIndex uint8
+}
+
+// Model is a dense box of voxels, sizes are in MagicaVoxel axes where Z is up
+type Model struct {
+	Size   [3]int
+	Voxels []Voxel
+}
+
+// Palette maps color indices to colors, index 0 is unused
+type Palette [256]color.RGBA
+
+// NodeKind is the type of a scene graph node
+type NodeKind int
+
+const (
+	TransformNode NodeKind = iota // nTRN: places its single child
+	GroupNode                     // nGRP: holds a list of children
+	ShapeNode                     // nSHP: references models
+)
+
+// Node is a node of the scene graph
+type Node struct {
+	ID         int32
+	Kind       NodeKind
+	Attributes map[string]string
+
+	// transform nodes
+	Child  int32
+	Layer  int32
+	Frames []map[string]string // _r rotation, _t translation of each animation frame
+
+	// group nodes
+	Children []int32
+
+	// shape nodes
+	Models []int32
+}
+
+// File is a decoded .vox file
+type File struct {
+	Version int
+	Models  []Model
+	Palette Palette
+	Nodes   map[int32]*Node // empty for files without a scene graph
+}
+
+// DefaultPalette is the palette MagicaVoxel uses for files without an RGBA chunk
+var DefaultPalette = defaultPalette()
+
+func defaultPalette() Palette {
+	var p Palette
+	i := 1
+	// a 6x6x6 color cube from white down to, but without, black
+	for r := 5; r >= 0; r-- {
+		for g := 5; g >= 0; g-- {
+			for b := 5; b >= 0; b-- {
+				if r == 0 && g == 0 && b == 0 {
+					continue
+				}
+				p[i] = color.RGBA{uint8(r * 0x33), uint8(g * 0x33), uint8(b * 0x33), 0xff}
+				i++
+			}
+		}
+	}
+	// ramps of red, green, blue and grey with the steps the cube doesn't have
+	ramp := []uint8{0xee, 0xdd, 0xbb, 0xaa, 0x88, 0x77, 0x55, 0x44, 0x22, 0x11}
+	for channel := 0; channel < 4; channel++ {
+		for _, v := range ramp {
+			c := color.RGBA{A: 0xff}
+			switch channel {
+			case 0:
+				c.R = v
+			case 1:
+				c.G = v
+			case 2:
+				c.B = v
+			case 3:
+				c.R, c.G, c.B = v, v, v
+			}
+			p[i] = c
+			i++
+		}
+	}
+	return p
+}
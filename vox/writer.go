@@ -0,0 +1,140 @@
+package vox
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// Save writes f as a .vox file to path
+func Save(path string, f *File) error {
+	fileHandle, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(fileHandle)
+	if err := Encode(w, f); err != nil {
+		fileHandle.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		fileHandle.Close()
+		return err
+	}
+	return fileHandle.Close()
+}
+
+// Encode writes f in the .vox format: the models as SIZE and XYZI chunks, the scene
+// graph if there is one, and the palette as RGBA chunk
+func Encode(w io.Writer, f *File) error {
+	var children bytes.Buffer
+	for i, m := range f.Models {
+		for _, v := range m.Size {
+			if v < 1 || v > MaxModelSize {
+				return fmt.Errorf("vox: model %d size %v out of range", i, m.Size)
+			}
+		}
+		e := new(encoder)
+		e.int32(int32(m.Size[0]), int32(m.Size[1]), int32(m.Size[2]))
+		writeChunk(&children, "SIZE", e.Bytes())
+
+		e = new(encoder)
+		e.int32(int32(len(m.Voxels)))
+		for _, v := range m.Voxels {
+			e.Write([]byte{v.X, v.Y, v.Z, v.ColorIndex})
+		}
+		writeChunk(&children, "XYZI", e.Bytes())
+	}
+
+	ids := make([]int, 0, len(f.Nodes))
+	for id := range f.Nodes {
+		ids = append(ids, int(id))
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		n := f.Nodes[int32(id)]
+		e := new(encoder)
+		e.int32(n.ID)
+		e.dict(n.Attributes)
+		switch n.Kind {
+		case TransformNode:
+			e.int32(n.Child, -1, n.Layer, int32(len(n.Frames)))
+			for _, frame := range n.Frames {
+				e.dict(frame)
+			}
+			writeChunk(&children, "nTRN", e.Bytes())
+		case GroupNode:
+			e.int32(int32(len(n.Children)))
+			e.int32(n.Children...)
+			writeChunk(&children, "nGRP", e.Bytes())
+		case ShapeNode:
+			e.int32(int32(len(n.Models)))
+			for _, m := range n.Models {
+				e.int32(m)
+				e.dict(nil)
+			}
+			writeChunk(&children, "nSHP", e.Bytes())
+		}
+	}
+
+	e := new(encoder)
+	for i := 1; i <= 256; i++ {
+		// entry i-1 holds color index i, the last entry is unused
+		if i < 256 {
+			c := f.Palette[i]
+			e.Write([]byte{c.R, c.G, c.B, c.A})
+		} else {
+			e.Write([]byte{0, 0, 0, 0})
+		}
+	}
+	writeChunk(&children, "RGBA", e.Bytes())
+
+	var out bytes.Buffer
+	out.WriteString(magic)
+	binary.Write(&out, binary.LittleEndian, int32(Version))
+	out.WriteString("MAIN")
+	binary.Write(&out, binary.LittleEndian, int32(0))
+	binary.Write(&out, binary.LittleEndian, int32(children.Len()))
+	out.Write(children.Bytes())
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+func writeChunk(w *bytes.Buffer, id string, content []byte) {
+	w.WriteString(id)
+	binary.Write(w, binary.LittleEndian, int32(len(content)))
+	binary.Write(w, binary.LittleEndian, int32(0))
+	w.Write(content)
+}
+
+type encoder struct {
+	bytes.Buffer
+}
+
+func (e *encoder) int32(values ...int32) {
+	for _, v := range values {
+		binary.Write(e, binary.LittleEndian, v)
+	}
+}
+
+func (e *encoder) string(s string) {
+	e.int32(int32(len(s)))
+	e.WriteString(s)
+}
+
+func (e *encoder) dict(d map[string]string) {
+	keys := make([]string, 0, len(d))
+	for k := range d {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	e.int32(int32(len(keys)))
+	for _, k := range keys {
+		e.string(k)
+		e.string(d[k])
+	}
+}
@@ -0,0 +1,123 @@
+package world
+
+import (
+	"sort"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// Block identifies the type of a single voxel
+type Block uint8
+
+const (
+	Air Block = iota
+	Stone
+	Dirt
+	Grass
+	Sand
+	Gravel
+	Bedrock
+	Water
+	CoalOre
+	IronOre
+	GoldOre
+	DiamondOre
+	Log
+	Leaves
+	Torch
+	Lava
+	NumBlocks
+)
+
+// BlockInfo holds the static properties of a block type
+type BlockInfo struct {
+	Name     string
+	Solid    bool       // collides with entities
+	Opaque   bool       // hides neighbouring faces and blocks light
+	Emission uint8      // block light level the block gives off
+	Filter   uint8      // light levels absorbed on top of the usual falloff
+	Color    mgl32.Vec4 // tints the texture
+
+	Texture       string // texture of every face, the block name if empty
+	TopTexture    string // replaces Texture on the top face
+	BottomTexture string // replaces Texture on the bottom face
+
+	FlowDelay    int   // fluid simulation ticks between updates, 0 for blocks that don't flow
+	FlowDistance uint8 // how far a fluid spreads sideways from a source
+}
+
+var blockInfos = [NumBlocks]BlockInfo{
+	Air:        {Name: "air"},
+	Stone:      {Name: "stone", Solid: true, Opaque: true, Color: mgl32.Vec4{0.5, 0.5, 0.5, 1}},
+	Dirt:       {Name: "dirt", Solid: true, Opaque: true, Color: mgl32.Vec4{0.45, 0.3, 0.2, 1}},
+	Grass:      {Name: "grass", Solid: true, Opaque: true, Color: mgl32.Vec4{0.3, 0.65, 0.2, 1}, Texture: "grass_side", TopTexture: "grass_top", BottomTexture: "dirt"},
+	Sand:       {Name: "sand", Solid: true, Opaque: true, Color: mgl32.Vec4{0.85, 0.8, 0.55, 1}},
+	Gravel:     {Name: "gravel", Solid: true, Opaque: true, Color: mgl32.Vec4{0.55, 0.5, 0.5, 1}},
+	Bedrock:    {Name: "bedrock", Solid: true, Opaque: true, Color: mgl32.Vec4{0.2, 0.2, 0.2, 1}},
+	Water:      {Name: "water", Filter: 2, Color: mgl32.Vec4{0.2, 0.4, 0.85, 0.6}, FlowDelay: 5, FlowDistance: 7},
+	CoalOre:    {Name: "coal_ore", Solid: true, Opaque: true, Color: mgl32.Vec4{0.25, 0.25, 0.25, 1}},
+	IronOre:    {Name: "iron_ore", Solid: true, Opaque: true, Color: mgl32.Vec4{0.7, 0.55, 0.45, 1}},
+	GoldOre:    {Name: "gold_ore", Solid: true, Opaque: true, Color: mgl32.Vec4{0.9, 0.8, 0.2, 1}},
+	DiamondOre: {Name: "diamond_ore", Solid: true, Opaque: true, Color: mgl32.Vec4{0.4, 0.9, 0.9, 1}},
+	Log:        {Name: "log", Solid: true, Opaque: true, Color: mgl32.Vec4{0.4, 0.28, 0.15, 1}, Texture: "log_side", TopTexture: "log_top", BottomTexture: "log_top"},
+	Leaves:     {Name: "leaves", Solid: true, Filter: 1, Color: mgl32.Vec4{0.2, 0.5, 0.15, 1}},
+	Torch:      {Name: "torch", Emission: 14, Color: mgl32.Vec4{1, 0.85, 0.4, 1}},
+	Lava:       {Name: "lava", Emission: 15, Color: mgl32.Vec4{0.95, 0.4, 0.1, 1}, FlowDelay: 30, FlowDistance: 3},
+}
+
+// Info returns the static properties of the block
+func (b Block) Info() BlockInfo {
+	if b >= NumBlocks {
+		return blockInfos[Air]
+	}
+	return blockInfos[b]
+}
+
+// FaceTexture returns the name of the texture on the face pointing along dy, 1
+// for the top, -1 for the bottom and 0 for the sides
+func (info BlockInfo) FaceTexture(dy int) string {
+	switch {
+	case dy > 0 && info.TopTexture != "":
+		return info.TopTexture
+	case dy < 0 && info.BottomTexture != "":
+		return info.BottomTexture
+	case info.Texture != "":
+		return info.Texture
+	}
+	return info.Name
+}
+
+// TextureNames returns the names of all textures used by blocks, sorted
+func TextureNames() []string {
+	seen := make(map[string]bool)
+	var names []string
+	for b := Air + 1; b < NumBlocks; b++ {
+		for dy := -1; dy <= 1; dy++ {
+			if name := b.Info().FaceTexture(dy); !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (b Block) String() string {
+	return b.Info().Name
+}
+
+// IsSolid reports whether entities collide with the block
+func (b Block) IsSolid() bool {
+	return b.Info().Solid
+}
+
+// IsFluid reports whether the block flows, its state holds the fluid level
+func (b Block) IsFluid() bool {
+	return b.Info().FlowDelay > 0
+}
+
+// IsOpaque reports whether the block hides the faces of its neighbours
+func (b Block) IsOpaque() bool {
+	return b.Info().Opaque
+}
@@ -0,0 +1,131 @@
+package world
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/tehcyx/goengine/noise"
+)
+
+// CavePass carves two kinds of caves into the terrain: large "cheese" caverns
+// where 3D noise exceeds a threshold, and "worm" tunnels that wander through
+// several chunks. Worms are computed from the seed of the chunk they start in,
+// so every chunk they cross carves the same tunnel without knowing about the others.
+type CavePass struct {
+	CheeseScale     float64
+	CheeseThreshold float64
+	WormsPerChunk   int
+	WormLength      int
+	WormRadius      float64
+	WormRange       int // how many chunks away a worm may start and still reach this chunk, see WormReach
+	seed            int64
+	cheese          *noise.Perlin
+}
+
+// NewCavePass creates a new cave pass for the given world seed
+func NewCavePass(seed int64) *CavePass {
+	p := new(CavePass)
+	p.CheeseScale = 1.0 / 24
+	p.CheeseThreshold = 0.45
+	p.WormsPerChunk = 1
+	p.WormLength = 80
+	p.WormRadius = 2.5
+	p.WormRange = WormReach(p.WormLength, p.WormRadius)
+	p.seed = seed
+	p.cheese = noise.NewPerlin(seed ^ 0x5ca7e)
+	return p
+}
+
+// WormReach returns how many chunks away from the chunk it starts in a worm of
+// length steps and radius can carve. Worms are widest at 1.25 times the radius.
+func WormReach(length int, radius float64) int {
+	return int(math.Ceil((float64(length) + radius*1.25) / ChunkSize))
+}
+
+func (p *CavePass) Name() string {
+	return "caves"
+}
+
+func (p *CavePass) Apply(ctx *Context) {
+	p.carveCheese(ctx)
+	for dx := -p.WormRange; dx <= p.WormRange; dx++ {
+		for dz := -p.WormRange; dz <= p.WormRange; dz++ {
+			origin := ChunkPos{ctx.Chunk.Pos.X + dx, ctx.Chunk.Pos.Z + dz}
+			r := rand.New(rand.NewSource(chunkSeed(p.seed, "caves/worms", origin)))
+			n := r.Intn(p.WormsPerChunk + 1)
+			for i := 0; i < n; i++ {
+				p.carveWorm(ctx.Chunk, origin, r)
+			}
+		}
+	}
+}
+
+func (p *CavePass) carveCheese(ctx *Context) {
+	ox, oz := ctx.Chunk.Pos.Origin()
+	for x := 0; x < ChunkSize; x++ {
+		for z := 0; z < ChunkSize; z++ {
+			// keep a crust below the surface so caverns don't swallow the landscape
+			top := ctx.Chunk.Height(x, z) - 6
+			for y := 1; y < top; y++ {
+				n := p.cheese.Octave3(float64(ox+x)*p.CheeseScale, float64(y)*p.CheeseScale*2, float64(oz+z)*p.CheeseScale, 2, 0.5)
+				if n > p.CheeseThreshold {
+					carve(ctx.Chunk, x, y, z)
+				}
+			}
+		}
+	}
+}
+
+func (p *CavePass) carveWorm(c *Chunk, origin ChunkPos, r *rand.Rand) {
+	sx, sz := origin.Origin()
+	x := float64(sx) + r.Float64()*ChunkSize
+	y := 8 + r.Float64()*float64(SeaLevel-8)
+	z := float64(sz) + r.Float64()*ChunkSize
+	yaw := r.Float64() * 2 * math.Pi
+	pitch := (r.Float64() - 0.5) * 0.5
+
+	ox, oz := c.Pos.Origin()
+	for i := 0; i < p.WormLength; i++ {
+		radius := p.WormRadius * (0.75 + 0.5*math.Sin(float64(i)*math.Pi/float64(p.WormLength)))
+		carveSphere(c, x-float64(ox), y, z-float64(oz), radius)
+
+		x += math.Cos(yaw) * math.Cos(pitch)
+		y += math.Sin(pitch)
+		z += math.Sin(yaw) * math.Cos(pitch)
+		yaw += (r.Float64() - 0.5) * 0.4
+		pitch = pitch*0.9 + (r.Float64()-0.5)*0.2
+	}
+}
+
+// carveSphere carves a sphere given in local chunk coordinates
+func carveSphere(c *Chunk, cx, cy, cz, radius float64) {
+	if cx+radius < 0 || cx-radius >= ChunkSize || cz+radius < 0 || cz-radius >= ChunkSize {
+		return
+	}
+	r2 := radius * radius
+	for x := int(math.Floor(cx - radius)); x <= int(math.Ceil(cx+radius)); x++ {
+		for y := int(math.Floor(cy - radius)); y <= int(math.Ceil(cy+radius)); y++ {
+			for z := int(math.Floor(cz - radius)); z <= int(math.Ceil(cz+radius)); z++ {
+				dx, dy, dz := float64(x)+0.5-cx, float64(y)+0.5-cy, float64(z)+0.5-cz
+				if dx*dx+dy*dy+dz*dz <= r2 {
+					carve(c, x, y, z)
+				}
+			}
+		}
+	}
+}
+
+// carve removes a block unless it is bedrock or would open a cave into water
+func carve(c *Chunk, x, y, z int) {
+	if !InBounds(x, y, z) || y == 0 {
+		return
+	}
+	switch c.Get(x, y, z) {
+	case Air, Water, Bedrock:
+		return
+	}
+	if c.Get(x, y+1, z) == Water {
+		return
+	}
+	c.Set(x, y, z, Air)
+}
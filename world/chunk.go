@@ -0,0 +1,102 @@
+package world
+
+const (
+	ChunkSize   = 16  // width and depth of a chunk column in blocks
+	ChunkHeight = 128 // height of a chunk column in blocks
+	SeaLevel    = 48
+)
+
+// ChunkPos is the position of a chunk column in chunk coordinates
+type ChunkPos struct {
+	X, Z int
+}
+
+// ChunkPosAt returns the position of the chunk containing the world block x, z
+func ChunkPosAt(x, z int) ChunkPos {
+	return ChunkPos{floorDiv(x, ChunkSize), floorDiv(z, ChunkSize)}
+}
+
+// Origin returns the world block coordinates of the chunks minimum corner
+func (p ChunkPos) Origin() (x, z int) {
+	return p.X * ChunkSize, p.Z * ChunkSize
+}
+
+// Chunk is a column of ChunkSize x ChunkHeight x ChunkSize blocks
+type Chunk struct {
+	Pos    ChunkPos
+	blocks [ChunkSize * ChunkHeight * ChunkSize]Block
+	states [ChunkSize * ChunkHeight * ChunkSize]uint8 // per block state, e.g. the level of fluids
+	light  [ChunkSize * ChunkHeight * ChunkSize]uint8 // sky light in the high, block light in the low nibble
+	dirty  bool                                       // changed since it was last saved
+}
+
+// NewChunk creates a new empty chunk at the given position
+func NewChunk(pos ChunkPos) *Chunk {
+	c := new(Chunk)
+	c.Pos = pos
+	return c
+}
+
+// InBounds reports whether the local coordinates lie inside a chunk
+func InBounds(x, y, z int) bool {
+	return x >= 0 && x < ChunkSize && y >= 0 && y < ChunkHeight && z >= 0 && z < ChunkSize
+}
+
+// Get returns the block at local coordinates, Air if out of bounds
+func (c *Chunk) Get(x, y, z int) Block {
+	if !InBounds(x, y, z) {
+		return Air
+	}
+	return c.blocks[index(x, y, z)]
+}
+
+// Set sets the block at local coordinates with state 0, out of bounds writes are ignored
+func (c *Chunk) Set(x, y, z int, b Block) {
+	c.SetWithState(x, y, z, b, 0)
+}
+
+// State returns the state of the block at local coordinates, 0 if out of bounds
+func (c *Chunk) State(x, y, z int) uint8 {
+	if !InBounds(x, y, z) {
+		return 0
+	}
+	return c.states[index(x, y, z)]
+}
+
+// SetWithState sets the block and its state at local coordinates, out of bounds
+// writes are ignored
+func (c *Chunk) SetWithState(x, y, z int, b Block, state uint8) {
+	if !InBounds(x, y, z) {
+		return
+	}
+	i := index(x, y, z)
+	c.blocks[i] = b
+	c.states[i] = state
+	c.dirty = true
+}
+
+// Height returns the local y of the highest non air block in the column, -1 if empty
+func (c *Chunk) Height(x, z int) int {
+	for y := ChunkHeight - 1; y >= 0; y-- {
+		if c.Get(x, y, z) != Air {
+			return y
+		}
+	}
+	return -1
+}
+
+func index(x, y, z int) int {
+	return x + z*ChunkSize + y*ChunkSize*ChunkSize
+}
+
+func floorDiv(a, b int) int {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
+
+func floorMod(a, b int) int {
+	return a - floorDiv(a, b)*b
+}
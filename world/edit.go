@@ -0,0 +1,143 @@
+package world
+
+// BlockChange describes a block changed through World.SetBlock
+type BlockChange struct {
+	Pos      [3]int
+	Old, New Block
+	OldState uint8
+	NewState uint8
+	Chunks   []ChunkPos // chunks that need remeshing, the ones touching the block and those whose light changed
+}
+
+// Subscribe registers fn to be called for every block change. fn runs on the
+// goroutine that changed the block, after the world is unlocked. The returned
+// function removes the subscription.
+func (w *World) Subscribe(fn func(BlockChange)) (unsubscribe func()) {
+	w.listenerMu.Lock()
+	defer w.listenerMu.Unlock()
+	if w.listeners == nil {
+		w.listeners = make(map[int]func(BlockChange))
+	}
+	id := w.nextID
+	w.nextID++
+	w.listeners[id] = fn
+	return func() {
+		w.listenerMu.Lock()
+		delete(w.listeners, id)
+		w.listenerMu.Unlock()
+	}
+}
+
+func (w *World) notify(change BlockChange) {
+	w.listenerMu.Lock()
+	listeners := make([]func(BlockChange), 0, len(w.listeners))
+	for _, fn := range w.listeners {
+		listeners = append(listeners, fn)
+	}
+	w.listenerMu.Unlock()
+	for _, fn := range listeners {
+		fn(change)
+	}
+}
+
+// History records block edits so level editors can undo and redo them. Edits
+// made between BeginGroup and EndGroup are undone and redone as one step.
+type History struct {
+	World *World
+	Limit int // number of steps kept, 0 keeps all
+
+	undo  [][]BlockChange
+	redo  [][]BlockChange
+	group []BlockChange
+	depth int
+}
+
+// NewHistory creates a new edit history for the world keeping up to limit steps
+func NewHistory(w *World, limit int) *History {
+	h := new(History)
+	h.World = w
+	h.Limit = limit
+	return h
+}
+
+// SetBlock sets a block with state 0 and records the edit, see SetBlockState
+func (h *History) SetBlock(x, y, z int, b Block) bool {
+	return h.SetBlockState(x, y, z, b, 0)
+}
+
+// SetBlockState sets a block and its state through the world and records the
+// edit. Recording a new edit drops the steps that could have been redone.
+func (h *History) SetBlockState(x, y, z int, b Block, state uint8) bool {
+	// the old block comes from the set itself, the fluid simulation may change it any time
+	change, ok := h.World.ReplaceBlock(x, y, z, b, state)
+	if !ok {
+		return false
+	}
+	change.Chunks = nil
+	h.BeginGroup()
+	h.group = append(h.group, change)
+	h.EndGroup()
+	return true
+}
+
+// BeginGroup starts a group of edits, groups can be nested
+func (h *History) BeginGroup() {
+	h.depth++
+}
+
+// EndGroup ends a group of edits and records it as a single step
+func (h *History) EndGroup() {
+	if h.depth == 0 {
+		return
+	}
+	h.depth--
+	if h.depth > 0 || len(h.group) == 0 {
+		return
+	}
+	h.undo = append(h.undo, h.group)
+	h.group = nil
+	h.redo = nil
+	if h.Limit > 0 && len(h.undo) > h.Limit {
+		h.undo = h.undo[len(h.undo)-h.Limit:]
+	}
+}
+
+// Undo reverts the last step and reports whether there was one. Blocks in
+// chunks that were unloaded in the meantime are skipped.
+func (h *History) Undo() bool {
+	if len(h.undo) == 0 {
+		return false
+	}
+	step := h.undo[len(h.undo)-1]
+	h.undo = h.undo[:len(h.undo)-1]
+	for i := len(step) - 1; i >= 0; i-- {
+		c := step[i]
+		h.World.SetBlockState(c.Pos[0], c.Pos[1], c.Pos[2], c.Old, c.OldState)
+	}
+	h.redo = append(h.redo, step)
+	return true
+}
+
+// Redo applies the last undone step again and reports whether there was one
+func (h *History) Redo() bool {
+	if len(h.redo) == 0 {
+		return false
+	}
+	step := h.redo[len(h.redo)-1]
+	h.redo = h.redo[:len(h.redo)-1]
+	for _, c := range step {
+		h.World.SetBlockState(c.Pos[0], c.Pos[1], c.Pos[2], c.New, c.NewState)
+	}
+	h.undo = append(h.undo, step)
+	return true
+}
+
+// CanUndo reports whether there is a step to undo
+func (h *History) CanUndo() bool {
+	return len(h.undo) > 0
+}
+
+// CanRedo reports whether there is a step to redo
+func (h *History) CanRedo() bool {
+	return len(h.redo) > 0
+}
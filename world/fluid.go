@@ -0,0 +1,227 @@
+package world
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Fluid levels are stored in the block state. Sources have level 0, flowing
+// fluid counts up with the distance to the source it came from.
+const (
+	FluidSource  uint8 = 0
+	FluidFalling uint8 = 8 // flowing straight down, spreads like a source but dries up without one
+)
+
+// FluidLevel returns the distance of a fluid block from its source, falling fluid
+// counts as 0
+func FluidLevel(state uint8) uint8 {
+	if state == FluidFalling {
+		return 0
+	}
+	return state
+}
+
+// FluidHeight returns the height of the fluid surface inside a block from 0 to 1
+func FluidHeight(state uint8) float32 {
+	return float32(8-FluidLevel(state)) / 9
+}
+
+var horizontalDirs = [4][3]int{{1, 0, 0}, {-1, 0, 0}, {0, 0, 1}, {0, 0, -1}}
+
+// FluidSim is a cellular fluid simulation running in fixed ticks, independent of
+// the frame rate. Fluid blocks are only updated when something changed next to
+// them, each fluid after its own FlowDelay. Lava touching water turns into stone.
+type FluidSim struct {
+	World        *World
+	TickInterval time.Duration // simulated time per tick
+	MaxTicks     int           // ticks per Advance before the simulation gives up catching up
+	MaxUpdates   int           // block updates per tick, the rest moves to the next tick
+
+	mu          sync.Mutex
+	tick        uint64
+	due         map[[3]int]uint64   // tick each scheduled block is updated at
+	buckets     map[uint64][][3]int // scheduled blocks by tick
+	accumulator time.Duration
+	unsubscribe func()
+}
+
+// NewFluidSim creates a new fluid simulation for the world, listening to its block changes
+func NewFluidSim(w *World) *FluidSim {
+	s := new(FluidSim)
+	s.World = w
+	s.TickInterval = 50 * time.Millisecond
+	s.MaxTicks = 5
+	s.MaxUpdates = 4096
+	s.due = make(map[[3]int]uint64)
+	s.buckets = make(map[uint64][][3]int)
+	s.unsubscribe = w.Subscribe(s.blockChanged)
+	return s
+}
+
+// Advance adds dt to the simulated time and runs the ticks that are due. It
+// returns the number of ticks run. Time beyond MaxTicks is dropped so a slow
+// frame doesn't snowball.
+func (s *FluidSim) Advance(dt time.Duration) int {
+	s.accumulator += dt
+	ticks := 0
+	for s.accumulator >= s.TickInterval {
+		if ticks == s.MaxTicks {
+			s.accumulator = 0
+			break
+		}
+		s.Tick()
+		s.accumulator -= s.TickInterval
+		ticks++
+	}
+	return ticks
+}
+
+// Tick runs a single simulation tick
+func (s *FluidSim) Tick() {
+	s.mu.Lock()
+	s.tick++
+	var blocks [][3]int
+	for _, p := range s.buckets[s.tick] {
+		// blocks rescheduled to an earlier tick were handled already
+		if s.due[p] == s.tick {
+			blocks = append(blocks, p)
+			delete(s.due, p)
+		}
+	}
+	delete(s.buckets, s.tick)
+	// update bottom up in a fixed order so the simulation is deterministic
+	sort.Slice(blocks, func(i, j int) bool {
+		a, b := blocks[i], blocks[j]
+		if a[1] != b[1] {
+			return a[1] < b[1]
+		}
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		return a[2] < b[2]
+	})
+	if len(blocks) > s.MaxUpdates {
+		for _, p := range blocks[s.MaxUpdates:] {
+			s.schedule(p, 1)
+		}
+		blocks = blocks[:s.MaxUpdates]
+	}
+	s.mu.Unlock()
+
+	for _, p := range blocks {
+		s.update(p[0], p[1], p[2])
+	}
+}
+
+// Schedule updates the fluid at world coordinates after its FlowDelay, e.g. after
+// loading a chunk with fluids that were still flowing when it was saved
+func (s *FluidSim) Schedule(x, y, z int) {
+	if delay := s.World.Block(x, y, z).Info().FlowDelay; delay > 0 {
+		s.mu.Lock()
+		s.schedule([3]int{x, y, z}, delay)
+		s.mu.Unlock()
+	}
+}
+
+// Pending returns the number of scheduled block updates
+func (s *FluidSim) Pending() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.due)
+}
+
+// Close stops listening to block changes of the world
+func (s *FluidSim) Close() {
+	s.unsubscribe()
+}
+
+func (s *FluidSim) schedule(p [3]int, delay int) {
+	at := s.tick + uint64(delay)
+	if due, ok := s.due[p]; ok && due <= at {
+		return
+	}
+	s.due[p] = at
+	s.buckets[at] = append(s.buckets[at], p)
+}
+
+// blockChanged schedules the fluids at and next to a changed block
+func (s *FluidSim) blockChanged(change BlockChange) {
+	x, y, z := change.Pos[0], change.Pos[1], change.Pos[2]
+	s.Schedule(x, y, z)
+	for _, d := range lightDirs {
+		s.Schedule(x+d[0], y+d[1], z+d[2])
+	}
+}
+
+func (s *FluidSim) update(x, y, z int) {
+	w := s.World
+	b, state := w.Block(x, y, z), w.State(x, y, z)
+	if !b.IsFluid() {
+		return
+	}
+	info := b.Info()
+
+	if b == Lava {
+		for _, d := range lightDirs {
+			if w.Block(x+d[0], y+d[1], z+d[2]) == Water {
+				w.SetBlock(x, y, z, Stone)
+				return
+			}
+		}
+	}
+
+	if state != FluidSource {
+		// flowing fluid is fed from above or by a neighbour closer to a source
+		want, fed := FluidFalling, true
+		if w.Block(x, y+1, z) != b {
+			fed = false
+			for _, d := range horizontalDirs {
+				nx, nz := x+d[0], z+d[2]
+				if w.Block(nx, y, nz) != b {
+					continue
+				}
+				if level := FluidLevel(w.State(nx, y, nz)) + 1; level <= info.FlowDistance && (!fed || level < want) {
+					want, fed = level, true
+				}
+			}
+		}
+		if !fed {
+			w.SetBlock(x, y, z, Air)
+			return
+		}
+		if want != state {
+			// the change schedules this block again to spread with the new level
+			w.SetBlockState(x, y, z, b, want)
+			return
+		}
+	}
+
+	// fall down first, fluid only spreads sideways on top of something else
+	below, belowState := w.Block(x, y-1, z), w.State(x, y-1, z)
+	if y > 0 && canFlowInto(b, below, belowState, 0) {
+		w.SetBlockState(x, y-1, z, b, FluidFalling)
+		return
+	}
+	if y > 0 && (below == Air || below == b) {
+		return
+	}
+	next := FluidLevel(state) + 1
+	if next > info.FlowDistance {
+		return
+	}
+	for _, d := range horizontalDirs {
+		nx, nz := x+d[0], z+d[2]
+		if canFlowInto(b, w.Block(nx, y, nz), w.State(nx, y, nz), next) {
+			w.SetBlockState(nx, y, nz, b, next)
+		}
+	}
+}
+
+// canFlowInto reports whether fluid with the given level may replace a block
+func canFlowInto(fluid, target Block, state, level uint8) bool {
+	if target == Air {
+		return true
+	}
+	return target == fluid && state != FluidSource && state != FluidFalling && state > level
+}
@@ -0,0 +1,232 @@
+package world
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"sync"
+)
+
+// Pass is a single step of the chunk generation pipeline. Passes are shared
+// between generator goroutines and must not keep per chunk state.
+type Pass interface {
+	Name() string
+	Apply(ctx *Context)
+}
+
+// PendingWrite is a block a structure placed outside of the chunk it was generated in
+type PendingWrite struct {
+	X, Y, Z int // world coordinates
+	Block   Block
+	Source  ChunkPos // the chunk the structure was generated in
+}
+
+// Context is handed to every pass while generating a single chunk
+type Context struct {
+	Chunk *Chunk
+	Rand  *rand.Rand // seeded from the world seed, the pass and the chunk position
+	Seed  int64
+	gen   *Generator
+}
+
+// Get returns the block at world coordinates, Air if outside of the chunk being generated
+func (ctx *Context) Get(x, y, z int) Block {
+	ox, oz := ctx.Chunk.Pos.Origin()
+	return ctx.Chunk.Get(x-ox, y, z-oz)
+}
+
+// Place writes a structure block at world coordinates. Blocks that fall outside
+// of the chunk are queued and applied once the neighbouring chunk exists.
+func (ctx *Context) Place(x, y, z int, b Block) {
+	if y < 0 || y >= ChunkHeight {
+		return
+	}
+	pos := ChunkPosAt(x, z)
+	if pos != ctx.Chunk.Pos {
+		ctx.gen.queue(pos, PendingWrite{x, y, z, b, ctx.Chunk.Pos})
+		return
+	}
+	placeBlock(ctx.Chunk, x, y, z, b)
+}
+
+// Generator runs the generation passes for chunks. Every pass is seeded per
+// chunk so chunks can be generated in any order and on any goroutine.
+type Generator struct {
+	Seed   int64
+	Passes []Pass
+
+	mu      sync.Mutex
+	pending map[ChunkPos][]PendingWrite
+	// writes already handed to a chunk, kept to generate it again after it
+	// was unloaded while the chunk that placed them wasn't generated again
+	placed map[ChunkPos][]PendingWrite
+}
+
+// NewGenerator creates a generator with the default terrain, cave, ore and structure passes
+func NewGenerator(seed int64) *Generator {
+	g := new(Generator)
+	g.Seed = seed
+	g.Passes = []Pass{
+		NewTerrainPass(seed),
+		NewCavePass(seed),
+		NewOrePass(DefaultOres),
+		NewStructurePass(DefaultStructures),
+	}
+	g.pending = make(map[ChunkPos][]PendingWrite)
+	g.placed = make(map[ChunkPos][]PendingWrite)
+	return g
+}
+
+// Generate creates the chunk at pos by running all passes in order and applying
+// writes other chunks already queued for it, or handed to it before it was unloaded
+func (g *Generator) Generate(pos ChunkPos) *Chunk {
+	c := NewChunk(pos)
+	for _, p := range g.Passes {
+		ctx := &Context{
+			Chunk: c,
+			Rand:  rand.New(rand.NewSource(chunkSeed(g.Seed, p.Name(), pos))),
+			Seed:  g.Seed,
+			gen:   g,
+		}
+		p.Apply(ctx)
+	}
+	g.mu.Lock()
+	ApplyWrites(c, g.placed[pos])
+	g.mu.Unlock()
+	// generated blocks can be generated again, only the queued writes need saving
+	c.dirty = false
+	ApplyWrites(c, g.TakePending(pos))
+	return c
+}
+
+// TakePending removes and returns the writes queued for the chunk at pos. Owners of
+// already generated chunks call this to pick up structures from newer neighbours.
+func (g *Generator) TakePending(pos ChunkPos) []PendingWrite {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	writes := g.pending[pos]
+	delete(g.pending, pos)
+	if len(writes) == 0 {
+		return writes
+	}
+
+	// a chunk generated again replaces the writes it handed out before
+	sources := make(map[ChunkPos]bool)
+	for _, w := range writes {
+		sources[w.Source] = true
+	}
+	var kept []PendingWrite
+	for _, w := range g.placed[pos] {
+		if !sources[w.Source] {
+			kept = append(kept, w)
+		}
+	}
+	g.placed[pos] = append(kept, writes...)
+	return writes
+}
+
+// Release forgets the writes handed out between chunks that are both unloaded.
+// The chunk that placed them queues them again when it is generated again.
+func (g *Generator) Release(loaded func(pos ChunkPos) bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for pos, writes := range g.placed {
+		if loaded(pos) {
+			continue
+		}
+		var kept []PendingWrite
+		for _, w := range writes {
+			if loaded(w.Source) {
+				kept = append(kept, w)
+			}
+		}
+		if len(kept) == 0 {
+			delete(g.placed, pos)
+		} else {
+			g.placed[pos] = kept
+		}
+	}
+}
+
+// Pending returns a copy of all queued writes, keyed by the chunk they are waiting for
+func (g *Generator) Pending() map[ChunkPos][]PendingWrite {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	pending := make(map[ChunkPos][]PendingWrite, len(g.pending))
+	for pos, writes := range g.pending {
+		pending[pos] = append([]PendingWrite(nil), writes...)
+	}
+	return pending
+}
+
+// QueuePending adds writes to the queue, e.g. when restoring a saved world
+func (g *Generator) QueuePending(pending map[ChunkPos][]PendingWrite) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for pos, writes := range pending {
+		g.pending[pos] = append(g.pending[pos], writes...)
+	}
+}
+
+func (g *Generator) queue(pos ChunkPos, w PendingWrite) {
+	g.mu.Lock()
+	g.pending[pos] = append(g.pending[pos], w)
+	g.mu.Unlock()
+}
+
+// ApplyWrites places pending writes into the chunk and reports whether any block changed
+func ApplyWrites(c *Chunk, writes []PendingWrite) bool {
+	changed := false
+	for _, w := range writes {
+		if placeBlock(c, w.X, w.Y, w.Z, w.Block) {
+			changed = true
+		}
+	}
+	return changed
+}
+
+// placeBlock overwrites air and structure blocks of a lower priority. The
+// winner of overlapping structures doesn't depend on which chunk was generated
+// first this way.
+func placeBlock(c *Chunk, x, y, z int, b Block) bool {
+	ox, oz := c.Pos.Origin()
+	lx, lz := x-ox, z-oz
+	if !InBounds(lx, y, lz) {
+		return false
+	}
+	if structurePriority(b) <= structurePriority(c.Get(lx, y, lz)) {
+		return false
+	}
+	c.Set(lx, y, lz, b)
+	return true
+}
+
+// structurePriority orders the blocks structures place where they overlap,
+// e.g. a boulder wins over the leaves of a tree. Every other block ranks
+// highest, so terrain is never replaced.
+func structurePriority(b Block) int {
+	switch b {
+	case Air:
+		return 0
+	case Leaves:
+		return 1
+	case Log:
+		return 2
+	}
+	return 3
+}
+
+// chunkSeed derives a seed for one pass of one chunk
+func chunkSeed(seed int64, pass string, pos ChunkPos) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(pass))
+	x := uint64(seed) ^ h.Sum64()
+	x ^= uint64(int64(pos.X)) * 0x9E3779B97F4A7C15
+	x ^= uint64(int64(pos.Z)) * 0xC2B2AE3D27D4EB4F
+	// splitmix64 finalizer
+	x ^= x >> 30
+	x *= 0xBF58476D1CE4E5B9
+	x ^= x >> 27
+	x *= 0x94D049BB133111EB
+	x ^= x >> 31
+	return int64(x)
+}
@@ -0,0 +1,124 @@
+package world
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// denseStructures crowds trees and boulders big enough to reach into their
+// canopies, so leaves and stone from different chunks land on the same blocks
+var denseStructures = []StructureSpawn{
+	{Structure: OakTree{MinHeight: 4, MaxHeight: 6}, On: Grass, Attempts: 12, Chance: 1},
+	{Structure: Boulder{Radius: 5}, On: Grass, Attempts: 4, Chance: 1},
+}
+
+// generateArea generates the chunks in order, handing the writes queued for
+// already generated chunks to them after every chunk like the Manager does
+func generateArea(seed int64, spawns []StructureSpawn, order []ChunkPos) map[ChunkPos]*Chunk {
+	g := NewGenerator(seed)
+	g.Passes[len(g.Passes)-1] = NewStructurePass(spawns)
+	chunks := make(map[ChunkPos]*Chunk)
+	for _, pos := range order {
+		chunks[pos] = g.Generate(pos)
+		for p, c := range chunks {
+			ApplyWrites(c, g.TakePending(p))
+		}
+	}
+	return chunks
+}
+
+func TestGenerateOrderIndependent(t *testing.T) {
+	var order []ChunkPos
+	for x := -2; x <= 2; x++ {
+		for z := -2; z <= 2; z++ {
+			order = append(order, ChunkPos{x, z})
+		}
+	}
+	shuffled := append([]ChunkPos(nil), order...)
+	rand.New(rand.NewSource(7)).Shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	})
+
+	for _, spawns := range [][]StructureSpawn{DefaultStructures, denseStructures} {
+		a, b := generateArea(1337, spawns, order), generateArea(1337, spawns, shuffled)
+		for _, pos := range order {
+			ca, cb := a[pos], b[pos]
+			for x := 0; x < ChunkSize; x++ {
+				for y := 0; y < ChunkHeight; y++ {
+					for z := 0; z < ChunkSize; z++ {
+						if ba, bb := ca.Get(x, y, z), cb.Get(x, y, z); ba != bb {
+							t.Fatalf("chunk %v block %v,%v,%v is %v in one order and %v in the other", pos, x, y, z, ba, bb)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestRegenerateKeepsNeighbourStructures(t *testing.T) {
+	w := NewWorld(1337)
+	w.Generator.Passes[len(w.Generator.Passes)-1] = NewStructurePass(denseStructures)
+	var area []ChunkPos
+	for x := -1; x <= 1; x++ {
+		for z := -1; z <= 1; z++ {
+			area = append(area, ChunkPos{x, z})
+			w.GenerateChunk(ChunkPos{x, z})
+		}
+	}
+	center := ChunkPos{}
+	if len(w.Generator.placed[center]) == 0 {
+		t.Fatal("no structure of a neighbour reaches into the chunk")
+	}
+	want := w.chunks[center].blocks
+
+	// unloaded alone, the neighbours still loaded don't place their structures again
+	w.RemoveChunk(center)
+	c, _ := w.GenerateChunk(center)
+	if c.blocks != want {
+		t.Error("the chunk generated again while its neighbours stayed loaded differs")
+	}
+
+	// unloaded with all neighbours, they place their structures again
+	for _, pos := range area {
+		w.RemoveChunk(pos)
+	}
+	if len(w.Generator.placed) != 0 {
+		t.Errorf("writes for %v chunks are kept after unloading everything", len(w.Generator.placed))
+	}
+	for i := len(area) - 1; i >= 0; i-- {
+		w.GenerateChunk(area[i])
+	}
+	if w.chunks[center].blocks != want {
+		t.Error("the chunk generated again with its neighbours differs")
+	}
+}
+
+func TestPlaceBlockPriority(t *testing.T) {
+	orders := [][]Block{{Leaves, Log, Stone}, {Stone, Log, Leaves}, {Log, Leaves, Stone}}
+	for _, blocks := range orders {
+		c := NewChunk(ChunkPos{})
+		for _, b := range blocks {
+			placeBlock(c, 1, 10, 1, b)
+		}
+		if got := c.Get(1, 10, 1); got != Stone {
+			t.Errorf("placing %v left %v, want stone", blocks, got)
+		}
+	}
+
+	c := NewChunk(ChunkPos{})
+	c.Set(1, 10, 1, Dirt)
+	if placeBlock(c, 1, 10, 1, Stone) {
+		t.Error("a structure replaced terrain")
+	}
+}
+
+func TestWormReach(t *testing.T) {
+	// 80 steps and a radius of up to 3.125 span 6 chunks
+	if got := WormReach(80, 2.5); got != 6 {
+		t.Errorf("WormReach(80, 2.5) = %v, want 6", got)
+	}
+	if p := NewCavePass(1); p.WormRange != WormReach(p.WormLength, p.WormRadius) {
+		t.Errorf("WormRange is %v, want it derived from the worm length and radius", p.WormRange)
+	}
+}
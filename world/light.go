@@ -0,0 +1,300 @@
+package world
+
+// MaxLight is the highest sky and block light level
+const MaxLight = 15
+
+// LightChannel selects sky or block light
+type LightChannel int
+
+const (
+	SkyLight   LightChannel = iota // sunlight, falls straight down without losing strength
+	BlockLight                     // light given off by blocks like torches and lava
+)
+
+var lightDirs = [6][3]int{{1, 0, 0}, {-1, 0, 0}, {0, 1, 0}, {0, -1, 0}, {0, 0, 1}, {0, 0, -1}}
+
+const lightDown = 3 // index of the downwards direction in lightDirs
+
+// Light returns the light level at local coordinates. Above the chunk there is
+// full sky light, anywhere else out of bounds it is dark.
+func (c *Chunk) Light(ch LightChannel, x, y, z int) uint8 {
+	if y >= ChunkHeight && ch == SkyLight {
+		return MaxLight
+	}
+	if !InBounds(x, y, z) {
+		return 0
+	}
+	if ch == SkyLight {
+		return c.light[index(x, y, z)] >> 4
+	}
+	return c.light[index(x, y, z)] & 0xf
+}
+
+func (c *Chunk) setLight(ch LightChannel, x, y, z int, level uint8) {
+	i := index(x, y, z)
+	if ch == SkyLight {
+		c.light[i] = c.light[i]&0xf | level<<4
+	} else {
+		c.light[i] = c.light[i]&0xf0 | level
+	}
+}
+
+// Light returns the light level at world coordinates, 0 if the chunk isn't loaded
+func (w *World) Light(ch LightChannel, x, y, z int) uint8 {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.light(ch, x, y, z)
+}
+
+func (w *World) light(ch LightChannel, x, y, z int) uint8 {
+	c := w.chunks[ChunkPosAt(x, z)]
+	if c == nil {
+		return 0
+	}
+	return c.Light(ch, floorMod(x, ChunkSize), y, floorMod(z, ChunkSize))
+}
+
+// spreadLight returns the level light of the given level reaches in a neighbouring
+// block, moving in direction dir
+func spreadLight(ch LightChannel, level uint8, dir int, b Block) uint8 {
+	info := b.Info()
+	if info.Opaque {
+		return 0
+	}
+	next := int(level) - 1 - int(info.Filter)
+	if ch == SkyLight && level == MaxLight && dir == lightDown {
+		next = MaxLight - int(info.Filter)
+	}
+	if next < 0 {
+		return 0
+	}
+	return uint8(next)
+}
+
+type lightNode struct {
+	x, y, z int
+	level   uint8
+}
+
+// lightUpdate runs flood fills over the loaded chunks, the world has to be locked
+// for writing. It collects the chunks that need remeshing because light changed.
+type lightUpdate struct {
+	w       *World
+	changed map[ChunkPos]bool
+}
+
+func newLightUpdate(w *World) *lightUpdate {
+	u := new(lightUpdate)
+	u.w = w
+	u.changed = make(map[ChunkPos]bool)
+	return u
+}
+
+// chunk returns the loaded chunk and local coordinates of a world block, nil if
+// the block isn't stored in a loaded chunk
+func (u *lightUpdate) chunk(x, y, z int) (*Chunk, int, int) {
+	if y < 0 || y >= ChunkHeight {
+		return nil, 0, 0
+	}
+	c := u.w.chunks[ChunkPosAt(x, z)]
+	return c, floorMod(x, ChunkSize), floorMod(z, ChunkSize)
+}
+
+func (u *lightUpdate) set(ch LightChannel, c *Chunk, lx, y, lz int, level uint8) {
+	c.setLight(ch, lx, y, lz, level)
+	// the faces of neighbours across the border sample this block too
+	u.changed[c.Pos] = true
+	if lx == 0 {
+		u.changed[ChunkPos{c.Pos.X - 1, c.Pos.Z}] = true
+	} else if lx == ChunkSize-1 {
+		u.changed[ChunkPos{c.Pos.X + 1, c.Pos.Z}] = true
+	}
+	if lz == 0 {
+		u.changed[ChunkPos{c.Pos.X, c.Pos.Z - 1}] = true
+	} else if lz == ChunkSize-1 {
+		u.changed[ChunkPos{c.Pos.X, c.Pos.Z + 1}] = true
+	}
+}
+
+// propagate floods light outwards from the queued blocks, raising the level of
+// every block it reaches with more light than it has
+func (u *lightUpdate) propagate(ch LightChannel, queue []lightNode) {
+	for head := 0; head < len(queue); head++ {
+		n := queue[head]
+		c, lx, lz := u.chunk(n.x, n.y, n.z)
+		if c == nil {
+			continue
+		}
+		level := c.Light(ch, lx, n.y, lz)
+		if level == 0 {
+			continue
+		}
+		for i, d := range lightDirs {
+			x, y, z := n.x+d[0], n.y+d[1], n.z+d[2]
+			nc, nx, nz := u.chunk(x, y, z)
+			if nc == nil {
+				continue
+			}
+			if next := spreadLight(ch, level, i, nc.Get(nx, y, nz)); next > nc.Light(ch, nx, y, nz) {
+				u.set(ch, nc, nx, y, nz, next)
+				queue = append(queue, lightNode{x, y, z, next})
+			}
+		}
+	}
+}
+
+// remove darkens the block at x, y, z and every block that got its light from it.
+// It returns the blocks bordering the darkened area, which have to be propagated
+// again to fill it with the light that is left.
+func (u *lightUpdate) remove(ch LightChannel, x, y, z int) []lightNode {
+	c, lx, lz := u.chunk(x, y, z)
+	if c == nil {
+		return nil
+	}
+	queue := []lightNode{{x, y, z, c.Light(ch, lx, y, lz)}}
+	u.set(ch, c, lx, y, lz, 0)
+	var refill []lightNode
+	for head := 0; head < len(queue); head++ {
+		n := queue[head]
+		for i, d := range lightDirs {
+			nx, ny, nz := n.x+d[0], n.y+d[1], n.z+d[2]
+			nc, lnx, lnz := u.chunk(nx, ny, nz)
+			if nc == nil {
+				continue
+			}
+			level := nc.Light(ch, lnx, ny, lnz)
+			if level == 0 {
+				continue
+			}
+			// full sky light below full sky light came from above
+			fromAbove := ch == SkyLight && i == lightDown && n.level == MaxLight && level == MaxLight
+			if level < n.level || fromAbove {
+				u.set(ch, nc, lnx, ny, lnz, 0)
+				queue = append(queue, lightNode{nx, ny, nz, level})
+				// light sources keep shining
+				if e := lightSource(ch, nc.Get(lnx, ny, lnz), ny); e > 0 {
+					u.set(ch, nc, lnx, ny, lnz, e)
+					refill = append(refill, lightNode{nx, ny, nz, e})
+				}
+			} else {
+				refill = append(refill, lightNode{nx, ny, nz, level})
+			}
+		}
+	}
+	return refill
+}
+
+// lightSource returns the light a block at height y gives off on its own
+func lightSource(ch LightChannel, b Block, y int) uint8 {
+	if ch == BlockLight {
+		return b.Info().Emission
+	}
+	if y == ChunkHeight-1 {
+		return spreadLight(SkyLight, MaxLight, lightDown, b)
+	}
+	return 0
+}
+
+// relight updates the light around a block that changed and returns the chunks
+// whose light changed. The world has to be locked for writing.
+func (w *World) relight(x, y, z int) map[ChunkPos]bool {
+	u := newLightUpdate(w)
+	c, lx, lz := u.chunk(x, y, z)
+	if c == nil {
+		return u.changed
+	}
+	b := c.Get(lx, y, lz)
+	for _, ch := range []LightChannel{SkyLight, BlockLight} {
+		queue := u.remove(ch, x, y, z)
+		if e := lightSource(ch, b, y); e > 0 {
+			u.set(ch, c, lx, y, lz, e)
+			queue = append(queue, lightNode{x, y, z, e})
+		}
+		// the neighbours shine into the block again if it lets light through
+		for _, d := range lightDirs {
+			nx, ny, nz := x+d[0], y+d[1], z+d[2]
+			if level := w.light(ch, nx, ny, nz); level > 0 {
+				queue = append(queue, lightNode{nx, ny, nz, level})
+			}
+		}
+		u.propagate(ch, queue)
+	}
+	return u.changed
+}
+
+// lightChunk computes the light of a chunk that was just added to the world, and
+// spreads light between it and its loaded neighbours. It returns the chunks whose
+// light changed. The world has to be locked for writing.
+func (w *World) lightChunk(c *Chunk) map[ChunkPos]bool {
+	u := newLightUpdate(w)
+	c.light = [len(c.light)]uint8{}
+	ox, oz := c.Pos.Origin()
+
+	var sky, blocks []lightNode
+	for x := 0; x < ChunkSize; x++ {
+		for z := 0; z < ChunkSize; z++ {
+			// light only spreads sideways below the top of a neighbouring column
+			spreadBelow := -1
+			for _, d := range lightDirs {
+				if d[1] == 0 {
+					if top := w.lightTop(ox+x+d[0], oz+z+d[2]); top > spreadBelow {
+						spreadBelow = top
+					}
+				}
+			}
+			level := uint8(MaxLight)
+			for y := ChunkHeight - 1; y >= 0; y-- {
+				b := c.Get(x, y, z)
+				level = spreadLight(SkyLight, level, lightDown, b)
+				if level > 0 {
+					c.setLight(SkyLight, x, y, z, level)
+					if y <= spreadBelow {
+						sky = append(sky, lightNode{ox + x, y, oz + z, level})
+					}
+				}
+				if e := b.Info().Emission; e > 0 {
+					c.setLight(BlockLight, x, y, z, e)
+					blocks = append(blocks, lightNode{ox + x, y, oz + z, e})
+				}
+			}
+		}
+	}
+
+	// light from the neighbours shines in across the border
+	for i := 0; i < ChunkSize; i++ {
+		for _, p := range [4][2]int{{-1, i}, {ChunkSize, i}, {i, -1}, {i, ChunkSize}} {
+			x, z := ox+p[0], oz+p[1]
+			if w.chunks[ChunkPosAt(x, z)] == nil {
+				continue
+			}
+			for y := 0; y < ChunkHeight; y++ {
+				if level := w.light(SkyLight, x, y, z); level > 0 {
+					sky = append(sky, lightNode{x, y, z, level})
+				}
+				if level := w.light(BlockLight, x, y, z); level > 0 {
+					blocks = append(blocks, lightNode{x, y, z, level})
+				}
+			}
+		}
+	}
+
+	u.propagate(SkyLight, sky)
+	u.propagate(BlockLight, blocks)
+	return u.changed
+}
+
+// lightTop returns the highest y of a loaded column at world coordinates that
+// dims sky light, -1 if there is none or the chunk isn't loaded
+func (w *World) lightTop(x, z int) int {
+	c := w.chunks[ChunkPosAt(x, z)]
+	if c == nil {
+		return -1
+	}
+	lx, lz := floorMod(x, ChunkSize), floorMod(z, ChunkSize)
+	for y := ChunkHeight - 1; y >= 0; y-- {
+		if info := c.Get(lx, y, lz).Info(); info.Opaque || info.Filter > 0 {
+			return y
+		}
+	}
+	return -1
+}
@@ -0,0 +1,116 @@
+package world
+
+import "testing"
+
+// emptyWorld returns a world of empty chunks from -r to r in both directions,
+// lit by the full sky
+func emptyWorld(r int) *World {
+	w := NewWorld(1)
+	w.Generator.Passes = nil
+	for x := -r; x <= r; x++ {
+		for z := -r; z <= r; z++ {
+			w.GenerateChunk(ChunkPos{x, z})
+		}
+	}
+	return w
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func TestTorchAcrossChunkBorder(t *testing.T) {
+	w := emptyWorld(1)
+	// the last column of chunk -1, the block light spills into chunk 0
+	tx, ty, tz := -1, 40, 5
+	change, ok := w.ReplaceBlock(tx, ty, tz, Torch, 0)
+	if !ok {
+		t.Fatal("torch wasn't placed")
+	}
+	remesh := false
+	for _, pos := range change.Chunks {
+		remesh = remesh || pos == ChunkPos{0, 0}
+	}
+	if !remesh {
+		t.Errorf("the lit neighbour chunk isn't remeshed, got %v", change.Chunks)
+	}
+
+	emission := int(Torch.Info().Emission)
+	for x := tx - 15; x <= tx+15; x++ {
+		for _, dy := range []int{-2, 0, 3} {
+			for _, dz := range []int{0, 1, 4} {
+				want := emission - abs(x-tx) - abs(dy) - dz
+				if want < 0 {
+					want = 0
+				}
+				if got := w.Light(BlockLight, x, ty+dy, tz+dz); got != uint8(want) {
+					t.Errorf("block light at %v,%v,%v is %v, want %v", x, ty+dy, tz+dz, got, want)
+				}
+			}
+		}
+	}
+
+	w.SetBlock(tx, ty, tz, Air)
+	for x := tx - 15; x <= tx+15; x++ {
+		for y := ty - 15; y <= ty+15; y++ {
+			for z := tz - 15; z <= tz+15; z++ {
+				if got := w.Light(BlockLight, x, y, z); got != 0 {
+					t.Fatalf("removed torch left light %v at %v,%v,%v", got, x, y, z)
+				}
+			}
+		}
+	}
+}
+
+func TestSkyLightUnderOverhang(t *testing.T) {
+	w := emptyWorld(1)
+	// a roof of 10 by 10 blocks over the corner where four chunks meet
+	const roof = 50
+	min, max := -5, 5
+	for x := min; x < max; x++ {
+		for z := min; z < max; z++ {
+			w.SetBlock(x, roof, z, Stone)
+		}
+	}
+
+	// light comes in from the sides and loses a level for every block under the roof
+	for x := min - 2; x < max+2; x++ {
+		for z := min - 2; z < max+2; z++ {
+			want := MaxLight
+			if x >= min && x < max && z >= min && z < max {
+				in := x - min + 1
+				for _, d := range []int{max - x, z - min + 1, max - z} {
+					if d < in {
+						in = d
+					}
+				}
+				want -= in
+			}
+			for _, y := range []int{roof - 1, 10} {
+				if got := w.Light(SkyLight, x, y, z); got != uint8(want) {
+					t.Errorf("sky light at %v,%v,%v is %v, want %v", x, y, z, got, want)
+				}
+			}
+			if got := w.Light(SkyLight, x, roof+1, z); got != MaxLight {
+				t.Errorf("sky light on the roof at %v,%v is %v", x, z, got)
+			}
+		}
+	}
+
+	// without the roof the sky shines straight down again
+	for x := min; x < max; x++ {
+		for z := min; z < max; z++ {
+			w.SetBlock(x, roof, z, Air)
+		}
+	}
+	for x := min; x < max; x++ {
+		for z := min; z < max; z++ {
+			if got := w.Light(SkyLight, x, 10, z); got != MaxLight {
+				t.Errorf("sky light at %v,10,%v is %v after removing the roof", x, z, got)
+			}
+		}
+	}
+}
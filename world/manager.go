@@ -0,0 +1,348 @@
+package world
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/tehcyx/goengine/bounds"
+	"github.com/tehcyx/goengine/mesh"
+	"github.com/tehcyx/goengine/texture"
+)
+
+// Metrics reports the state of the chunk manager, refreshed every Update
+type Metrics struct {
+	QueueDepth    int           // chunks waiting for a worker
+	InFlight      int           // chunks being generated or meshed
+	Loaded        int           // chunks with uploaded meshes
+	Uploaded      int           // meshes uploaded during the last Update
+	UploadTime    time.Duration // time spent uploading during the last Update
+	OverBudget    bool          // the last Update stopped uploading because the frame budget ran out
+	ResultBacklog int           // meshes finished by workers but not uploaded yet
+}
+
+type chunkJob struct {
+	pos      ChunkPos
+	generate bool
+}
+
+type chunkResult struct {
+	pos         ChunkPos
+	opaque      *mesh.Data
+	translucent *mesh.Data
+	dirty       []ChunkPos
+}
+
+// chunkMesh holds the uploaded meshes of a loaded chunk, nil if a pass is empty
+type chunkMesh struct {
+	opaque      *mesh.Mesh
+	translucent *mesh.Mesh
+	faces       *mesh.Data // translucent faces, sorted in place
+	sortedFrom  [3]int     // block the translucent faces were last sorted from
+	sorted      bool
+}
+
+func (c *chunkMesh) delete() {
+	if c.opaque != nil {
+		c.opaque.Delete()
+	}
+	if c.translucent != nil {
+		c.translucent.Delete()
+	}
+}
+
+// Manager streams the chunks within ViewRadius around the camera. Generation and
+// meshing run on a pool of worker goroutines, GL uploads happen in Update, which
+// has to be called from the GL thread.
+type Manager struct {
+	World        *World
+	ViewRadius   int
+	UploadBudget time.Duration   // max time spent uploading meshes per Update
+	Textures     *texture.Lookup // where block faces find their textures, set it before the first Update
+
+	jobs    chan chunkJob
+	results chan chunkResult
+	quit    chan struct{}
+	wg      sync.WaitGroup
+
+	center   ChunkPos
+	meshes   map[ChunkPos]*chunkMesh
+	inFlight map[ChunkPos]bool
+	waiting  []chunkJob
+	started  bool
+	metrics  Metrics
+
+	changeMu    sync.Mutex
+	changed     map[ChunkPos]bool
+	unsubscribe func()
+}
+
+// NewManager creates a new chunk manager and starts its workers
+func NewManager(w *World, viewRadius, workers int) *Manager {
+	m := new(Manager)
+	m.World = w
+	m.ViewRadius = viewRadius
+	m.UploadBudget = 4 * time.Millisecond
+	m.jobs = make(chan chunkJob, workers*2)
+	m.results = make(chan chunkResult, 64)
+	m.quit = make(chan struct{})
+	m.meshes = make(map[ChunkPos]*chunkMesh)
+	m.inFlight = make(map[ChunkPos]bool)
+	m.changed = make(map[ChunkPos]bool)
+	m.unsubscribe = w.Subscribe(m.blockChanged)
+	for i := 0; i < workers; i++ {
+		m.wg.Add(1)
+		go m.worker()
+	}
+	return m
+}
+
+func (m *Manager) worker() {
+	defer m.wg.Done()
+	for {
+		select {
+		case job := <-m.jobs:
+			var dirty []ChunkPos
+			if job.generate && m.World.Chunk(job.pos) == nil {
+				_, dirty = m.World.LoadChunk(job.pos)
+			}
+			opaque, translucent := MeshChunk(m.World, job.pos, m.Textures)
+			result := chunkResult{job.pos, opaque, translucent, dirty}
+			select {
+			case m.results <- result:
+			case <-m.quit:
+				return
+			}
+		case <-m.quit:
+			return
+		}
+	}
+}
+
+// Update loads chunks around the camera, unloads far chunks and uploads finished
+// meshes until the frame budget is used up. Must be called on the GL thread.
+func (m *Manager) Update(camera mgl32.Vec3) {
+	center := ChunkPosAt(int(math.Floor(float64(camera.X()))), int(math.Floor(float64(camera.Z()))))
+	if center != m.center || !m.started {
+		m.started = true
+		m.center = center
+		m.unloadFar()
+		m.queueMissing()
+	}
+	m.remeshChanged()
+	m.dispatch()
+
+	start := time.Now()
+	m.metrics.Uploaded = 0
+drain:
+	for time.Since(start) < m.UploadBudget {
+		select {
+		case r := <-m.results:
+			m.upload(r)
+		default:
+			break drain
+		}
+	}
+	m.metrics.OverBudget = len(m.results) > 0
+	m.metrics.UploadTime = time.Since(start)
+	m.metrics.QueueDepth = len(m.waiting) + len(m.jobs)
+	m.metrics.InFlight = len(m.inFlight)
+	m.metrics.Loaded = len(m.meshes)
+	m.metrics.ResultBacklog = len(m.results)
+}
+
+func (m *Manager) upload(r chunkResult) {
+	delete(m.inFlight, r.pos)
+	if !m.inRange(r.pos, m.ViewRadius+1) {
+		m.World.RemoveChunk(r.pos)
+		return
+	}
+	if old := m.meshes[r.pos]; old != nil {
+		old.delete()
+		delete(m.meshes, r.pos)
+	}
+	if r.opaque == nil {
+		// the chunk was unloaded before it was meshed
+		return
+	}
+	cm := new(chunkMesh)
+	if len(r.opaque.Positions) > 0 {
+		cm.opaque = mesh.NewMeshFromData(r.opaque)
+	}
+	if len(r.translucent.Positions) > 0 {
+		cm.faces = r.translucent
+		cm.translucent = mesh.NewMeshFromData(r.translucent)
+	}
+	m.meshes[r.pos] = cm
+	m.metrics.Uploaded++
+	for _, pos := range r.dirty {
+		if m.World.Chunk(pos) != nil {
+			m.Remesh(pos)
+		}
+	}
+}
+
+// Remesh queues the loaded chunk at pos to be meshed again, e.g. after blocks changed
+func (m *Manager) Remesh(pos ChunkPos) {
+	for _, job := range m.waiting {
+		if job.pos == pos && !job.generate {
+			return
+		}
+	}
+	m.waiting = append([]chunkJob{{pos, false}}, m.waiting...)
+}
+
+// blockChanged collects the chunks affected by a block change, it may run on any goroutine
+func (m *Manager) blockChanged(change BlockChange) {
+	m.changeMu.Lock()
+	for _, pos := range change.Chunks {
+		m.changed[pos] = true
+	}
+	m.changeMu.Unlock()
+}
+
+// remeshChanged queues the loaded chunks affected by block changes since the last Update
+func (m *Manager) remeshChanged() {
+	m.changeMu.Lock()
+	changed := m.changed
+	m.changed = make(map[ChunkPos]bool)
+	m.changeMu.Unlock()
+	for pos := range changed {
+		if m.meshes[pos] != nil || m.World.Chunk(pos) != nil {
+			m.Remesh(pos)
+		}
+	}
+}
+
+func (m *Manager) unloadFar() {
+	for pos, cm := range m.meshes {
+		if !m.inRange(pos, m.ViewRadius+1) {
+			cm.delete()
+			delete(m.meshes, pos)
+			m.World.RemoveChunk(pos)
+		}
+	}
+}
+
+func (m *Manager) queueMissing() {
+	// keep pending remeshes, generation jobs are rebuilt around the new center
+	remesh := m.waiting[:0]
+	for _, job := range m.waiting {
+		if !job.generate && m.inRange(job.pos, m.ViewRadius+1) {
+			remesh = append(remesh, job)
+		}
+	}
+	m.waiting = remesh
+	r := m.ViewRadius
+	for dx := -r; dx <= r; dx++ {
+		for dz := -r; dz <= r; dz++ {
+			pos := ChunkPos{m.center.X + dx, m.center.Z + dz}
+			if !m.inRange(pos, r) || m.inFlight[pos] || m.World.Chunk(pos) != nil {
+				continue
+			}
+			m.waiting = append(m.waiting, chunkJob{pos, true})
+		}
+	}
+	// nearest chunks first
+	sort.SliceStable(m.waiting, func(i, j int) bool {
+		return m.distance2(m.waiting[i].pos) < m.distance2(m.waiting[j].pos)
+	})
+}
+
+// dispatch hands waiting jobs to the workers without blocking the GL thread
+func (m *Manager) dispatch() {
+	rest := m.waiting[:0]
+	for i, job := range m.waiting {
+		if m.inFlight[job.pos] {
+			// a remesh of a chunk that is in flight runs once the current job is done
+			if !job.generate {
+				rest = append(rest, job)
+			}
+			continue
+		}
+		select {
+		case m.jobs <- job:
+			m.inFlight[job.pos] = true
+		default:
+			m.waiting = append(rest, m.waiting[i:]...)
+			return
+		}
+	}
+	m.waiting = rest
+}
+
+func (m *Manager) distance2(pos ChunkPos) int {
+	dx, dz := pos.X-m.center.X, pos.Z-m.center.Z
+	return dx*dx + dz*dz
+}
+
+func (m *Manager) inRange(pos ChunkPos, radius int) bool {
+	return m.distance2(pos) <= radius*radius
+}
+
+// Draw draws the opaque faces of the loaded chunks inside the frustum of culler,
+// must be called on the GL thread
+func (m *Manager) Draw(culler *bounds.Culler) {
+	for _, cm := range m.meshes {
+		// chunk meshes are built in world space
+		if cm.opaque != nil && culler.VisibleAABB(cm.opaque.Bounds()) {
+			cm.opaque.Draw()
+		}
+	}
+}
+
+// DrawTranslucent draws the translucent faces of the loaded chunks inside the
+// frustum of culler back to front as seen from eye. Call it after Draw with
+// blending enabled and depth writes disabled. Faces are sorted again whenever
+// the eye moves into another block. Must be called on the GL thread.
+func (m *Manager) DrawTranslucent(eye mgl32.Vec3, culler *bounds.Culler) {
+	type entry struct {
+		cm   *chunkMesh
+		dist float32
+	}
+	var order []entry
+	for pos, cm := range m.meshes {
+		if cm.translucent == nil || !culler.VisibleAABB(cm.translucent.Bounds()) {
+			continue
+		}
+		ox, oz := pos.Origin()
+		dx, dz := float32(ox)+ChunkSize/2-eye.X(), float32(oz)+ChunkSize/2-eye.Z()
+		order = append(order, entry{cm, dx*dx + dz*dz})
+	}
+	sort.Slice(order, func(i, j int) bool { return order[i].dist > order[j].dist })
+
+	block := [3]int{int(math.Floor(float64(eye.X()))), int(math.Floor(float64(eye.Y()))), int(math.Floor(float64(eye.Z())))}
+	for _, e := range order {
+		if !e.cm.sorted || e.cm.sortedFrom != block {
+			SortFaces(e.cm.faces, eye)
+			e.cm.translucent.UpdateIndices()
+			e.cm.sortedFrom, e.cm.sorted = block, true
+		}
+		e.cm.translucent.Draw()
+	}
+}
+
+// Meshes calls fn with the meshes of every loaded chunk, either may be nil
+func (m *Manager) Meshes(fn func(pos ChunkPos, opaque, translucent *mesh.Mesh)) {
+	for pos, cm := range m.meshes {
+		fn(pos, cm.opaque, cm.translucent)
+	}
+}
+
+// Metrics returns the metrics of the last Update
+func (m *Manager) Metrics() Metrics {
+	return m.metrics
+}
+
+// Close stops the workers and frees all chunk meshes, must be called on the GL thread
+func (m *Manager) Close() {
+	m.unsubscribe()
+	close(m.quit)
+	m.wg.Wait()
+	for pos, cm := range m.meshes {
+		cm.delete()
+		delete(m.meshes, pos)
+	}
+}
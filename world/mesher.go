@@ -0,0 +1,132 @@
+package world
+
+import (
+	"sort"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/tehcyx/goengine/mesh"
+	"github.com/tehcyx/goengine/texture"
+)
+
+type face struct {
+	dir     [3]int
+	normal  mgl32.Vec3
+	corners [4]mgl32.Vec3 // counter clockwise seen from outside
+}
+
+var faces = [6]face{
+	{[3]int{1, 0, 0}, mgl32.Vec3{1, 0, 0}, [4]mgl32.Vec3{{1, 0, 0}, {1, 1, 0}, {1, 1, 1}, {1, 0, 1}}},
+	{[3]int{-1, 0, 0}, mgl32.Vec3{-1, 0, 0}, [4]mgl32.Vec3{{0, 0, 1}, {0, 1, 1}, {0, 1, 0}, {0, 0, 0}}},
+	{[3]int{0, 1, 0}, mgl32.Vec3{0, 1, 0}, [4]mgl32.Vec3{{0, 1, 0}, {0, 1, 1}, {1, 1, 1}, {1, 1, 0}}},
+	{[3]int{0, -1, 0}, mgl32.Vec3{0, -1, 0}, [4]mgl32.Vec3{{0, 0, 0}, {1, 0, 0}, {1, 0, 1}, {0, 0, 1}}},
+	{[3]int{0, 0, 1}, mgl32.Vec3{0, 0, 1}, [4]mgl32.Vec3{{1, 0, 1}, {1, 1, 1}, {0, 1, 1}, {0, 0, 1}}},
+	{[3]int{0, 0, -1}, mgl32.Vec3{0, 0, -1}, [4]mgl32.Vec3{{0, 0, 0}, {0, 1, 0}, {1, 1, 0}, {1, 0, 0}}},
+}
+
+var faceUVs = [4]mgl32.Vec2{{0, 0}, {0, 1}, {1, 1}, {1, 0}}
+
+// MeshChunk builds the vertex data of the chunk at pos in world coordinates, split
+// into opaque faces and translucent faces that have to be drawn after them, see
+// SortFaces. Faces hidden by an opaque neighbour, or by the same block type, are
+// skipped. Every face is lit with the sky and block light of the block in front
+// of it, fluid surfaces are lowered to their level. Texture coordinates and
+// layers come from textures, see BlockInfo.FaceTexture, faces whose texture
+// isn't in it or a nil textures get the whole texture. Returns nil if the chunk
+// isn't loaded.
+func MeshChunk(w *World, pos ChunkPos, textures *texture.Lookup) (opaque, translucent *mesh.Data) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	c := w.chunks[pos]
+	if c == nil {
+		return nil, nil
+	}
+
+	opaque, translucent = new(mesh.Data), new(mesh.Data)
+	ox, oz := pos.Origin()
+	for y := 0; y < ChunkHeight; y++ {
+		for z := 0; z < ChunkSize; z++ {
+			for x := 0; x < ChunkSize; x++ {
+				b := c.Get(x, y, z)
+				if b == Air {
+					continue
+				}
+				info := b.Info()
+				data := opaque
+				if info.Color[3] < 1 {
+					data = translucent
+				}
+				height := float32(1)
+				if b.IsFluid() && c.Get(x, y+1, z) != b {
+					height = FluidHeight(c.State(x, y, z))
+				}
+				for _, f := range faces {
+					nx, ny, nz := x+f.dir[0], y+f.dir[1], z+f.dir[2]
+					var n Block
+					var sky, blk uint8
+					if InBounds(nx, ny, nz) {
+						n = c.Get(nx, ny, nz)
+						sky, blk = c.Light(SkyLight, nx, ny, nz), c.Light(BlockLight, nx, ny, nz)
+					} else {
+						n = w.block(ox+nx, ny, oz+nz)
+						sky, blk = w.light(SkyLight, ox+nx, ny, oz+nz), w.light(BlockLight, ox+nx, ny, oz+nz)
+					}
+					// a lowered fluid surface stays visible below a block
+					lowered := height < 1 && f.dir[1] == 1
+					if n == b || n.IsOpaque() && !lowered {
+						continue
+					}
+					light := mgl32.Vec2{float32(sky) / MaxLight, float32(blk) / MaxLight}
+					tile := faceTile(textures, info.FaceTexture(f.dir[1]))
+					addFace(data, f, mgl32.Vec3{float32(ox + x), float32(y), float32(oz + z)}, height, tile, info.Color, light)
+				}
+			}
+		}
+	}
+	return opaque, translucent
+}
+
+func faceTile(textures *texture.Lookup, name string) texture.Tile {
+	if textures != nil {
+		if tile, ok := textures.Tile(name); ok {
+			return tile
+		}
+	}
+	return texture.Tile{Rect: texture.Rect{U0: 0, V0: 0, U1: 1, V1: 1}}
+}
+
+// addFace adds a face of a block whose top is lowered to height
+func addFace(data *mesh.Data, f face, origin mgl32.Vec3, height float32, tile texture.Tile, color mgl32.Vec4, light mgl32.Vec2) {
+	base := uint32(len(data.Positions))
+	for i, corner := range f.corners {
+		corner[1] *= height
+		data.Positions = append(data.Positions, origin.Add(corner))
+		data.TexCoords = append(data.TexCoords, tile.Rect.Map(faceUVs[i]))
+		data.Layers = append(data.Layers, float32(tile.Layer))
+		data.Normals = append(data.Normals, f.normal)
+		data.Colors = append(data.Colors, color)
+		data.Lights = append(data.Lights, light)
+	}
+	data.Indices = append(data.Indices, base, base+1, base+2, base, base+2, base+3)
+}
+
+// SortFaces reorders the indices of a mesh built by MeshChunk so its faces are
+// drawn back to front as seen from eye, as blending translucent faces requires
+func SortFaces(data *mesh.Data, eye mgl32.Vec3) {
+	type quad struct {
+		base uint32
+		dist float32
+	}
+	quads := make([]quad, len(data.Indices)/6)
+	for i := range quads {
+		base := data.Indices[i*6]
+		// the middle of the diagonal is the center of the face
+		center := data.Positions[base].Add(data.Positions[base+2]).Mul(0.5)
+		d := center.Sub(eye)
+		quads[i] = quad{base, d.Dot(d)}
+	}
+	sort.Slice(quads, func(i, j int) bool { return quads[i].dist > quads[j].dist })
+	for i, q := range quads {
+		b := q.base
+		copy(data.Indices[i*6:], []uint32{b, b + 1, b + 2, b, b + 2, b + 3})
+	}
+}
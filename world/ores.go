@@ -0,0 +1,67 @@
+package world
+
+// OreConfig describes how one ore is scattered through the stone of a chunk
+type OreConfig struct {
+	Block    Block
+	MinY     int
+	MaxY     int
+	Attempts int     // vein attempts per chunk
+	Chance   float64 // probability that an attempt places a vein
+	VeinSize int     // blocks visited by the random walk of a vein
+}
+
+// DefaultOres lists the ores placed by the default generator, common to rare
+var DefaultOres = []OreConfig{
+	{Block: CoalOre, MinY: 5, MaxY: 100, Attempts: 20, Chance: 1, VeinSize: 12},
+	{Block: IronOre, MinY: 5, MaxY: 64, Attempts: 12, Chance: 1, VeinSize: 8},
+	{Block: GoldOre, MinY: 5, MaxY: 32, Attempts: 4, Chance: 0.5, VeinSize: 6},
+	{Block: DiamondOre, MinY: 5, MaxY: 16, Attempts: 2, Chance: 0.25, VeinSize: 5},
+}
+
+// OrePass replaces stone with ore veins. Veins are clipped to the chunk they start in.
+type OrePass struct {
+	Ores []OreConfig
+}
+
+// NewOrePass creates a new ore pass scattering the given ores
+func NewOrePass(ores []OreConfig) *OrePass {
+	p := new(OrePass)
+	p.Ores = ores
+	return p
+}
+
+func (p *OrePass) Name() string {
+	return "ores"
+}
+
+func (p *OrePass) Apply(ctx *Context) {
+	for _, ore := range p.Ores {
+		for i := 0; i < ore.Attempts; i++ {
+			// always draw the same numbers so one ore's rarity doesn't shift the others
+			x, z := ctx.Rand.Intn(ChunkSize), ctx.Rand.Intn(ChunkSize)
+			y := ore.MinY + ctx.Rand.Intn(ore.MaxY-ore.MinY+1)
+			if ctx.Rand.Float64() >= ore.Chance {
+				continue
+			}
+			for n := 0; n < ore.VeinSize; n++ {
+				if ctx.Chunk.Get(x, y, z) == Stone {
+					ctx.Chunk.Set(x, y, z, ore.Block)
+				}
+				switch ctx.Rand.Intn(6) {
+				case 0:
+					x++
+				case 1:
+					x--
+				case 2:
+					y++
+				case 3:
+					y--
+				case 4:
+					z++
+				case 5:
+					z--
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package world
+
+import (
+	"math"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/tehcyx/goengine/mesh"
+)
+
+// Hit describes the block a ray ran into
+type Hit struct {
+	Block    Block
+	Pos      [3]int     // world coordinates of the block
+	Normal   [3]int     // normal of the face the ray entered through, zero if it started inside the block
+	Distance float32    // distance from the ray origin to the entry point
+	Point    mgl32.Vec3 // entry point on the face
+}
+
+// Raycast walks the voxel grid from origin along dir with the Amanatides & Woo DDA
+// and returns the first solid block within maxDistance
+func (w *World) Raycast(origin, dir mgl32.Vec3, maxDistance float32) (Hit, bool) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return Raycast(origin, dir, maxDistance, func(x, y, z int) bool {
+		return w.block(x, y, z).IsSolid()
+	}, w.block)
+}
+
+// Raycast walks the voxel grid from origin along dir and returns the first block
+// within maxDistance for which hit returns true. block looks up the block type
+// reported in the result.
+func Raycast(origin, dir mgl32.Vec3, maxDistance float32, hit func(x, y, z int) bool, block func(x, y, z int) Block) (Hit, bool) {
+	if dir.Len() == 0 {
+		return Hit{}, false
+	}
+	dir = dir.Normalize()
+
+	var pos, step [3]int
+	var tMax, tDelta [3]float64
+	for i := 0; i < 3; i++ {
+		o, d := float64(origin[i]), float64(dir[i])
+		pos[i] = int(math.Floor(o))
+		switch {
+		case d > 0:
+			step[i] = 1
+			tDelta[i] = 1 / d
+			tMax[i] = (float64(pos[i]+1) - o) / d
+		case d < 0:
+			step[i] = -1
+			tDelta[i] = -1 / d
+			tMax[i] = (float64(pos[i]) - o) / d
+		default:
+			tDelta[i] = math.Inf(1)
+			tMax[i] = math.Inf(1)
+		}
+	}
+
+	var normal [3]int
+	t := 0.0
+	for t <= float64(maxDistance) {
+		if hit(pos[0], pos[1], pos[2]) {
+			return Hit{
+				Block:    block(pos[0], pos[1], pos[2]),
+				Pos:      pos,
+				Normal:   normal,
+				Distance: float32(t),
+				Point:    origin.Add(dir.Mul(float32(t))),
+			}, true
+		}
+		// step along the axis whose next cell boundary is closest
+		axis := 0
+		if tMax[1] < tMax[axis] {
+			axis = 1
+		}
+		if tMax[2] < tMax[axis] {
+			axis = 2
+		}
+		t = tMax[axis]
+		tMax[axis] += tDelta[axis]
+		pos[axis] += step[axis]
+		normal = [3]int{}
+		normal[axis] = -step[axis]
+	}
+	return Hit{}, false
+}
+
+// ChunksTouching returns the chunk containing the world block x, z and the
+// neighbours sharing a face with the block, which need remeshing when it changes
+func ChunksTouching(x, z int) []ChunkPos {
+	pos := ChunkPosAt(x, z)
+	chunks := []ChunkPos{pos}
+	lx, lz := floorMod(x, ChunkSize), floorMod(z, ChunkSize)
+	if lx == 0 {
+		chunks = append(chunks, ChunkPos{pos.X - 1, pos.Z})
+	} else if lx == ChunkSize-1 {
+		chunks = append(chunks, ChunkPos{pos.X + 1, pos.Z})
+	}
+	if lz == 0 {
+		chunks = append(chunks, ChunkPos{pos.X, pos.Z - 1})
+	} else if lz == ChunkSize-1 {
+		chunks = append(chunks, ChunkPos{pos.X, pos.Z + 1})
+	}
+	return chunks
+}
+
+// OutlineData builds a slightly enlarged wireframe cube around the block at pos,
+// used to highlight the selected block
+func OutlineData(pos [3]int, color mgl32.Vec4) *mesh.Data {
+	const e = 0.005
+	min := mgl32.Vec3{float32(pos[0]) - e, float32(pos[1]) - e, float32(pos[2]) - e}
+	size := float32(1 + 2*e)
+	data := &mesh.Data{Lines: true}
+	for i := 0; i < 8; i++ {
+		corner := mgl32.Vec3{float32(i & 1), float32(i >> 1 & 1), float32(i >> 2 & 1)}
+		data.Positions = append(data.Positions, min.Add(corner.Mul(size)))
+		data.Normals = append(data.Normals, mgl32.Vec3{0, 1, 0})
+		data.Colors = append(data.Colors, color)
+	}
+	// corners that differ in exactly one bit share an edge
+	for i := uint32(0); i < 8; i++ {
+		for _, bit := range []uint32{1, 2, 4} {
+			if i&bit == 0 {
+				data.Indices = append(data.Indices, i, i|bit)
+			}
+		}
+	}
+	return data
+}
@@ -0,0 +1,370 @@
+package world
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Region files store RegionSize x RegionSize chunks. The layout is
+//
+//	header   magic "GERG", format version uint16, reserved uint16
+//	table    RegionSize*RegionSize entries of offset, length, crc32 uint32, compression uint8, 3 bytes padding
+//	data     the chunk payloads at the offsets given in the table
+//
+// All numbers are little endian. An entry with length 0 marks a chunk that isn't stored.
+const (
+	RegionSize    = 32
+	RegionVersion = 1
+
+	regionMagic      = "GERG"
+	regionHeaderSize = 8
+	regionEntrySize  = 16
+	regionTableSize  = RegionSize * RegionSize * regionEntrySize
+)
+
+// Compression of a stored chunk payload
+const (
+	CompressionNone uint8 = iota
+	CompressionZlib
+)
+
+// ErrCorruptRegion is returned for region files or chunk payloads that fail validation
+var ErrCorruptRegion = errors.New("corrupt region file")
+
+type regionEntry struct {
+	Offset      uint32
+	Length      uint32
+	CRC         uint32
+	Compression uint8
+	_           [3]uint8
+}
+
+// region is a region file held in memory as raw, still compressed chunk payloads
+type region struct {
+	entries [RegionSize * RegionSize]regionEntry
+	blobs   [RegionSize * RegionSize][]byte
+}
+
+// RegionPos is the position of a region in region coordinates
+type RegionPos struct {
+	X, Z int
+}
+
+// RegionOf returns the region the chunk belongs to
+func RegionOf(pos ChunkPos) RegionPos {
+	return RegionPos{floorDiv(pos.X, RegionSize), floorDiv(pos.Z, RegionSize)}
+}
+
+func regionIndex(pos ChunkPos) int {
+	return floorMod(pos.X, RegionSize) + floorMod(pos.Z, RegionSize)*RegionSize
+}
+
+// decodeRegion parses and validates a complete region file
+func decodeRegion(data []byte) (*region, error) {
+	if len(data) < regionHeaderSize+regionTableSize {
+		return nil, fmt.Errorf("%w: file too short", ErrCorruptRegion)
+	}
+	if string(data[:4]) != regionMagic {
+		return nil, fmt.Errorf("%w: bad magic", ErrCorruptRegion)
+	}
+	if v := binary.LittleEndian.Uint16(data[4:]); v != RegionVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrCorruptRegion, v)
+	}
+
+	rg := new(region)
+	if err := binary.Read(bytes.NewReader(data[regionHeaderSize:]), binary.LittleEndian, &rg.entries); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorruptRegion, err)
+	}
+	for i, e := range rg.entries {
+		if e.Length == 0 {
+			continue
+		}
+		end := uint64(e.Offset) + uint64(e.Length)
+		if uint64(e.Offset) < regionHeaderSize+regionTableSize || end > uint64(len(data)) {
+			return nil, fmt.Errorf("%w: chunk %d out of bounds", ErrCorruptRegion, i)
+		}
+		rg.blobs[i] = data[e.Offset:end]
+	}
+	return rg, nil
+}
+
+// salvageRegion keeps the chunks of a region file that failed to decode which
+// are still in bounds, pass their checksum and decode
+func salvageRegion(data []byte) *region {
+	rg := new(region)
+	var entries [RegionSize * RegionSize]regionEntry
+	if len(data) < regionHeaderSize+regionTableSize ||
+		binary.Read(bytes.NewReader(data[regionHeaderSize:]), binary.LittleEndian, &entries) != nil {
+		return rg
+	}
+	for i, e := range entries {
+		end := uint64(e.Offset) + uint64(e.Length)
+		if e.Length == 0 || uint64(e.Offset) < regionHeaderSize+regionTableSize || end > uint64(len(data)) {
+			continue
+		}
+		rg.entries[i], rg.blobs[i] = e, data[e.Offset:end]
+		if _, err := rg.chunk(ChunkPos{i % RegionSize, i / RegionSize}); err != nil {
+			rg.entries[i], rg.blobs[i] = regionEntry{}, nil
+		}
+	}
+	return rg
+}
+
+func (rg *region) encode() []byte {
+	var buf bytes.Buffer
+	buf.WriteString(regionMagic)
+	binary.Write(&buf, binary.LittleEndian, uint16(RegionVersion))
+	binary.Write(&buf, binary.LittleEndian, uint16(0))
+
+	offset := uint32(regionHeaderSize + regionTableSize)
+	for i := range rg.entries {
+		rg.entries[i].Offset = 0
+		rg.entries[i].Length = uint32(len(rg.blobs[i]))
+		if len(rg.blobs[i]) > 0 {
+			rg.entries[i].Offset = offset
+			offset += uint32(len(rg.blobs[i]))
+		}
+	}
+	binary.Write(&buf, binary.LittleEndian, &rg.entries)
+	for _, b := range rg.blobs {
+		buf.Write(b)
+	}
+	return buf.Bytes()
+}
+
+// chunk decodes the chunk at pos, nil if the region doesn't store it
+func (rg *region) chunk(pos ChunkPos) (*Chunk, error) {
+	i := regionIndex(pos)
+	e, blob := rg.entries[i], rg.blobs[i]
+	if len(blob) == 0 {
+		return nil, nil
+	}
+	if crc32.ChecksumIEEE(blob) != e.CRC {
+		return nil, fmt.Errorf("%w: chunk %v checksum mismatch", ErrCorruptRegion, pos)
+	}
+
+	var payload []byte
+	switch e.Compression {
+	case CompressionNone:
+		payload = blob
+	case CompressionZlib:
+		zr, err := zlib.NewReader(bytes.NewReader(blob))
+		if err != nil {
+			return nil, fmt.Errorf("%w: chunk %v: %v", ErrCorruptRegion, pos, err)
+		}
+		// never inflate more than a chunk can hold
+		payload, err = ioutil.ReadAll(io.LimitReader(zr, maxChunkPayload+1))
+		zr.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%w: chunk %v: %v", ErrCorruptRegion, pos, err)
+		}
+	default:
+		return nil, fmt.Errorf("%w: chunk %v unknown compression %d", ErrCorruptRegion, pos, e.Compression)
+	}
+	return decodeChunk(pos, payload)
+}
+
+func (rg *region) setChunk(c *Chunk, compression uint8) {
+	payload := encodeChunk(c)
+	if compression == CompressionZlib {
+		var buf bytes.Buffer
+		zw := zlib.NewWriter(&buf)
+		zw.Write(payload)
+		zw.Close()
+		payload = buf.Bytes()
+	}
+	i := regionIndex(c.Pos)
+	rg.blobs[i] = payload
+	rg.entries[i].CRC = crc32.ChecksumIEEE(payload)
+	rg.entries[i].Compression = compression
+}
+
+// Chunk payloads start with a format byte followed by the raw block array. Since
+// format 2 the block states follow the blocks, format 1 payloads load with all
+// states 0. Light isn't stored, it is computed when a chunk is added to a world.
+const (
+	chunkFormat     = 2
+	chunkBlocks     = ChunkSize * ChunkHeight * ChunkSize
+	maxChunkPayload = 1 + 2*chunkBlocks
+)
+
+func encodeChunk(c *Chunk) []byte {
+	payload := make([]byte, 0, maxChunkPayload)
+	payload = append(payload, chunkFormat)
+	for _, b := range c.blocks {
+		payload = append(payload, byte(b))
+	}
+	return append(payload, c.states[:]...)
+}
+
+func decodeChunk(pos ChunkPos, payload []byte) (*Chunk, error) {
+	if len(payload) == 0 ||
+		!(payload[0] == 1 && len(payload) == 1+chunkBlocks) &&
+			!(payload[0] == chunkFormat && len(payload) == maxChunkPayload) {
+		return nil, fmt.Errorf("%w: chunk %v has a bad payload", ErrCorruptRegion, pos)
+	}
+	c := NewChunk(pos)
+	for i, b := range payload[1 : 1+chunkBlocks] {
+		if Block(b) >= NumBlocks {
+			return nil, fmt.Errorf("%w: chunk %v has unknown block %d", ErrCorruptRegion, pos, b)
+		}
+		c.blocks[i] = Block(b)
+	}
+	copy(c.states[:], payload[1+chunkBlocks:])
+	return c, nil
+}
+
+// RegionStore reads and writes chunks to the region files in a directory
+type RegionStore struct {
+	Dir         string
+	Compression uint8
+
+	mu    sync.Mutex
+	cache map[RegionPos]*region
+}
+
+const regionCacheSize = 16
+
+// NewRegionStore creates a new region store for the given directory
+func NewRegionStore(dir string) *RegionStore {
+	s := new(RegionStore)
+	s.Dir = dir
+	s.Compression = CompressionZlib
+	s.cache = make(map[RegionPos]*region)
+	return s
+}
+
+func (s *RegionStore) path(pos RegionPos) string {
+	return filepath.Join(s.Dir, fmt.Sprintf("r.%d.%d.region", pos.X, pos.Z))
+}
+
+// readRegion returns the region at pos, an empty region if the file doesn't
+// exist. Corrupt files are salvaged.
+func (s *RegionStore) readRegion(pos RegionPos) (*region, error) {
+	if rg := s.cache[pos]; rg != nil {
+		return rg, nil
+	}
+	data, err := ioutil.ReadFile(s.path(pos))
+	if os.IsNotExist(err) {
+		return new(region), nil
+	} else if err != nil {
+		return nil, err
+	}
+	rg, err := decodeRegion(data)
+	if errors.Is(err, ErrCorruptRegion) {
+		rg, err = s.salvage(pos, data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(s.cache) >= regionCacheSize {
+		for k := range s.cache {
+			delete(s.cache, k)
+		}
+	}
+	s.cache[pos] = rg
+	return rg, nil
+}
+
+// ReadChunk reads the chunk at pos, it returns nil and no error if the chunk was never stored
+func (s *RegionStore) ReadChunk(pos ChunkPos) (*Chunk, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rg, err := s.readRegion(RegionOf(pos))
+	if err != nil {
+		return nil, err
+	}
+	return rg.chunk(pos)
+}
+
+// salvage moves the corrupt region file at pos aside to a numbered .corrupt
+// file and rewrites it with the chunks in it that are still intact
+func (s *RegionStore) salvage(pos RegionPos, data []byte) (*region, error) {
+	path := s.path(pos)
+	var backup string
+	for i := 1; ; i++ {
+		// earlier backups are never overwritten
+		backup = fmt.Sprintf("%s.corrupt.%d", path, i)
+		if _, err := os.Stat(backup); err != nil {
+			break
+		}
+	}
+	if err := os.Rename(path, backup); err != nil {
+		return nil, err
+	}
+	log.Printf("region file %s is corrupt, moved it to %s", path, backup)
+	rg := salvageRegion(data)
+	if err := writeFileAtomic(path, rg.encode()); err != nil {
+		return nil, err
+	}
+	return rg, nil
+}
+
+// WriteChunks stores the chunks, rewriting every touched region file. Each file
+// is replaced atomically so a crash leaves either the old or the new version.
+func (s *RegionStore) WriteChunks(chunks []*Chunk) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return err
+	}
+
+	byRegion := make(map[RegionPos][]*Chunk)
+	for _, c := range chunks {
+		byRegion[RegionOf(c.Pos)] = append(byRegion[RegionOf(c.Pos)], c)
+	}
+	for pos, cs := range byRegion {
+		rg, err := s.readRegion(pos)
+		if err != nil {
+			return err
+		}
+		for _, c := range cs {
+			rg.setChunk(c, s.Compression)
+		}
+		if err := writeFileAtomic(s.path(pos), rg.encode()); err != nil {
+			delete(s.cache, pos)
+			return err
+		}
+		s.cache[pos] = rg
+	}
+	return nil
+}
+
+// writeFileAtomic writes data to a temporary file next to path, syncs it and renames it over path
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	f, err := ioutil.TempFile(dir, filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	if _, err = f.Write(data); err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	// make the rename itself durable, not supported on every platform
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+package world
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"testing"
+)
+
+// testRegion encodes a region holding a zlib and an uncompressed chunk
+func testRegion() []byte {
+	rg := new(region)
+	a, b := NewChunk(ChunkPos{0, 0}), NewChunk(ChunkPos{3, 1})
+	a.Set(1, 2, 3, Stone)
+	b.SetWithState(4, 5, 6, Water, 7)
+	rg.setChunk(a, CompressionZlib)
+	rg.setChunk(b, CompressionNone)
+	return rg.encode()
+}
+
+// entryAt returns the offset of the table entry of the chunk at pos
+func entryAt(pos ChunkPos) int {
+	return regionHeaderSize + regionIndex(pos)*regionEntrySize
+}
+
+// mutate returns a copy of data changed by fn
+func mutate(data []byte, fn func(d []byte) []byte) []byte {
+	return fn(append([]byte(nil), data...))
+}
+
+func TestRegionRoundTrip(t *testing.T) {
+	rg, err := decodeRegion(testRegion())
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := rg.chunk(ChunkPos{0, 0})
+	if err != nil || a.Get(1, 2, 3) != Stone {
+		t.Fatalf("zlib chunk: %v, %v", a, err)
+	}
+	b, err := rg.chunk(ChunkPos{3, 1})
+	if err != nil || b.Get(4, 5, 6) != Water || b.State(4, 5, 6) != 7 {
+		t.Fatalf("uncompressed chunk: %v, %v", b, err)
+	}
+	if c, err := rg.chunk(ChunkPos{1, 1}); c != nil || err != nil {
+		t.Fatalf("missing chunk: %v, %v", c, err)
+	}
+}
+
+func TestRegionCorrupt(t *testing.T) {
+	data := testRegion()
+	zlibEntry, rawEntry := entryAt(ChunkPos{0, 0}), entryAt(ChunkPos{3, 1})
+	rawOffset := binary.LittleEndian.Uint32(data[rawEntry:])
+	zlibOffset := binary.LittleEndian.Uint32(data[zlibEntry:])
+	zlibLength := binary.LittleEndian.Uint32(data[zlibEntry+4:])
+	tests := []struct {
+		name string
+		data []byte
+		pos  ChunkPos // chunk to read if the region decodes
+	}{
+		{"empty", nil, ChunkPos{}},
+		{"short header", data[:regionHeaderSize+regionTableSize-1], ChunkPos{}},
+		{"bad magic", mutate(data, func(d []byte) []byte { d[0] = 'X'; return d }), ChunkPos{}},
+		{"bad version", mutate(data, func(d []byte) []byte { d[4] = 9; return d }), ChunkPos{}},
+		{"offset into table", mutate(data, func(d []byte) []byte {
+			binary.LittleEndian.PutUint32(d[zlibEntry:], regionHeaderSize)
+			return d
+		}), ChunkPos{}},
+		{"length past end", mutate(data, func(d []byte) []byte {
+			binary.LittleEndian.PutUint32(d[rawEntry+4:], 1<<31)
+			return d
+		}), ChunkPos{}},
+		{"truncated data", data[:len(data)-1], ChunkPos{}},
+		{"crc mismatch", mutate(data, func(d []byte) []byte { d[rawOffset+10] ^= 0xff; return d }), ChunkPos{3, 1}},
+		{"unknown compression", mutate(data, func(d []byte) []byte { d[rawEntry+12] = 7; return d }), ChunkPos{3, 1}},
+		{"bad zlib", mutate(data, func(d []byte) []byte {
+			blob := d[zlibOffset : zlibOffset+zlibLength]
+			blob[0] ^= 0xff
+			binary.LittleEndian.PutUint32(d[zlibEntry+8:], crc32.ChecksumIEEE(blob))
+			return d
+		}), ChunkPos{0, 0}},
+		{"unknown block", mutate(data, func(d []byte) []byte {
+			blob := d[rawOffset : rawOffset+maxChunkPayload]
+			blob[1] = byte(NumBlocks)
+			binary.LittleEndian.PutUint32(d[rawEntry+8:], crc32.ChecksumIEEE(blob))
+			return d
+		}), ChunkPos{3, 1}},
+		{"bad payload format", mutate(data, func(d []byte) []byte {
+			blob := d[rawOffset : rawOffset+maxChunkPayload]
+			blob[0] = 1
+			binary.LittleEndian.PutUint32(d[rawEntry+8:], crc32.ChecksumIEEE(blob))
+			return d
+		}), ChunkPos{3, 1}},
+	}
+	for _, test := range tests {
+		rg, err := decodeRegion(test.data)
+		if err == nil {
+			_, err = rg.chunk(test.pos)
+		}
+		if !errors.Is(err, ErrCorruptRegion) {
+			t.Errorf("%v: got error %v, want ErrCorruptRegion", test.name, err)
+		}
+	}
+}
+
+// corruptChunk points the entry of the chunk at pos past the end of the region
+// file holding it, which fails the whole region
+func corruptChunk(t *testing.T, s *RegionStore, pos ChunkPos) {
+	t.Helper()
+	path := s.path(RegionOf(pos))
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	binary.LittleEndian.PutUint32(data[entryAt(pos):], uint32(len(data)))
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWriteChunksSalvagesCorruptRegion(t *testing.T) {
+	dir := t.TempDir()
+	s := NewRegionStore(dir)
+	a, b := NewChunk(ChunkPos{0, 0}), NewChunk(ChunkPos{1, 0})
+	a.Set(1, 1, 1, Stone)
+	b.Set(2, 2, 2, Dirt)
+	if err := s.WriteChunks([]*Chunk{a, b}); err != nil {
+		t.Fatal(err)
+	}
+	corruptChunk(t, s, b.Pos)
+
+	s = NewRegionStore(dir)
+	c := NewChunk(ChunkPos{2, 0})
+	c.Set(3, 3, 3, Sand)
+	if err := s.WriteChunks([]*Chunk{c}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(s.path(RegionPos{}) + ".corrupt.1"); err != nil {
+		t.Errorf("the corrupt file wasn't kept: %v", err)
+	}
+	s = NewRegionStore(dir)
+	if got, err := s.ReadChunk(a.Pos); err != nil || got == nil || got.Get(1, 1, 1) != Stone {
+		t.Errorf("intact chunk wasn't kept: %v, %v", got, err)
+	}
+	if got, err := s.ReadChunk(b.Pos); err != nil || got != nil {
+		t.Errorf("corrupt chunk: got %v, %v, want it dropped", got, err)
+	}
+	if got, err := s.ReadChunk(c.Pos); err != nil || got == nil || got.Get(3, 3, 3) != Sand {
+		t.Errorf("new chunk: %v, %v", got, err)
+	}
+}
+
+func TestReadChunkSalvagesCorruptRegion(t *testing.T) {
+	dir := t.TempDir()
+	s := NewRegionStore(dir)
+	a, b := NewChunk(ChunkPos{0, 0}), NewChunk(ChunkPos{1, 0})
+	a.Set(1, 1, 1, Stone)
+	b.Set(2, 2, 2, Dirt)
+	path := s.path(RegionPos{})
+
+	// every corruption is moved aside to a file of its own
+	for i := 1; i <= 2; i++ {
+		s = NewRegionStore(dir)
+		if err := s.WriteChunks([]*Chunk{a, b}); err != nil {
+			t.Fatal(err)
+		}
+		corruptChunk(t, s, b.Pos)
+
+		s = NewRegionStore(dir)
+		if got, err := s.ReadChunk(a.Pos); err != nil || got == nil || got.Get(1, 1, 1) != Stone {
+			t.Errorf("corruption %v: intact chunk didn't load: %v, %v", i, got, err)
+		}
+		if got, err := s.ReadChunk(b.Pos); err != nil || got != nil {
+			t.Errorf("corruption %v: corrupt chunk: got %v, %v, want it dropped", i, got, err)
+		}
+		for j := 1; j <= i; j++ {
+			if _, err := os.Stat(fmt.Sprintf("%s.corrupt.%d", path, j)); err != nil {
+				t.Errorf("corruption %v: backup %v is missing: %v", i, j, err)
+			}
+		}
+
+		// the salvaged region was written back, a new store reads it without errors
+		s = NewRegionStore(dir)
+		if got, err := s.ReadChunk(a.Pos); err != nil || got == nil || got.Get(1, 1, 1) != Stone {
+			t.Errorf("corruption %v: salvaged chunk wasn't written back: %v, %v", i, got, err)
+		}
+		if _, err := os.Stat(fmt.Sprintf("%s.corrupt.%d", path, i+1)); err == nil {
+			t.Errorf("corruption %v: the salvaged region was moved aside again", i)
+		}
+	}
+}
+
+func FuzzDecodeRegion(f *testing.F) {
+	data := testRegion()
+	f.Add(data)
+	f.Add(data[:regionHeaderSize+regionTableSize])
+	f.Add(mutate(data, func(d []byte) []byte { d[entryAt(ChunkPos{0, 0})+8] ^= 1; return d }))
+	f.Add(mutate(data, func(d []byte) []byte { d[entryAt(ChunkPos{3, 1})+12] = CompressionZlib; return d }))
+	f.Add([]byte(regionMagic))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		salvageRegion(data)
+		rg, err := decodeRegion(data)
+		if err != nil {
+			if !errors.Is(err, ErrCorruptRegion) {
+				t.Fatalf("got error %v, want ErrCorruptRegion", err)
+			}
+			return
+		}
+		for i := range rg.entries {
+			c, err := rg.chunk(ChunkPos{i % RegionSize, i / RegionSize})
+			if err != nil && !errors.Is(err, ErrCorruptRegion) {
+				t.Fatalf("chunk %v: got error %v, want ErrCorruptRegion", i, err)
+			}
+			if err == nil && c == nil && len(rg.blobs[i]) > 0 {
+				t.Fatalf("chunk %v is stored but read as missing", i)
+			}
+		}
+	})
+}
@@ -0,0 +1,159 @@
+package world
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/tehcyx/goengine/util"
+)
+
+// A saved world is a directory holding
+//
+//	level.json   format version and seed
+//	pending.gob  structure writes waiting for chunks that were never generated
+//	region/      the region files with the saved chunks
+const (
+	levelFile   = "level.json"
+	pendingFile = "pending.gob"
+	regionDir   = "region"
+
+	levelVersion = 1
+)
+
+type level struct {
+	Version int
+	Seed    int64
+}
+
+// Save writes the world to dir. Only chunks changed since the last save are
+// written, unless the world is saved to a new directory. Every file is replaced
+// atomically, so a crash during Save never leaves a half written world behind.
+func (w *World) Save(dir string) error {
+	w.mu.Lock()
+	all := w.store == nil || w.store.Dir != filepath.Join(dir, regionDir)
+	if all {
+		w.store = NewRegionStore(filepath.Join(dir, regionDir))
+	}
+	store := w.store
+	var chunks []*Chunk
+	for _, c := range w.chunks {
+		if c.dirty || all {
+			snapshot := *c
+			chunks = append(chunks, &snapshot)
+			c.dirty = false
+		}
+	}
+	for pos, c := range w.evicted {
+		chunks = append(chunks, c)
+		delete(w.evicted, pos)
+	}
+	w.mu.Unlock()
+
+	err := w.save(dir, store, chunks)
+	if err != nil {
+		// keep the chunks marked as unsaved so the next Save tries again
+		w.mu.Lock()
+		for _, c := range chunks {
+			if loaded := w.chunks[c.Pos]; loaded != nil {
+				loaded.dirty = true
+			} else {
+				w.evicted[c.Pos] = c
+			}
+		}
+		w.mu.Unlock()
+	}
+	return err
+}
+
+func (w *World) save(dir string, store *RegionStore, chunks []*Chunk) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	lvl, err := json.MarshalIndent(level{levelVersion, w.Generator.Seed}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(filepath.Join(dir, levelFile), lvl); err != nil {
+		return err
+	}
+	// the chunks first, pending writes saved without the chunks that received
+	// some of them would be missing from both after a crash
+	if err := store.WriteChunks(chunks); err != nil {
+		return err
+	}
+	var pending bytes.Buffer
+	if err := gob.NewEncoder(&pending).Encode(w.Generator.Pending()); err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(dir, pendingFile), pending.Bytes())
+}
+
+// Load opens the world saved in dir. Chunks are read from the region files
+// when they are loaded, chunks that were never saved are generated.
+func Load(dir string) (*World, error) {
+	defer util.TimeTrack(time.Now(), "World Load")
+	data, err := ioutil.ReadFile(filepath.Join(dir, levelFile))
+	if err != nil {
+		return nil, err
+	}
+	var lvl level
+	if err := json.Unmarshal(data, &lvl); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", levelFile, err)
+	}
+	if lvl.Version != levelVersion {
+		return nil, fmt.Errorf("unsupported world version %d", lvl.Version)
+	}
+
+	w := NewWorld(lvl.Seed)
+	w.store = NewRegionStore(filepath.Join(dir, regionDir))
+
+	f, err := os.Open(filepath.Join(dir, pendingFile))
+	if err == nil {
+		defer f.Close()
+		var pending map[ChunkPos][]PendingWrite
+		if err := gob.NewDecoder(f).Decode(&pending); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", pendingFile, err)
+		}
+		w.Generator.QueuePending(pending)
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	return w, nil
+}
+
+// StartAutosave saves the world to dir every interval until the returned stop function is called
+func (w *World) StartAutosave(dir string, interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := w.Save(dir); err != nil {
+					log.Printf("autosave failed: %v", err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			wg.Wait()
+		})
+	}
+}
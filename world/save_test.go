@@ -0,0 +1,44 @@
+package world
+
+import "testing"
+
+func TestRemoveChunkKeepsOnlyUnsavedEdits(t *testing.T) {
+	area := []ChunkPos{{0, 0}, {1, 0}, {0, 1}, {1, 1}}
+
+	// without a save directory nothing is kept around after unloading
+	w := NewWorld(7)
+	for _, pos := range area {
+		w.GenerateChunk(pos)
+	}
+	w.SetBlock(3, 100, 3, Stone)
+	for _, pos := range area {
+		w.RemoveChunk(pos)
+	}
+	if len(w.evicted) != 0 {
+		t.Errorf("an unsaved world kept %v unloaded chunks", len(w.evicted))
+	}
+
+	// a saved world keeps the edited chunk only, without structures writing
+	// into their neighbours
+	w = NewWorld(7)
+	w.Generator.Passes = []Pass{NewTerrainPass(7)}
+	if err := w.Save(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	for _, pos := range area {
+		w.GenerateChunk(pos)
+	}
+	edited := ChunkPosAt(3, 3)
+	w.SetBlock(3, 100, 3, Stone)
+	for _, pos := range area {
+		w.RemoveChunk(pos)
+	}
+	for pos := range w.evicted {
+		if pos != edited {
+			t.Errorf("unedited chunk %v was kept", pos)
+		}
+	}
+	if w.evicted[edited] == nil {
+		t.Errorf("edited chunk %v was dropped", edited)
+	}
+}
@@ -0,0 +1,100 @@
+package world
+
+// Structure is a multi block feature placed on the surface. Place receives the
+// world coordinates of the block above the ground and may write across chunk borders.
+type Structure interface {
+	Place(ctx *Context, x, y, z int)
+}
+
+// StructureSpawn describes where and how often a structure is placed
+type StructureSpawn struct {
+	Structure Structure
+	On        Block // surface block the structure has to stand on
+	Attempts  int   // placement attempts per chunk
+	Chance    float64
+}
+
+// DefaultStructures lists the structures placed by the default generator
+var DefaultStructures = []StructureSpawn{
+	{Structure: OakTree{MinHeight: 4, MaxHeight: 6}, On: Grass, Attempts: 3, Chance: 0.6},
+	{Structure: Boulder{Radius: 2}, On: Grass, Attempts: 1, Chance: 0.05},
+}
+
+// StructurePass places structures on top of the terrain
+type StructurePass struct {
+	Spawns []StructureSpawn
+}
+
+// NewStructurePass creates a new structure pass for the given spawns
+func NewStructurePass(spawns []StructureSpawn) *StructurePass {
+	p := new(StructurePass)
+	p.Spawns = spawns
+	return p
+}
+
+func (p *StructurePass) Name() string {
+	return "structures"
+}
+
+func (p *StructurePass) Apply(ctx *Context) {
+	ox, oz := ctx.Chunk.Pos.Origin()
+	for _, s := range p.Spawns {
+		for i := 0; i < s.Attempts; i++ {
+			x, z := ctx.Rand.Intn(ChunkSize), ctx.Rand.Intn(ChunkSize)
+			if ctx.Rand.Float64() >= s.Chance {
+				continue
+			}
+			y := ctx.Chunk.Height(x, z)
+			if y < 0 || ctx.Chunk.Get(x, y, z) != s.On {
+				continue
+			}
+			s.Structure.Place(ctx, ox+x, y+1, oz+z)
+		}
+	}
+}
+
+// OakTree is a log trunk with a rounded leaf canopy
+type OakTree struct {
+	MinHeight, MaxHeight int
+}
+
+func (t OakTree) Place(ctx *Context, x, y, z int) {
+	height := t.MinHeight + ctx.Rand.Intn(t.MaxHeight-t.MinHeight+1)
+	top := y + height
+	for dy := -2; dy <= 1; dy++ {
+		radius := 2
+		if dy > 0 {
+			radius = 1
+		}
+		for dx := -radius; dx <= radius; dx++ {
+			for dz := -radius; dz <= radius; dz++ {
+				// trim the canopy corners
+				if dx*dx == radius*radius && dz*dz == radius*radius {
+					continue
+				}
+				ctx.Place(x+dx, top+dy, z+dz, Leaves)
+			}
+		}
+	}
+	for dy := 0; dy < height; dy++ {
+		ctx.Place(x, y+dy, z, Log)
+	}
+}
+
+// Boulder is a rough sphere of stone half buried in the ground
+type Boulder struct {
+	Radius int
+}
+
+func (b Boulder) Place(ctx *Context, x, y, z int) {
+	r2 := b.Radius * b.Radius
+	for dx := -b.Radius; dx <= b.Radius; dx++ {
+		for dy := -b.Radius; dy <= b.Radius; dy++ {
+			for dz := -b.Radius; dz <= b.Radius; dz++ {
+				if dx*dx+dy*dy+dz*dz <= r2 {
+					ctx.Place(x+dx, y+dy-1, z+dz, Stone)
+				}
+			}
+		}
+	}
+}
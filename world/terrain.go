@@ -0,0 +1,67 @@
+package world
+
+import (
+	"github.com/tehcyx/goengine/noise"
+)
+
+// TerrainPass fills a chunk with the base heightmap terrain: bedrock, stone,
+// a few layers of dirt topped with grass or sand, and water up to sea level.
+type TerrainPass struct {
+	BaseHeight int
+	Amplitude  float64
+	Scale      float64
+	height     *noise.Perlin
+}
+
+// NewTerrainPass creates a new terrain pass for the given world seed
+func NewTerrainPass(seed int64) *TerrainPass {
+	p := new(TerrainPass)
+	p.BaseHeight = SeaLevel + 4
+	p.Amplitude = 24
+	p.Scale = 1.0 / 96
+	p.height = noise.NewPerlin(seed)
+	return p
+}
+
+func (p *TerrainPass) Name() string {
+	return "terrain"
+}
+
+// HeightAt returns the surface height of the world column x, z
+func (p *TerrainPass) HeightAt(x, z int) int {
+	n := p.height.Octave2(float64(x)*p.Scale, float64(z)*p.Scale, 4, 0.5)
+	h := p.BaseHeight + int(n*p.Amplitude)
+	if h < 1 {
+		h = 1
+	} else if h >= ChunkHeight {
+		h = ChunkHeight - 1
+	}
+	return h
+}
+
+func (p *TerrainPass) Apply(ctx *Context) {
+	ox, oz := ctx.Chunk.Pos.Origin()
+	for x := 0; x < ChunkSize; x++ {
+		for z := 0; z < ChunkSize; z++ {
+			h := p.HeightAt(ox+x, oz+z)
+			top := Grass
+			if h <= SeaLevel+1 {
+				top = Sand
+			}
+			ctx.Chunk.Set(x, 0, z, Bedrock)
+			for y := 1; y <= h; y++ {
+				switch {
+				case y == h:
+					ctx.Chunk.Set(x, y, z, top)
+				case y > h-4:
+					ctx.Chunk.Set(x, y, z, Dirt)
+				default:
+					ctx.Chunk.Set(x, y, z, Stone)
+				}
+			}
+			for y := h + 1; y <= SeaLevel; y++ {
+				ctx.Chunk.Set(x, y, z, Water)
+			}
+		}
+	}
+}
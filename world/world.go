@@ -0,0 +1,196 @@
+package world
+
+import (
+	"log"
+	"sync"
+)
+
+// World holds the loaded chunks of a voxel world. It is safe for concurrent use.
+type World struct {
+	Generator *Generator
+
+	mu      sync.RWMutex
+	chunks  map[ChunkPos]*Chunk
+	evicted map[ChunkPos]*Chunk // unloaded chunks with unsaved changes
+	store   *RegionStore
+
+	listenerMu sync.Mutex
+	listeners  map[int]func(BlockChange)
+	nextID     int
+}
+
+// NewWorld creates a new empty world generated from the given seed
+func NewWorld(seed int64) *World {
+	w := new(World)
+	w.Generator = NewGenerator(seed)
+	w.chunks = make(map[ChunkPos]*Chunk)
+	w.evicted = make(map[ChunkPos]*Chunk)
+	return w
+}
+
+// Chunk returns the loaded chunk at pos, nil if it isn't loaded
+func (w *World) Chunk(pos ChunkPos) *Chunk {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.chunks[pos]
+}
+
+// Block returns the block at world coordinates, Air if the chunk isn't loaded
+func (w *World) Block(x, y, z int) Block {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.block(x, y, z)
+}
+
+// SetBlock sets the block at world coordinates with state 0 and reports whether
+// it changed, see SetBlockState
+func (w *World) SetBlock(x, y, z int, b Block) bool {
+	return w.SetBlockState(x, y, z, b, 0)
+}
+
+// State returns the state of the block at world coordinates, e.g. the level of a fluid
+func (w *World) State(x, y, z int) uint8 {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	c := w.chunks[ChunkPosAt(x, z)]
+	if c == nil {
+		return 0
+	}
+	return c.State(floorMod(x, ChunkSize), y, floorMod(z, ChunkSize))
+}
+
+// SetBlockState sets the block and its state at world coordinates and reports
+// whether either changed. Blocks in chunks that aren't loaded can't be set. Light
+// around the block is updated and every change is sent to the subscribers, see Subscribe.
+func (w *World) SetBlockState(x, y, z int, b Block, state uint8) bool {
+	_, ok := w.ReplaceBlock(x, y, z, b, state)
+	return ok
+}
+
+// ReplaceBlock is SetBlockState returning the change it made, with the old block
+// and state read under the same lock that sets the new ones
+func (w *World) ReplaceBlock(x, y, z int, b Block, state uint8) (BlockChange, bool) {
+	w.mu.Lock()
+	c := w.chunks[ChunkPosAt(x, z)]
+	lx, lz := floorMod(x, ChunkSize), floorMod(z, ChunkSize)
+	if c == nil || !InBounds(lx, y, lz) || c.Get(lx, y, lz) == b && c.State(lx, y, lz) == state {
+		w.mu.Unlock()
+		return BlockChange{}, false
+	}
+	old, oldState := c.Get(lx, y, lz), c.State(lx, y, lz)
+	c.SetWithState(lx, y, lz, b, state)
+	changed := w.relight(x, y, z)
+	w.mu.Unlock()
+
+	for _, pos := range ChunksTouching(x, z) {
+		changed[pos] = true
+	}
+	chunks := make([]ChunkPos, 0, len(changed))
+	for pos := range changed {
+		chunks = append(chunks, pos)
+	}
+	change := BlockChange{
+		Pos:      [3]int{x, y, z},
+		Old:      old,
+		New:      b,
+		OldState: oldState,
+		NewState: state,
+		Chunks:   chunks,
+	}
+	w.notify(change)
+	return change, true
+}
+
+func (w *World) block(x, y, z int) Block {
+	c := w.chunks[ChunkPosAt(x, z)]
+	if c == nil {
+		return Air
+	}
+	return c.Get(floorMod(x, ChunkSize), y, floorMod(z, ChunkSize))
+}
+
+// LoadChunk adds the chunk at pos to the world, reading it from the worlds region
+// files if it was saved before and generating it otherwise. It returns the chunk
+// and the loaded neighbours which changed and need to be remeshed.
+func (w *World) LoadChunk(pos ChunkPos) (*Chunk, []ChunkPos) {
+	w.mu.Lock()
+	c := w.evicted[pos]
+	delete(w.evicted, pos)
+	store := w.store
+	w.mu.Unlock()
+
+	if c == nil && store != nil {
+		var err error
+		c, err = store.ReadChunk(pos)
+		if err != nil {
+			log.Printf("loading chunk %v failed, generating it again: %v", pos, err)
+			c = nil
+		}
+	}
+	if c == nil {
+		return w.GenerateChunk(pos)
+	}
+	return w.addChunk(c)
+}
+
+// GenerateChunk generates the chunk at pos and adds it to the world. It returns
+// the chunk and the loaded neighbours which changed and need to be remeshed.
+func (w *World) GenerateChunk(pos ChunkPos) (*Chunk, []ChunkPos) {
+	return w.addChunk(w.Generator.Generate(pos))
+}
+
+func (w *World) addChunk(c *Chunk) (*Chunk, []ChunkPos) {
+	pos := c.Pos
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if existing := w.chunks[pos]; existing != nil {
+		return existing, nil
+	}
+	w.chunks[pos] = c
+	// pick up writes neighbours queued while this chunk was being generated
+	ApplyWrites(c, w.Generator.TakePending(pos))
+	relit := w.lightChunk(c)
+
+	for dx := -1; dx <= 1; dx++ {
+		for dz := -1; dz <= 1; dz++ {
+			n := ChunkPos{pos.X + dx, pos.Z + dz}
+			nc := w.chunks[n]
+			if n == pos || nc == nil {
+				continue
+			}
+			writes := w.Generator.TakePending(n)
+			if ApplyWrites(nc, writes) {
+				relit[n] = true
+				for _, wr := range writes {
+					for p := range w.relight(wr.X, wr.Y, wr.Z) {
+						relit[p] = true
+					}
+				}
+			}
+			// direct neighbours always remesh, their border faces may now be hidden
+			if dx == 0 || dz == 0 {
+				relit[n] = true
+			}
+		}
+	}
+
+	var dirty []ChunkPos
+	for p := range relit {
+		if p != pos && w.chunks[p] != nil {
+			dirty = append(dirty, p)
+		}
+	}
+	return c, dirty
+}
+
+// RemoveChunk unloads the chunk at pos. Unsaved changes of a world that is
+// saved are kept until the next Save, otherwise they are dropped.
+func (w *World) RemoveChunk(pos ChunkPos) {
+	w.mu.Lock()
+	if c := w.chunks[pos]; c != nil && c.dirty && w.store != nil {
+		w.evicted[pos] = c
+	}
+	delete(w.chunks, pos)
+	w.Generator.Release(func(p ChunkPos) bool { return w.chunks[p] != nil })
+	w.mu.Unlock()
+}